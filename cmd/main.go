@@ -11,6 +11,12 @@ func main() {
 	case "server":
 		serverRun()
 
+	case "worker":
+		workerRun()
+
+	case "migrate":
+		migrateRun()
+
 	default:
 		fmt.Println("Unknown mode. Exiting.")
 	}
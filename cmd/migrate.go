@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gouser/config"
+	"gouser/migrations"
+	"gouser/pkg/user"
+	"gouser/utils/initialize"
+	"gouser/utils/migrate"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// migrateRun runs a migrate subcommand given as argument:
+//
+//	up             applies all pending migrations and creates the metadata indexes of the config
+//	down [steps]   reverts the last `steps` migrations, 1 by default
+//	status         lists migrations and when they were applied
+//	create <name>  creates empty up and down files of a new migration in `migrations_dir`
+//	backfill-phones  normalizes the phone numbers saved before 0003_add_phone_number_metadata to E.164,
+//	                 the numbers which could not be normalized are listed and the command exits with status 1
+func migrateRun() {
+	conf := config.New()
+	log := initialize.InitLogrus(conf)
+
+	args := pflag.Args()
+	if len(args) == 0 {
+		fmt.Println("Missing migrate subcommand: up, down, status, create or backfill-phones. Exiting.")
+		return
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			fmt.Println("Missing migration name. Exiting.")
+			return
+		}
+		files, err := migrate.Create(conf.GetString("migrations_dir"), strings.Join(args[1:], "_"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, file := range files {
+			fmt.Println("created", file)
+		}
+		return
+	}
+
+	out, err := initialize.NewGoUserDB(conf, log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.DB.Close()
+
+	m, err := migrate.New(out.DB, log, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migration(s) applied\n", len(applied))
+		repo, err := user.NewDBRepository(user.NewRepositoryIn{Log: log, DB: out.DB})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = user.EnsureMetadataIndexes(ctx, conf, log, repo); err != nil {
+			log.Fatal(err)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("invalid steps %q", args[1])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migration(s) reverted\n", len(reverted))
+
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, appliedAt)
+		}
+
+	case "backfill-phones":
+		report, err := user.BackfillPhoneNumbers(ctx, out.DB, log, conf.GetString("phone_default_region"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d user mobile(s) and %d phone(s) normalized\n", report.Users, report.Phones)
+		for _, f := range report.Failures {
+			fmt.Printf("%s id=%d user_id=%d tenant_id=%d number=%q %s: %s\n", f.Table, f.ID, f.UserID, f.TenantID, f.Number, f.Reason, f.Detail)
+		}
+		if len(report.Failures) > 0 {
+			fmt.Printf("%d number(s) could not be normalized, fix them and run backfill-phones again\n", len(report.Failures))
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Println("Unknown migrate subcommand. Exiting.")
+	}
+}
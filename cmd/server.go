@@ -4,6 +4,10 @@ import (
 	"gouser/config"
 	"gouser/internal/server"
 	"gouser/internal/server/handler"
+	"gouser/pkg/blob"
+	"gouser/pkg/mail"
+	"gouser/pkg/rbac"
+	"gouser/pkg/tenant"
 	"gouser/pkg/user"
 	"gouser/utils/initialize"
 
@@ -20,7 +24,11 @@ func serverRun() {
 		initialize.Module,
 		server.Module,
 		handler.Module,
+		mail.Module,
+		blob.Module,
 		user.Module,
+		rbac.Module,
+		tenant.Module,
 	)
 
 	// Run app forever
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"gouser/config"
+	"gouser/internal/worker"
+	"gouser/pkg/blob"
+	"gouser/pkg/mail"
+	"gouser/pkg/tenant"
+	"gouser/pkg/user"
+	"gouser/utils/initialize"
+
+	"go.uber.org/fx"
+)
+
+func workerRun() {
+	app := fx.New(
+		fx.Provide(
+			// postgresql
+			initialize.NewGoUserDB,
+		),
+		config.Module,
+		initialize.Module,
+		worker.Module,
+		mail.Module,
+		blob.Module,
+		tenant.Module,
+		user.Module,
+	)
+
+	// Run app forever
+	app.Run()
+}
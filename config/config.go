@@ -48,13 +48,157 @@ func New() (config *viper.Viper) {
 			defaultVal: "admin",
 			desc:       "Postgresql password",
 		},
+		"auto_migrate": {
+			defaultVal: "true",
+			desc:       "Apply pending schema migrations when server or worker starts",
+		},
+		"migrations_dir": {
+			defaultVal: "../migrations",
+			desc:       "Directory where `MODE=migrate` create subcommand writes new migration files",
+		},
+		"metadata_indexes": {
+			defaultVal: "",
+			desc:       "Comma separated metadata paths indexed for filters, eg. os,ver:numeric,device.model",
+		},
+		"metadata_gin_index": {
+			defaultVal: "false",
+			desc:       "Create a GIN index of metadata for contains filters",
+		},
+		"blob_driver": {
+			defaultVal: "local",
+			desc:       "Blob store of uploaded files eg. local, s3",
+		},
+		"blob_local_dir": {
+			defaultVal: "../uploads",
+			desc:       "Directory of the local blob store",
+		},
+		"blob_base_url": {
+			defaultVal: "http://127.0.0.1:8765/files",
+			desc:       "Public URL of the blob store, the local store is served by the API server at /files",
+		},
+		"s3_endpoint": {
+			defaultVal: "127.0.0.1:9000",
+			desc:       "Host of the S3 compatible object storage, eg. s3.amazonaws.com",
+		},
+		"s3_region": {
+			defaultVal: "us-east-1",
+			desc:       "Region of the S3 bucket",
+		},
+		"s3_bucket": {
+			defaultVal: "gouser",
+			desc:       "S3 bucket of uploaded files, it must exist",
+		},
+		"s3_access_key": {
+			defaultVal: "",
+			desc:       "S3 access key",
+		},
+		"s3_secret_key": {
+			defaultVal: "",
+			desc:       "S3 secret key",
+		},
+		"s3_use_ssl": {
+			defaultVal: "false",
+			desc:       "Connect to the S3 endpoint over HTTPS",
+		},
+		"s3_base_url": {
+			defaultVal: "",
+			desc:       "Public URL of the S3 bucket eg. a CDN, files are served from the endpoint if empty",
+		},
+		"picture_max_size": {
+			defaultVal: "5242880",
+			desc:       "Maximum size in bytes of uploaded profile pictures",
+		},
+		"picture_sizes": {
+			defaultVal: "64,256,1024",
+			desc:       "Comma separated sizes in pixels of the resized variants of profile pictures, ascending",
+		},
+		"picture_upload_ttl": {
+			defaultVal: "15m",
+			desc:       "Validity of pre-signed picture upload URLs, uploads not finalized within twice the time are deleted",
+		},
+		"rbac_enabled": {
+			defaultVal: "true",
+			desc:       "Require an API key with the permission of each /v1 route, disable only for local development",
+		},
+		"rbac_admin_key": {
+			defaultVal: "",
+			desc:       "Bootstrap API key granted every permission, eg. to create the first API keys. Disabled if empty",
+		},
+		"tenant_default": {
+			defaultVal: "default",
+			desc:       "Slug of the tenant of requests naming no tenant by API key, X-Tenant header or host. Required if empty",
+		},
+		"referral_tree_max_depth": {
+			defaultVal: "5",
+			desc:       "Maximum depth of referral trees served by the API, also the depth of the referral total of a user",
+		},
+		"merge_precedence": {
+			defaultVal: "",
+			desc:       "Comma separated field=strategy rules of user merges, eg. dob=oldest. Strategies are survivor (default), merged, newest and oldest",
+		},
+		"preferences": {
+			defaultVal: "",
+			desc:       "Comma separated preferences of users besides the built-in ones, eg. theme:enum(light|dark)=light,digest_hour:int(0..23)=9",
+		},
+		"duplicate_threshold": {
+			defaultVal: "0.55",
+			desc:       "Minimum score, from 0 to 1, of probable duplicate users. Scores of names alone are at most 0.4",
+		},
 		"port": {
 			defaultVal: "8765",
 			desc:       "Port number of user API server",
 		},
 		"mode": {
 			defaultVal: "server",
-			desc:       "App mode eg. consumer, server, worker",
+			desc:       "App mode eg. consumer, server, worker, migrate",
+		},
+		"phone_default_region": {
+			defaultVal: "IN",
+			desc:       "ISO 3166-1 region used to parse phone numbers written without country code",
+		},
+		"mail_driver": {
+			defaultVal: "log",
+			desc:       "Mail sender eg. log, smtp",
+		},
+		"mail_from": {
+			defaultVal: "no-reply@gouser.local",
+			desc:       "Sender address of outbound mails",
+		},
+		"smtp_host": {
+			defaultVal: "127.0.0.1",
+			desc:       "SMTP server host",
+		},
+		"smtp_port": {
+			defaultVal: "587",
+			desc:       "SMTP server port",
+		},
+		"smtp_username": {
+			defaultVal: "",
+			desc:       "SMTP username, auth is skipped if empty",
+		},
+		"smtp_password": {
+			defaultVal: "",
+			desc:       "SMTP password",
+		},
+		"email_verification_ttl": {
+			defaultVal: "24h",
+			desc:       "Validity of email verification tokens",
+		},
+		"email_verification_url": {
+			defaultVal: "",
+			desc:       "URL of the page verifying emails, the token is sent in `token` query param",
+		},
+		"erasure_grace_period": {
+			defaultVal: "720h",
+			desc:       "Time after which a user scheduled for erasure is permanently erased",
+		},
+		"worker_interval": {
+			defaultVal: "1m",
+			desc:       "Interval at which worker mode runs its jobs",
+		},
+		"duplicate_detection_interval": {
+			defaultVal: "24h",
+			desc:       "Interval at which worker mode detects duplicate users of every tenant",
 		},
 		"log_level": {
 			defaultVal: "debug",
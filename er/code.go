@@ -9,4 +9,74 @@ const (
 	UncaughtException Code = iota // 0
 	InvalidRequestBody
 	UserAlreadyExists
+	UserNotFound
+	PhoneNotFound
+	PhoneAlreadyExists
+	PrimaryPhoneRequired
+	InvalidPhoneNumber
+	EmailNotFound
+	EmailAlreadyExists
+	EmailAlreadyVerified
+	InvalidVerificationToken
+	VerificationTokenExpired
+	UserNotDeleted
+	UserErased
+	ErasureScheduled
+	ErasureNotFound
+	VersionMismatch
+	MetadataSchemaNotFound
+	InvalidMetadataSchema
+	InvalidMetadata
+	InvalidMetadataFilter
+	InvalidPatch
+	UnsupportedPatchType
+	AddressNotFound
+	InvalidCountry
+	InvalidPostalCode
+	PictureTooLarge
+	UnsupportedPictureType
+	InvalidPicture
+	DirectUploadUnsupported
+	PictureUploadNotFound
+	PictureUploadFinalized
+	PictureUploadExpired
+	PictureNotUploaded
+	InvalidStatus
+	IllegalStatusTransition
+	InvalidTag
+	TagNotFound
+	GroupNotFound
+	GroupMemberNotFound
+	GroupMemberAlreadyExists
+	InvalidGroupRole
+	GroupOwnerRequired
+	Unauthorized
+	PermissionDenied
+	RoleNotFound
+	RoleAlreadyExists
+	InvalidPermission
+	APIKeyNotFound
+	APIKeyAlreadyExists
+	TenantNotFound
+	TenantAlreadyExists
+	InvalidTenant
+	TenantRequired
+	TenantAccessDenied
+	ReferrerNotFound
+	SelfReferral
+	ReferralCycle
+	ReferrerAlreadySet
+	InvalidRelationship
+	RelationshipNotFound
+	RelationshipAlreadyExists
+	RelationshipLimitReached
+	RelationshipBlocked
+	RelationshipNotPending
+	InvalidMerge
+	UserMerged
+	InvalidPreference
+	InvalidConsent
+	ConsentDocumentNotFound
+	ConsentNotGranted
+	PrimaryEmailRequired
 )
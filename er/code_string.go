@@ -11,15 +11,86 @@ func _() {
 	_ = x[UncaughtException-0]
 	_ = x[InvalidRequestBody-1]
 	_ = x[UserAlreadyExists-2]
+	_ = x[UserNotFound-3]
+	_ = x[PhoneNotFound-4]
+	_ = x[PhoneAlreadyExists-5]
+	_ = x[PrimaryPhoneRequired-6]
+	_ = x[InvalidPhoneNumber-7]
+	_ = x[EmailNotFound-8]
+	_ = x[EmailAlreadyExists-9]
+	_ = x[EmailAlreadyVerified-10]
+	_ = x[InvalidVerificationToken-11]
+	_ = x[VerificationTokenExpired-12]
+	_ = x[UserNotDeleted-13]
+	_ = x[UserErased-14]
+	_ = x[ErasureScheduled-15]
+	_ = x[ErasureNotFound-16]
+	_ = x[VersionMismatch-17]
+	_ = x[MetadataSchemaNotFound-18]
+	_ = x[InvalidMetadataSchema-19]
+	_ = x[InvalidMetadata-20]
+	_ = x[InvalidMetadataFilter-21]
+	_ = x[InvalidPatch-22]
+	_ = x[UnsupportedPatchType-23]
+	_ = x[AddressNotFound-24]
+	_ = x[InvalidCountry-25]
+	_ = x[InvalidPostalCode-26]
+	_ = x[PictureTooLarge-27]
+	_ = x[UnsupportedPictureType-28]
+	_ = x[InvalidPicture-29]
+	_ = x[DirectUploadUnsupported-30]
+	_ = x[PictureUploadNotFound-31]
+	_ = x[PictureUploadFinalized-32]
+	_ = x[PictureUploadExpired-33]
+	_ = x[PictureNotUploaded-34]
+	_ = x[InvalidStatus-35]
+	_ = x[IllegalStatusTransition-36]
+	_ = x[InvalidTag-37]
+	_ = x[TagNotFound-38]
+	_ = x[GroupNotFound-39]
+	_ = x[GroupMemberNotFound-40]
+	_ = x[GroupMemberAlreadyExists-41]
+	_ = x[InvalidGroupRole-42]
+	_ = x[GroupOwnerRequired-43]
+	_ = x[Unauthorized-44]
+	_ = x[PermissionDenied-45]
+	_ = x[RoleNotFound-46]
+	_ = x[RoleAlreadyExists-47]
+	_ = x[InvalidPermission-48]
+	_ = x[APIKeyNotFound-49]
+	_ = x[APIKeyAlreadyExists-50]
+	_ = x[TenantNotFound-51]
+	_ = x[TenantAlreadyExists-52]
+	_ = x[InvalidTenant-53]
+	_ = x[TenantRequired-54]
+	_ = x[TenantAccessDenied-55]
+	_ = x[ReferrerNotFound-56]
+	_ = x[SelfReferral-57]
+	_ = x[ReferralCycle-58]
+	_ = x[ReferrerAlreadySet-59]
+	_ = x[InvalidRelationship-60]
+	_ = x[RelationshipNotFound-61]
+	_ = x[RelationshipAlreadyExists-62]
+	_ = x[RelationshipLimitReached-63]
+	_ = x[RelationshipBlocked-64]
+	_ = x[RelationshipNotPending-65]
+	_ = x[InvalidMerge-66]
+	_ = x[UserMerged-67]
+	_ = x[InvalidPreference-68]
+	_ = x[InvalidConsent-69]
+	_ = x[ConsentDocumentNotFound-70]
+	_ = x[ConsentNotGranted-71]
+	_ = x[PrimaryEmailRequired-72]
 }
 
-const _Code_name = "UncaughtExceptionInvalidRequestBodyUserAlreadyExists"
+const _Code_name = "UncaughtExceptionInvalidRequestBodyUserAlreadyExistsUserNotFoundPhoneNotFoundPhoneAlreadyExistsPrimaryPhoneRequiredInvalidPhoneNumberEmailNotFoundEmailAlreadyExistsEmailAlreadyVerifiedInvalidVerificationTokenVerificationTokenExpiredUserNotDeletedUserErasedErasureScheduledErasureNotFoundVersionMismatchMetadataSchemaNotFoundInvalidMetadataSchemaInvalidMetadataInvalidMetadataFilterInvalidPatchUnsupportedPatchTypeAddressNotFoundInvalidCountryInvalidPostalCodePictureTooLargeUnsupportedPictureTypeInvalidPictureDirectUploadUnsupportedPictureUploadNotFoundPictureUploadFinalizedPictureUploadExpiredPictureNotUploadedInvalidStatusIllegalStatusTransitionInvalidTagTagNotFoundGroupNotFoundGroupMemberNotFoundGroupMemberAlreadyExistsInvalidGroupRoleGroupOwnerRequiredUnauthorizedPermissionDeniedRoleNotFoundRoleAlreadyExistsInvalidPermissionAPIKeyNotFoundAPIKeyAlreadyExistsTenantNotFoundTenantAlreadyExistsInvalidTenantTenantRequiredTenantAccessDeniedReferrerNotFoundSelfReferralReferralCycleReferrerAlreadySetInvalidRelationshipRelationshipNotFoundRelationshipAlreadyExistsRelationshipLimitReachedRelationshipBlockedRelationshipNotPendingInvalidMergeUserMergedInvalidPreferenceInvalidConsentConsentDocumentNotFoundConsentNotGrantedPrimaryEmailRequired"
 
-var _Code_index = [...]uint8{0, 17, 35, 52}
+var _Code_index = [...]uint16{0, 17, 35, 52, 64, 77, 95, 115, 133, 146, 164, 184, 208, 232, 246, 256, 272, 287, 302, 324, 345, 360, 381, 393, 413, 428, 442, 459, 474, 496, 510, 533, 554, 576, 596, 614, 627, 650, 660, 671, 684, 703, 727, 743, 761, 773, 789, 801, 818, 835, 849, 868, 882, 901, 914, 928, 946, 962, 974, 987, 1005, 1024, 1044, 1069, 1093, 1112, 1134, 1146, 1156, 1173, 1187, 1210, 1227, 1247}
 
 func (i Code) String() string {
-	if i < 0 || i >= Code(len(_Code_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_Code_index)-1 {
 		return "Code(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _Code_name[_Code_index[i]:_Code_index[i+1]]
+	return _Code_name[_Code_index[idx]:_Code_index[idx+1]]
 }
@@ -53,6 +53,16 @@ type E struct {
 
 	// NOP (no-operation) if set will not send error to sentry
 	NOP bool `json:"-"`
+
+	// Fields lists the errors of individual fields of the request, eg. failed validations
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is the error of a single field of the request.
+// Path is the JSON pointer of the field in the request body, eg. `/metadata/hni`
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
 }
 
 // New constructs and returns new E object
@@ -100,6 +110,12 @@ func (e *E) SetStatus(httpStatus int) *E {
 	return e
 }
 
+// SetFields sets the errors of individual fields in the error object
+func (e *E) SetFields(fields ...FieldError) *E {
+	e.Fields = fields
+	return e
+}
+
 // SetTraceID sets the TraceID in the error object
 func (e *E) SetTraceID(traceID string) *E {
 	e.TraceID = traceID
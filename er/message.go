@@ -5,11 +5,151 @@ var messages = map[string]string{
 
 	"422": "Request not valid",
 	"423": "User already exists",
+	"424": "User not found",
+	"425": "Phone number not found",
+	"426": "Phone number is already registered",
+	"427": "User must have a primary phone number",
+	"428": "Phone number is not valid",
+	"429": "Email address not found",
+	"430": "Email address is already registered",
+	"431": "Email address is already verified",
+	"432": "Verification link is not valid",
+	"433": "Verification link has expired",
+	"434": "User is not deleted",
+	"435": "User has been erased",
+	"436": "User is scheduled for erasure",
+	"437": "Erasure request not found",
+	"438": "User was modified by someone else, please reload and try again",
+	"439": "Metadata schema not found",
+	"440": "Metadata schema is not valid",
+	"441": "Metadata does not match its schema",
+	"442": "Metadata filter is not valid",
+	"443": "Patch could not be applied to the user",
+	"444": "Patch content type is not supported",
+	"445": "Address not found",
+	"446": "Country is not valid",
+	"447": "Postal code is not valid for the country",
+	"448": "Picture is too large",
+	"449": "Picture type is not supported, upload a JPEG, PNG, GIF or WebP image",
+	"450": "Picture could not be read",
+	"451": "Direct uploads are not supported by the file store",
+	"452": "Picture upload not found",
+	"453": "Picture upload is already finalized",
+	"454": "Picture upload has expired, please start a new upload",
+	"455": "Picture has not been uploaded yet",
+	"456": "Status is not valid, use one of pending, active, suspended, banned or deactivated",
+	"457": "User cannot be moved to this status from its current status",
+	"458": "Tag is not valid, use lowercase letters, digits, - and _",
+	"459": "Tag not found",
+	"460": "Group not found",
+	"461": "User is not a member of the group",
+	"462": "User is already a member of the group",
+	"463": "Group role is not valid, use one of owner, admin or member",
+	"464": "Group must keep at least one owner",
+	"465": "API key is missing or not valid",
+	"466": "API key is not allowed to make this request",
+	"467": "Role not found",
+	"468": "Role already exists",
+	"469": "Permission is not valid",
+	"470": "API key not found",
+	"471": "API key with this name already exists",
+	"472": "Tenant not found",
+	"473": "Tenant with this slug or host already exists",
+	"474": "Tenant slug is not valid, use lowercase letters, digits and -",
+	"475": "Tenant is missing, send the X-Tenant header",
+	"476": "API key is not allowed to access this tenant",
+	"477": "Referral code or referrer not found",
+	"478": "User cannot refer itself",
+	"479": "Referrer is referred by the user, directly or indirectly",
+	"480": "User already has a referrer",
+	"481": "Relationship type is not valid or the user cannot be related to itself",
+	"482": "Relationship not found",
+	"483": "Relationship of this type between the users already exists",
+	"484": "User has reached the maximum number of relationships of this type",
+	"485": "Relationship not allowed, one of the users blocked the other",
+	"486": "Relationship is already accepted or must be accepted by the other user",
+	"487": "Users cannot be merged, check the merged user and the precedence rules",
+	"488": "User was merged into another user",
+	"489": "Invalid preference",
+	"490": "Invalid consent",
+	"491": "Consent document not found",
+	"492": "Consent not granted",
+	"493": "User must have a primary email",
 }
 
 var codes = map[Code]string{
 	UncaughtException: "1",
 
-	InvalidRequestBody: "422",
-	UserAlreadyExists:  "423",
+	InvalidRequestBody:        "422",
+	UserAlreadyExists:         "423",
+	UserNotFound:              "424",
+	PhoneNotFound:             "425",
+	PhoneAlreadyExists:        "426",
+	PrimaryPhoneRequired:      "427",
+	InvalidPhoneNumber:        "428",
+	EmailNotFound:             "429",
+	EmailAlreadyExists:        "430",
+	EmailAlreadyVerified:      "431",
+	InvalidVerificationToken:  "432",
+	VerificationTokenExpired:  "433",
+	UserNotDeleted:            "434",
+	UserErased:                "435",
+	ErasureScheduled:          "436",
+	ErasureNotFound:           "437",
+	VersionMismatch:           "438",
+	MetadataSchemaNotFound:    "439",
+	InvalidMetadataSchema:     "440",
+	InvalidMetadata:           "441",
+	InvalidMetadataFilter:     "442",
+	InvalidPatch:              "443",
+	UnsupportedPatchType:      "444",
+	AddressNotFound:           "445",
+	InvalidCountry:            "446",
+	InvalidPostalCode:         "447",
+	PictureTooLarge:           "448",
+	UnsupportedPictureType:    "449",
+	InvalidPicture:            "450",
+	DirectUploadUnsupported:   "451",
+	PictureUploadNotFound:     "452",
+	PictureUploadFinalized:    "453",
+	PictureUploadExpired:      "454",
+	PictureNotUploaded:        "455",
+	InvalidStatus:             "456",
+	IllegalStatusTransition:   "457",
+	InvalidTag:                "458",
+	TagNotFound:               "459",
+	GroupNotFound:             "460",
+	GroupMemberNotFound:       "461",
+	GroupMemberAlreadyExists:  "462",
+	InvalidGroupRole:          "463",
+	GroupOwnerRequired:        "464",
+	Unauthorized:              "465",
+	PermissionDenied:          "466",
+	RoleNotFound:              "467",
+	RoleAlreadyExists:         "468",
+	InvalidPermission:         "469",
+	APIKeyNotFound:            "470",
+	APIKeyAlreadyExists:       "471",
+	TenantNotFound:            "472",
+	TenantAlreadyExists:       "473",
+	InvalidTenant:             "474",
+	TenantRequired:            "475",
+	TenantAccessDenied:        "476",
+	ReferrerNotFound:          "477",
+	SelfReferral:              "478",
+	ReferralCycle:             "479",
+	ReferrerAlreadySet:        "480",
+	InvalidRelationship:       "481",
+	RelationshipNotFound:      "482",
+	RelationshipAlreadyExists: "483",
+	RelationshipLimitReached:  "484",
+	RelationshipBlocked:       "485",
+	RelationshipNotPending:    "486",
+	InvalidMerge:              "487",
+	UserMerged:                "488",
+	InvalidPreference:         "489",
+	InvalidConsent:            "490",
+	ConsentDocumentNotFound:   "491",
+	ConsentNotGranted:         "492",
+	PrimaryEmailRequired:      "493",
 }
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) CreateAddress(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.AddressRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	address, err := h.userService.CreateAddress(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = address
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchAddresses(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	addresses, err := h.userService.FetchAddresses(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = addresses
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchAddress(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	addressID, err := paramInt(c, "address_id")
+	if err != nil {
+		return
+	}
+	address, err := h.userService.FetchAddress(dCtx, userID, addressID)
+	if err != nil {
+		return
+	}
+	res.Data = address
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) UpdateAddress(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.AddressRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	addressID, err := paramInt(c, "address_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	address, err := h.userService.UpdateAddress(dCtx, userID, addressID, req)
+	if err != nil {
+		return
+	}
+	res.Data = address
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) DeleteAddress(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	addressID, err := paramInt(c, "address_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.DeleteAddress(dCtx, userID, addressID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
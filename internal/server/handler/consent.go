@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PublishConsentDocument publishes the next version of the consent document of a purpose
+func (h *UserHandler) PublishConsentDocument(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = &user.ConsentDocumentRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	document, err := h.userService.PublishConsentDocument(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = document
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchConsentDocuments lists all versions of the consent documents, optionally of a purpose
+func (h *UserHandler) FetchConsentDocuments(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.ConsentDocumentsRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	documents, err := h.userService.FetchConsentDocuments(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = documents
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchConsentDocument returns a version of a consent document along with its content
+func (h *UserHandler) FetchConsentDocument(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	documentID, err := paramInt(c, "document_id")
+	if err != nil {
+		return
+	}
+	document, err := h.userService.FetchConsentDocument(dCtx, documentID)
+	if err != nil {
+		return
+	}
+	res.Data = document
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// RecordConsent records the user of the path granting or withdrawing its consent to a purpose
+func (h *UserHandler) RecordConsent(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = &user.ConsentRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	event, err := h.userService.RecordConsent(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = event
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchConsents returns the current consent of the user to every purpose
+func (h *UserHandler) FetchConsents(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	states, err := h.userService.FetchConsentStates(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = states
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchConsentHistory lists the consent events of the user, latest first
+func (h *UserHandler) FetchConsentHistory(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.ConsentEventsRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	events, pagination, err := h.userService.FetchConsentEvents(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = events
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchDuplicates lists the clusters of probable duplicate users found by the last detection, highest score first
+func (h *UserHandler) FetchDuplicates(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.DuplicatesRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	clusters, pagination, err := h.userService.FetchDuplicateClusters(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = clusters
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// DetectDuplicates asks the worker to detect the duplicate users of the tenant at its next run,
+// rather than after the detection interval
+func (h *UserHandler) DetectDuplicates(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	request, err := h.userService.RequestDuplicateDetection(dCtx)
+	if err != nil {
+		return
+	}
+	res.Data = request
+	res.Success = true
+	c.JSON(http.StatusAccepted, res)
+}
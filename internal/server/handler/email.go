@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WarningVerificationNotSent is the warning code of a saved email whose verification mail failed
+const WarningVerificationNotSent = "verification_not_sent"
+
+var verificationNotSent = Warning{
+	Code:    WarningVerificationNotSent,
+	Message: "email is saved but the verification mail could not be sent, request a new one",
+}
+
+func (h *UserHandler) CreateEmail(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.EmailRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	email, unsent, err := h.userService.CreateEmail(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	if unsent {
+		res.Warnings = append(res.Warnings, verificationNotSent)
+	}
+	res.Data = email
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchEmails(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	emails, err := h.userService.FetchEmails(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = emails
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchEmail(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	emailID, err := paramInt(c, "email_id")
+	if err != nil {
+		return
+	}
+	email, err := h.userService.FetchEmail(dCtx, userID, emailID)
+	if err != nil {
+		return
+	}
+	res.Data = email
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) UpdateEmail(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.EmailRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	emailID, err := paramInt(c, "email_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	email, unsent, err := h.userService.UpdateEmail(dCtx, userID, emailID, req)
+	if err != nil {
+		return
+	}
+	if unsent {
+		res.Warnings = append(res.Warnings, verificationNotSent)
+	}
+	res.Data = email
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) DeleteEmail(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	emailID, err := paramInt(c, "email_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.DeleteEmail(dCtx, userID, emailID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) SendEmailVerification(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	emailID, err := paramInt(c, "email_id")
+	if err != nil {
+		return
+	}
+	email, err := h.userService.SendEmailVerification(dCtx, userID, emailID)
+	if err != nil {
+		return
+	}
+	res.Data = email
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.VerifyEmailRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	email, err := h.userService.ConfirmEmail(dCtx, req.Token)
+	if err != nil {
+		return
+	}
+	res.Data = email
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) ScheduleErasure(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = user.ErasureRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	erasure, err := h.userService.ScheduleErasure(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = erasure
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchErasure(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	erasure, err := h.userService.FetchErasure(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = erasure
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) CancelErasure(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	erasure, err := h.userService.CancelErasure(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = erasure
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
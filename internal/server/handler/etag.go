@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"gouser/er"
+	"gouser/internal/server/mw"
+	"gouser/pkg/rbac"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the strong entity tag of a user version, eg. `"3"`, or `"3-m"` for the representation
+// with masked PII so that callers with and without `users:read_pii` never share a tag
+func etag(version int, masked bool) string {
+	if masked {
+		return `"` + strconv.Itoa(version) + `-m"`
+	}
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag sets the entity tag of the user version as seen by the caller, it is sent with 304 responses as well
+func setETag(c *gin.Context, version int) {
+	c.Header("Vary", "Authorization")
+	c.Header("ETag", etag(version, !mw.Can(c, rbac.UsersReadPII)))
+}
+
+// matchETag reports whether the `If-Match` or `If-None-Match` header value lists the tag.
+// `*` matches any tag. Weak tags only match when weak comparison is allowed,
+// which is the case for `If-None-Match` but not for `If-Match`.
+func matchETag(header, tag string, weak bool) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" {
+			return true
+		}
+		if strings.HasPrefix(v, "W/") {
+			if !weak {
+				continue
+			}
+			v = v[2:]
+		}
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the `If-None-Match` header lists the tag of the user version as seen by the caller
+func notModified(c *gin.Context, version int) bool {
+	inm := c.GetHeader("If-None-Match")
+	return inm != "" && matchETag(inm, etag(version, !mw.Can(c, rbac.UsersReadPII)), true)
+}
+
+// checkIfMatch fails with `er.VersionMismatch` if the request has an `If-Match` header not matching the version
+func checkIfMatch(c *gin.Context, version int) error {
+	// both representations of the version are current, writes do not depend on the masking
+	if im := c.GetHeader("If-Match"); im != "" && !matchETag(im, etag(version, false), false) && !matchETag(im, etag(version, true), false) {
+		return er.New(errors.New("If-Match does not match the user version"), er.VersionMismatch).SetStatus(http.StatusPreconditionFailed)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gouser/er"
+	"gouser/internal/server/mw"
+	"gouser/pkg/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testContext returns the context of a GET request with the headers, made by a caller allowed to read PII or not
+func testContext(readPII bool, headers map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+	for k, v := range headers {
+		c.Request.Header.Set(k, v)
+	}
+	principal := &rbac.Principal{Name: "test", Permissions: map[rbac.Permission]bool{}}
+	if readPII {
+		principal.Permissions[rbac.UsersReadPII] = true
+	}
+	c.Set(mw.PrincipalKey, principal)
+	return c
+}
+
+func TestETag(t *testing.T) {
+	if got := etag(3, false); got != `"3"` {
+		t.Errorf(`etag(3, false) = %s, want "3"`, got)
+	}
+	if got := etag(3, true); got != `"3-m"` {
+		t.Errorf(`etag(3, true) = %s, want "3-m"`, got)
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		header string
+		tag    string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, `"3"`, false, true},
+		{`"2"`, `"3"`, false, false},
+		{`"2", "3"`, `"3"`, false, true},
+		{`"2","3"`, `"3"`, false, true},
+		{`*`, `"3"`, false, true},
+		{`W/"3"`, `"3"`, true, true},
+		// weak tags never match If-Match
+		{`W/"3"`, `"3"`, false, false},
+		// tags are quoted
+		{`3`, `"3"`, true, false},
+		{`"3-m"`, `"3"`, true, false},
+		{``, `"3"`, true, false},
+	}
+	for _, tt := range tests {
+		if got := matchETag(tt.header, tt.tag, tt.weak); got != tt.want {
+			t.Errorf("matchETag(%q, %s, %v) = %v, want %v", tt.header, tt.tag, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		readPII bool
+		ok      bool
+	}{
+		{``, false, true},
+		{`"3"`, true, true},
+		{`*`, true, true},
+		// either tag of the version is current, whether PII is masked for the caller or not
+		{`"3-m"`, true, true},
+		{`"3"`, false, true},
+		{`"2"`, true, false},
+		{`"2-m"`, false, false},
+		{`W/"3"`, true, false},
+	}
+	for _, tt := range tests {
+		c := testContext(tt.readPII, map[string]string{"If-Match": tt.ifMatch})
+		err := checkIfMatch(c, 3)
+		if tt.ok && err != nil {
+			t.Errorf("checkIfMatch with If-Match %s failed: %v", tt.ifMatch, err)
+		}
+		if !tt.ok {
+			if !er.IsCodeEq(err, er.VersionMismatch) || er.From(err).Status != http.StatusPreconditionFailed {
+				t.Errorf("checkIfMatch with If-Match %s = %v, want 412 VersionMismatch", tt.ifMatch, err)
+			}
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		readPII     bool
+		want        bool
+	}{
+		{``, true, false},
+		{`"3"`, true, true},
+		{`W/"3"`, true, true},
+		{`*`, false, true},
+		{`"2"`, true, false},
+		// a caller sees only the representation matching its permissions
+		{`"3"`, false, false},
+		{`"3-m"`, false, true},
+		{`"3-m"`, true, false},
+	}
+	for _, tt := range tests {
+		c := testContext(tt.readPII, map[string]string{"If-None-Match": tt.ifNoneMatch})
+		if got := notModified(c, 3); got != tt.want {
+			t.Errorf("notModified with If-None-Match %s, read PII %v = %v, want %v", tt.ifNoneMatch, tt.readPII, got, tt.want)
+		}
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	for _, readPII := range []bool{true, false} {
+		c := testContext(readPII, nil)
+		setETag(c, 3)
+		if got := c.Writer.Header().Get("ETag"); got != etag(3, !readPII) {
+			t.Errorf("setETag with read PII %v set %s, want %s", readPII, got, etag(3, !readPII))
+		}
+		if got := c.Writer.Header().Get("Vary"); got != "Authorization" {
+			t.Errorf("setETag set Vary %q, want Authorization", got)
+		}
+	}
+}
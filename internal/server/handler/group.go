@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) CreateGroup(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.GroupRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	group, err := h.userService.CreateGroup(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = group
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchGroup(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	groupID, err := paramInt(c, "group_id")
+	if err != nil {
+		return
+	}
+	group, err := h.userService.FetchGroup(dCtx, groupID)
+	if err != nil {
+		return
+	}
+	res.Data = group
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// AddGroupMember adds a user to the group with the requested role
+func (h *UserHandler) AddGroupMember(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.GroupMemberRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	groupID, err := paramInt(c, "group_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	member, err := h.userService.AddGroupMember(dCtx, groupID, req)
+	if err != nil {
+		return
+	}
+	maskPII(c, member.User)
+	res.Data = member
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateGroupMember changes the role of a member of the group
+func (h *UserHandler) UpdateGroupMember(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.GroupRoleRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	groupID, err := paramInt(c, "group_id")
+	if err != nil {
+		return
+	}
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	member, err := h.userService.UpdateGroupMember(dCtx, groupID, userID, req)
+	if err != nil {
+		return
+	}
+	maskPII(c, member.User)
+	res.Data = member
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) RemoveGroupMember(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	groupID, err := paramInt(c, "group_id")
+	if err != nil {
+		return
+	}
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.RemoveGroupMember(dCtx, groupID, userID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchGroupMembers(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.MembershipRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	groupID, err := paramInt(c, "group_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	members, pagination, err := h.userService.FetchGroupMembers(dCtx, groupID, req)
+	if err != nil {
+		return
+	}
+	for i := range members {
+		maskPII(c, members[i].User)
+	}
+	res.Data = members
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchUserGroups lists the groups of the user along with the role of the user in each
+func (h *UserHandler) FetchUserGroups(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.MembershipRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	members, pagination, err := h.userService.FetchUserGroups(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = members
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -1,10 +1,96 @@
 package handler
 
-import "go.uber.org/fx"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gouser/er"
+	"gouser/internal/server/mw"
+	"gouser/pkg/rbac"
+	"gouser/pkg/user"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/fx"
+)
 
 // Module invokes mainserver
 var Module = fx.Options(
 	fx.Provide(
 		newUserHandler,
+		newRBACHandler,
+		newTenantHandler,
 	),
 )
+
+// maskPII masks the PII of the users unless the caller is allowed to read it
+func maskPII(c *gin.Context, users ...*user.User) {
+	// the representation depends on the permissions of the API key
+	c.Header("Vary", "Authorization")
+	if mw.Can(c, rbac.UsersReadPII) {
+		return
+	}
+	for _, u := range users {
+		if u != nil {
+			u.MaskPII()
+		}
+	}
+}
+
+// canReadDeleted returns a permission error unless the caller is allowed to read soft deleted users
+func canReadDeleted(c *gin.Context) error {
+	if mw.Can(c, rbac.UsersReadDeleted) {
+		return nil
+	}
+	err := errors.New("include_deleted requires " + rbac.UsersReadDeleted)
+	return er.New(err, er.PermissionDenied).SetStatus(http.StatusForbidden).Ignore()
+}
+
+// paramInt reads an integer path param, eg. `user_id` of `/users/:user_id`
+func paramInt(c *gin.Context, name string) (int, error) {
+	str, ok := c.Params.Get(name)
+	if !ok {
+		return 0, er.New(errors.New(name+" empty in param"), er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return v, nil
+}
+
+// bindStrict binds the request like `c.ShouldBind`, but a JSON body with fields unknown to obj is rejected
+// rather than binding the known fields only
+func bindStrict(c *gin.Context, obj interface{}) error {
+	if binding.Default(c.Request.Method, c.ContentType()) != binding.JSON {
+		return c.ShouldBind(obj)
+	}
+	if c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// requestContext returns the context of the request carrying the audit info of the caller.
+// The caller is the API key of the request, and the `X-Actor` header names who acts through it,
+// eg. `support-desk/jane` for the header `jane` sent with the `support-desk` key.
+func requestContext(c *gin.Context) context.Context {
+	actor := c.GetHeader("X-Actor")
+	if p := mw.Principal(c); p != nil && p.Name != "" {
+		actor = strings.TrimSuffix(p.Name+"/"+actor, "/")
+	}
+	return user.WithAuditInfo(c.Request.Context(), user.AuditInfo{
+		Actor:     actor,
+		RequestID: c.GetString(mw.RequestIDKey),
+		SourceIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
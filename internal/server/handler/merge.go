@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MergeUsers merges the requested duplicate user into the user of the path
+func (h *UserHandler) MergeUsers(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = &user.MergeRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	merge, err := h.userService.MergeUsers(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	setETag(c, merge.Survivor.Version)
+	maskPII(c, merge.Survivor)
+	res.Data = merge
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchUserMerges lists the users merged into the user
+func (h *UserHandler) FetchUserMerges(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	merges, err := h.userService.FetchUserMerges(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = merges
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) SaveMetadataSchema(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = user.MetadataSchemaRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	version, err := paramInt(c, "version")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	if version < 1 {
+		err = er.New(errors.New("version must be positive"), er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	schema := &user.MetadataSchema{
+		Namespace: c.Param("namespace"),
+		Version:   version,
+		Schema:    req.Schema,
+	}
+	if err = h.userService.SaveMetadataSchema(dCtx, schema); err != nil {
+		return
+	}
+	res.Data = schema
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchMetadataSchema(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	version, err := paramInt(c, "version")
+	if err != nil {
+		return
+	}
+	schema, err := h.userService.FetchMetadataSchema(dCtx, c.Param("namespace"), version)
+	if err != nil {
+		return
+	}
+	res.Data = schema
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchMetadataSchemas(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	schemas, err := h.userService.FetchMetadataSchemas(dCtx, c.Query("namespace"))
+	if err != nil {
+		return
+	}
+	res.Data = schemas
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
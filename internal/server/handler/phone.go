@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) CreatePhone(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = user.PhoneRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	phone, err := h.userService.CreatePhone(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = phone
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchPhones(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	phones, err := h.userService.FetchPhones(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = phones
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchPhone(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	phoneID, err := paramInt(c, "phone_id")
+	if err != nil {
+		return
+	}
+	phone, err := h.userService.FetchPhone(dCtx, userID, phoneID)
+	if err != nil {
+		return
+	}
+	res.Data = phone
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) UpdatePhone(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = user.PhoneRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	phoneID, err := paramInt(c, "phone_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	phone, err := h.userService.UpdatePhone(dCtx, userID, phoneID, req)
+	if err != nil {
+		return
+	}
+	res.Data = phone
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) DeletePhone(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	phoneID, err := paramInt(c, "phone_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.DeletePhone(dCtx, userID, phoneID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"gouser/er"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is the room left for multipart headers and boundaries of an upload
+const multipartOverhead = 1 << 20
+
+// UploadProfilePicture takes the picture of the user as the `picture` file of a multipart form
+func (h *UserHandler) UploadProfilePicture(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.userService.PictureMaxSize()+multipartOverhead)
+	fh, err := c.FormFile("picture")
+	if err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	user, err := h.userService.UploadProfilePicture(dCtx, userID, file)
+	if err != nil {
+		return
+	}
+	setETag(c, user.Version)
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// CreatePictureUpload returns a pre-signed URL the client PUTs the picture of the user to,
+// followed by a call to `FinalizePictureUpload`. Used with object storages like S3.
+func (h *UserHandler) CreatePictureUpload(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+
+	upload, err := h.userService.CreatePictureUpload(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = upload
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FinalizePictureUpload sets the picture uploaded with the pre-signed URL as the profile picture of the user
+func (h *UserHandler) FinalizePictureUpload(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	uploadID, err := paramInt(c, "upload_id")
+	if err != nil {
+		return
+	}
+
+	user, err := h.userService.FinalizePictureUpload(dCtx, userID, uploadID)
+	if err != nil {
+		return
+	}
+	setETag(c, user.Version)
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
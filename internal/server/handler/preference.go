@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchPreferenceDefinitions lists the preferences of users along with their types and defaults
+func (h *UserHandler) FetchPreferenceDefinitions(c *gin.Context) {
+	var (
+		err error
+		res = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	res.Data = h.userService.PreferenceDefinitions()
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchPreferences returns the effective preferences of the user
+func (h *UserHandler) FetchPreferences(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	prefs, err := h.userService.FetchPreferences(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = prefs
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdatePreferences sets the preferences of the request body, and returns the effective preferences of the user
+func (h *UserHandler) UpdatePreferences(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.PreferencesUpdateRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	prefs, err := h.userService.UpdatePreferences(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = prefs
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchBulkPreferences returns the effective preferences of many users, eg. `?user_id=1&user_id=2&key=language`
+func (h *UserHandler) FetchBulkPreferences(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.PreferencesRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	prefs, err := h.userService.FetchBulkPreferences(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = prefs
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/rbac"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RBACHandler serves the admin APIs of roles and API keys
+type RBACHandler struct {
+	log *logrus.Logger
+
+	rbacService *rbac.Service
+}
+
+func newRBACHandler(
+	log *logrus.Logger,
+	rbacService *rbac.Service,
+) *RBACHandler {
+	return &RBACHandler{
+		log:         log,
+		rbacService: rbacService,
+	}
+}
+
+// FetchPermissions lists the permissions which can be granted to roles
+func (h *RBACHandler) FetchPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, &Response{Success: true, Data: rbac.Permissions})
+}
+
+func (h *RBACHandler) FetchRoles(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	roles, err := h.rbacService.FetchRoles(dCtx)
+	if err != nil {
+		return
+	}
+	res.Data = roles
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *RBACHandler) CreateRole(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &rbac.RoleRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	role, err := h.rbacService.CreateRole(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = role
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateRole replaces the name, description and permissions of the role
+func (h *RBACHandler) UpdateRole(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &rbac.RoleRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	roleID, err := paramInt(c, "role_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	role, err := h.rbacService.UpdateRole(dCtx, roleID, req)
+	if err != nil {
+		return
+	}
+	res.Data = role
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *RBACHandler) DeleteRole(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	roleID, err := paramInt(c, "role_id")
+	if err != nil {
+		return
+	}
+	if err = h.rbacService.DeleteRole(dCtx, roleID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *RBACHandler) FetchAPIKeys(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	keys, err := h.rbacService.FetchAPIKeys(dCtx)
+	if err != nil {
+		return
+	}
+	res.Data = keys
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// CreateAPIKey generates an API key, the key is only returned in this response
+func (h *RBACHandler) CreateAPIKey(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &rbac.APIKeyRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	key, err := h.rbacService.CreateAPIKey(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = key
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *RBACHandler) RevokeAPIKey(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	keyID, err := paramInt(c, "key_id")
+	if err != nil {
+		return
+	}
+	if err = h.rbacService.RevokeAPIKey(dCtx, keyID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// AssignRole assigns the role to the API key
+func (h *RBACHandler) AssignRole(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	keyID, err := paramInt(c, "key_id")
+	if err != nil {
+		return
+	}
+	roleID, err := paramInt(c, "role_id")
+	if err != nil {
+		return
+	}
+	key, err := h.rbacService.AssignRole(dCtx, keyID, roleID)
+	if err != nil {
+		return
+	}
+	res.Data = key
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// UnassignRole removes the role from the API key
+func (h *RBACHandler) UnassignRole(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	keyID, err := paramInt(c, "key_id")
+	if err != nil {
+		return
+	}
+	roleID, err := paramInt(c, "role_id")
+	if err != nil {
+		return
+	}
+	key, err := h.rbacService.UnassignRole(dCtx, keyID, roleID)
+	if err != nil {
+		return
+	}
+	res.Data = key
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetReferrer sets the referrer of a user created without one, by referral code or user ID
+func (h *UserHandler) SetReferrer(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = &user.ReferrerRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	user, err := h.userService.SetReferrer(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	setETag(c, user.Version)
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchReferralTree returns the users referred by the user, nested under the users who referred them
+func (h *UserHandler) FetchReferralTree(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.ReferralTreeRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	tree, err := h.userService.FetchReferralTree(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = tree
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchReferralStats(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	stats, err := h.userService.FetchReferralStats(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = stats
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchReferrerStats lists the users who referred other users, most referrals first
+func (h *UserHandler) FetchReferrerStats(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.ReferralStatsRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	stats, pagination, err := h.userService.FetchReferrerStats(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = stats
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
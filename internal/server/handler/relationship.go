@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRelationship relates the user of the path to another user, eg. as its parent or spouse
+func (h *UserHandler) CreateRelationship(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.RelationshipRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	rel, err := h.userService.CreateRelationship(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = rel
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchRelationships lists the relationships from and to the user, filtered by type, status and direction
+func (h *UserHandler) FetchRelationships(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.RelationshipsRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	rels, pagination, err := h.userService.FetchRelationships(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = rels
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchRelationship(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	relationshipID, err := paramInt(c, "relationship_id")
+	if err != nil {
+		return
+	}
+	rel, err := h.userService.FetchRelationship(dCtx, userID, relationshipID)
+	if err != nil {
+		return
+	}
+	res.Data = rel
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// AcceptRelationship accepts a pending relationship on behalf of the related user of the path
+func (h *UserHandler) AcceptRelationship(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	relationshipID, err := paramInt(c, "relationship_id")
+	if err != nil {
+		return
+	}
+	rel, err := h.userService.AcceptRelationship(dCtx, userID, relationshipID)
+	if err != nil {
+		return
+	}
+	res.Data = rel
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// RemoveRelationship removes a relationship of the user, or declines it if pending
+func (h *UserHandler) RemoveRelationship(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	relationshipID, err := paramInt(c, "relationship_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.RemoveRelationship(dCtx, userID, relationshipID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
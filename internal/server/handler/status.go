@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangeUserStatus moves the user to another status, the caller is identified by the `X-Actor` header
+func (h *UserHandler) ChangeUserStatus(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		req  = &user.StatusRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+
+	user, err := h.userService.ChangeUserStatus(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	setETag(c, user.Version)
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchStatusHistory(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.StatusHistoryRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	changes, pagination, err := h.userService.FetchStatusHistory(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = changes
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchTagCatalogue lists all tags with the number of users having each tag
+func (h *UserHandler) FetchTagCatalogue(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	usages, err := h.userService.FetchTagCatalogue(dCtx)
+	if err != nil {
+		return
+	}
+	res.Data = usages
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// AssignTags adds and removes tags of many users at once
+func (h *UserHandler) AssignTags(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.TagAssignmentRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	result, err := h.userService.AssignTags(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = result
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchUserTags(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	tags, err := h.userService.FetchUserTags(dCtx, userID)
+	if err != nil {
+		return
+	}
+	res.Data = tags
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// AddUserTag assigns the tag of the path to the user and returns the tags of the user
+func (h *UserHandler) AddUserTag(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	tags, err := h.userService.AddUserTag(dCtx, userID, c.Param("tag"))
+	if err != nil {
+		return
+	}
+	res.Data = tags
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// RemoveUserTag removes the tag of the path from the user and returns the remaining tags of the user
+func (h *UserHandler) RemoveUserTag(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	tags, err := h.userService.RemoveUserTag(dCtx, userID, c.Param("tag"))
+	if err != nil {
+		return
+	}
+	res.Data = tags
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
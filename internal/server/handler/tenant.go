@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"gouser/er"
+	"gouser/pkg/tenant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TenantHandler serves the admin APIs of tenants
+type TenantHandler struct {
+	log *logrus.Logger
+
+	tenantService *tenant.Service
+}
+
+func newTenantHandler(
+	log *logrus.Logger,
+	tenantService *tenant.Service,
+) *TenantHandler {
+	return &TenantHandler{
+		log:           log,
+		tenantService: tenantService,
+	}
+}
+
+func (h *TenantHandler) FetchTenants(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	tenants, err := h.tenantService.FetchTenants(dCtx)
+	if err != nil {
+		return
+	}
+	res.Data = tenants
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *TenantHandler) FetchTenant(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	tenantID, err := paramInt(c, "tenant_id")
+	if err != nil {
+		return
+	}
+	t, err := h.tenantService.FetchTenant(dCtx, tenantID)
+	if err != nil {
+		return
+	}
+	res.Data = t
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *TenantHandler) CreateTenant(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &tenant.TenantRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	t, err := h.tenantService.CreateTenant(dCtx, req)
+	if err != nil {
+		return
+	}
+	res.Data = t
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateTenant replaces the slug, name and hosts of the tenant
+func (h *TenantHandler) UpdateTenant(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &tenant.TenantRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	tenantID, err := paramInt(c, "tenant_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	t, err := h.tenantService.UpdateTenant(dCtx, tenantID, req)
+	if err != nil {
+		return
+	}
+	res.Data = t
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
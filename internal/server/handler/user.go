@@ -1,12 +1,14 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"gouser/er"
+	"gouser/internal/server/mw"
+	"gouser/pkg/rbac"
 	"gouser/pkg/user"
 	"net/http"
+	"path"
 	"strconv"
 	"time"
 
@@ -42,26 +44,55 @@ type (
 )
 type (
 	CreateUserRequest struct {
+		UpdateUserRequest
+
+		// Status of the new user, pending or active (default)
+		Status user.Status `json:"status,omitempty"`
+
+		// ReferrerRequest names the user who referred the new user, if any
+		user.ReferrerRequest
+	}
+
+	// UpdateUserRequest is the request body of the full replacement of a user, and the editable part of
+	// a new user. The status and the referrer are changed with their own APIs, a replacement carrying them
+	// is rejected.
+	UpdateUserRequest struct {
 		FirstName      string      `json:"first_name,omitempty"`
 		LastName       string      `json:"last_name,omitempty"`
 		Mobile         string      `json:"mobile" binding:"required"`
 		ProfilePicture string      `json:"profile_picture,omitempty"`
 		DOB            *time.Time  `form:"dob" time_format:"2006-01-02" binding:"required"`
 		Metadata       interface{} `json:"metadata,omitempty"`
+
+		// MetadataNamespace selects the schemas metadata is validated against, the latest version
+		// of the namespace unless MetadataSchemaVersion is set
+		MetadataNamespace     string `json:"metadata_namespace,omitempty"`
+		MetadataSchemaVersion int    `json:"metadata_schema_version,omitempty"`
 	}
 	Response struct {
-		Success bool             `json:"success"`
-		Message string           `json:"message,omitempty"`
-		Data    interface{}      `json:"data,omitempty"`
-		Meta    *user.Pagination `json:"meta,omitempty"`
+		Success  bool             `json:"success"`
+		Message  string           `json:"message,omitempty"`
+		Data     interface{}      `json:"data,omitempty"`
+		Meta     *user.Pagination `json:"meta,omitempty"`
+		Warnings []Warning        `json:"warnings,omitempty"`
+	}
+
+	// Warning is a concern about a successful request, eg. a new user probably duplicating another user
+	Warning struct {
+		Code    string      `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
 	}
 )
 
+// WarningProbableDuplicate is the warning code of a new user probably the same person as existing users
+const WarningProbableDuplicate = "probable_duplicate"
+
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var (
 		err  error
 		now  = time.Now()
-		dCtx = context.Background()
+		dCtx = requestContext(c)
 		req  = CreateUserRequest{}
 		res  = &Response{}
 	)
@@ -75,17 +106,32 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
 		return
 	}
+	mobile, err := h.userService.NormalizePhone(req.Mobile)
+	if err != nil {
+		return
+	}
+	referrerID, err := h.userService.ResolveReferrer(dCtx, &req.ReferrerRequest)
+	if err != nil {
+		return
+	}
 	user := &user.User{
 		FirstName:      req.FirstName,
 		LastName:       req.LastName,
-		Mobile:         req.Mobile,
+		Mobile:         mobile.E164,
 		ProfilePicture: req.ProfilePicture,
 		DOB:            req.DOB,
 		CreatedAt:      &now,
 		UpdatedAt:      &now,
 		Metadata:       req.Metadata,
+
+		MetadataNamespace:     req.MetadataNamespace,
+		MetadataSchemaVersion: req.MetadataSchemaVersion,
+		Status:                req.Status,
+	}
+	if referrerID != 0 {
+		user.ReferredBy = &referrerID
 	}
-	_, ePrr := h.userService.FetchByMobileNumber(dCtx, req.Mobile)
+	_, ePrr := h.userService.FetchByMobileNumber(dCtx, mobile.E164)
 	switch ePrr {
 	case _pg.ErrNoRows:
 
@@ -93,11 +139,26 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		if ePrr != nil {
 			h.log.WithFields(logrus.Fields{
 				"request":    req,
-				"error":      err,
+				"error":      ePrr,
 				"req.Mobile": req.Mobile,
 			}).Info("error inserting user")
+			err = ePrr
 			return
 		}
+		// the user is created anyway, a failed check only leaves out the warning
+		if check, _ := strconv.ParseBool(c.Query("check_duplicates")); check {
+			matches, ePrr := h.userService.FindDuplicates(dCtx, user)
+			if ePrr != nil {
+				h.log.WithField("error", ePrr.Error()).Error("error checking duplicates of new user")
+			} else if len(matches) > 0 {
+				res.Warnings = append(res.Warnings, Warning{
+					Code:    WarningProbableDuplicate,
+					Message: "user is probably the same person as existing users",
+					Data:    matches,
+				})
+			}
+		}
+		maskPII(c, user)
 		res.Data = user
 		res.Success = true
 	case nil:
@@ -105,8 +166,8 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		err = er.New(err, er.UserAlreadyExists).SetStatus(http.StatusUnprocessableEntity)
 		return
 	default:
-		h.log.Info("error while fetching data from database", err.Error())
-		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		h.log.Info("error while fetching data from database", ePrr.Error())
+		err = er.New(ePrr, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
 		res.Message = err.Error()
 		return
 	}
@@ -116,7 +177,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 func (h *UserHandler) FetchUserByID(c *gin.Context) {
 	var (
 		err  error
-		dCtx = context.Background()
+		dCtx = c.Request.Context()
 		res  = &Response{}
 	)
 	defer func() {
@@ -138,14 +199,48 @@ func (h *UserHandler) FetchUserByID(c *gin.Context) {
 		h.log.Info("error while converting string to int: " + err.Error())
 		return
 	}
-	user, ePrr := h.userService.FetchUserByID(dCtx, userID)
+	fetch := h.userService.FetchUserByID
+	if includeDeleted, _ := strconv.ParseBool(c.Query("include_deleted")); includeDeleted {
+		if err = canReadDeleted(c); err != nil {
+			return
+		}
+		fetch = h.userService.FetchUserByIDIncludingDeleted
+	}
+	user, ePrr := fetch(dCtx, userID)
+	if ePrr == _pg.ErrNoRows {
+		// the IDs of merged users redirect to the users they were merged into
+		survivor, mErr := h.userService.FetchMergeSurvivor(dCtx, userID)
+		if mErr != nil {
+			err = mErr
+			return
+		}
+		if survivor != nil {
+			c.Header("Location", path.Join(path.Dir(c.Request.URL.Path), strconv.Itoa(survivor.ID)))
+			setETag(c, survivor.Version)
+			maskPII(c, survivor)
+			res.Data = survivor
+			res.Message = fmt.Sprintf("user %d was merged into user %d", userID, survivor.ID)
+			res.Success = true
+			c.JSON(http.StatusMovedPermanently, res)
+			return
+		}
+	}
 	switch ePrr {
-	case _pg.ErrNoRows, nil:
+	case nil:
+		setETag(c, user.Version)
+		if notModified(c, user.Version) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		maskPII(c, user)
 		res.Data = user
 		res.Success = true
+	case _pg.ErrNoRows:
+		err = er.New(ePrr, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
 	default:
-		h.log.Info("error while fetching data from database", err.Error())
-		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		h.log.Info("error while fetching data from database", ePrr.Error())
+		err = er.New(ePrr, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
 		res.Message = err.Error()
 		return
 	}
@@ -155,7 +250,7 @@ func (h *UserHandler) FetchUserByID(c *gin.Context) {
 func (h *UserHandler) FetchAllUsers(c *gin.Context) {
 	var (
 		err  error
-		dCtx = context.Background()
+		dCtx = c.Request.Context()
 		req  = &user.UserRequest{}
 		res  = &Response{}
 	)
@@ -170,9 +265,23 @@ func (h *UserHandler) FetchAllUsers(c *gin.Context) {
 		res.Message = err.Error()
 		return
 	}
+	// filters by contact details would reveal them, eg. by trying numbers
+	if (req.Mobile != nil || req.Email != nil) && !mw.Can(c, rbac.UsersReadPII) {
+		err = errors.New("mobile and email filters require " + rbac.UsersReadPII)
+		err = er.New(err, er.PermissionDenied).SetStatus(http.StatusForbidden).Ignore()
+		return
+	}
+	if req.IncludeDeleted {
+		if err = canReadDeleted(c); err != nil {
+			return
+		}
+	}
 	users, pagination, ePrr := h.userService.FetchAllUsers(dCtx, req)
 	switch ePrr {
 	case _pg.ErrNoRows, nil:
+		for i := range users {
+			maskPII(c, &users[i])
+		}
 		res.Data = users
 		res.Meta = &pagination
 		res.Success = true
@@ -189,8 +298,8 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var (
 		err  error
 		now  = time.Now()
-		dCtx = context.Background()
-		req  = CreateUserRequest{}
+		dCtx = requestContext(c)
+		req  = UpdateUserRequest{}
 		res  = &Response{}
 	)
 	defer func() {
@@ -199,7 +308,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			return
 		}
 	}()
-	if err = c.ShouldBind(&req); err != nil {
+	if err = bindStrict(c, &req); err != nil {
 		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
 		return
 	}
@@ -225,6 +334,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		DOB:            req.DOB,
 		UpdatedAt:      &now,
 		Metadata:       req.Metadata,
+
+		MetadataNamespace:     req.MetadataNamespace,
+		MetadataSchemaVersion: req.MetadataSchemaVersion,
 	}
 	savedUser, err := h.userService.FetchUserByID(dCtx, userID)
 	switch err {
@@ -234,18 +346,156 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		res.Message = err.Error()
 		return
 	case nil:
+		if err = checkIfMatch(c, savedUser.Version); err != nil {
+			return
+		}
 		user.ID = savedUser.ID
+		user.Version = savedUser.Version
+		// the status is only changed through the status API
+		user.Status = savedUser.Status
+		if user.MetadataNamespace == "" {
+			user.MetadataNamespace = savedUser.MetadataNamespace
+		}
 		err = h.userService.UpdateUser(dCtx, user)
 		if err != nil {
 			return
 		}
+		setETag(c, user.Version)
 	default:
 		h.log.Info("error while fetching data from database", err.Error())
 		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
 		res.Message = err.Error()
 		return
 	}
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// PatchUser applies a JSON Merge Patch or a JSON Patch to the user, selected by the content type of the request
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	patch, err := c.GetRawData()
+	if err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	savedUser, err := h.userService.FetchUserByID(dCtx, userID)
+	if err == _pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if err = checkIfMatch(c, savedUser.Version); err != nil {
+		return
+	}
+	patched, err := h.userService.PatchUser(dCtx, savedUser, c.ContentType(), patch)
+	if err != nil {
+		return
+	}
+	user, err := h.userService.FetchUserByID(dCtx, patched.ID)
+	if err != nil {
+		return
+	}
+	setETag(c, user.Version)
+	maskPII(c, user)
 	res.Data = user
 	res.Success = true
 	c.JSON(http.StatusOK, res)
 }
+
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = h.userService.DeleteUser(dCtx, userID); err != nil {
+		return
+	}
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	var (
+		err  error
+		dCtx = requestContext(c)
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	user, err := h.userService.RestoreUser(dCtx, userID)
+	if err != nil {
+		return
+	}
+	maskPII(c, user)
+	res.Data = user
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) FetchUserHistory(c *gin.Context) {
+	var (
+		err  error
+		dCtx = c.Request.Context()
+		req  = &user.HistoryRequest{}
+		res  = &Response{}
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}()
+	userID, err := paramInt(c, "user_id")
+	if err != nil {
+		return
+	}
+	if err = c.ShouldBind(req); err != nil {
+		err = er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	audits, pagination, err := h.userService.FetchUserHistory(dCtx, userID, req)
+	if err != nil {
+		return
+	}
+	res.Data = audits
+	res.Meta = &pagination
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
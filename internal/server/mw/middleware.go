@@ -2,6 +2,8 @@
 package mw
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"gouser/er"
 	"net/http"
 
@@ -36,3 +38,23 @@ func ErrorHandlerX(log *logrus.Logger) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequestIDKey is the gin context key of the request ID set by `RequestID`
+const RequestIDKey = "request_id"
+
+// RequestID identifies each request by its `X-Request-ID` header, generated when the client sends none.
+// The ID is set in the gin context under `RequestIDKey` and echoed in the response headers.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err == nil {
+				id = hex.EncodeToString(b)
+			}
+		}
+		c.Set(RequestIDKey, id)
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package mw
+
+import (
+	"errors"
+	"gouser/er"
+	"gouser/pkg/rbac"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey is the gin context key of the principal set by `Authenticate`
+const PrincipalKey = "principal"
+
+// Authenticate resolves the API key sent as `Authorization: Bearer <key>` to its principal and sets it
+// in the gin context under `PrincipalKey`. Requests without a key go on unauthenticated, routes
+// declaring a permission with `Require` reject them. When RBAC is disabled every request is let through.
+func Authenticate(auth *rbac.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() {
+			c.Set(PrincipalKey, auth.Anonymous())
+			c.Next()
+			return
+		}
+		key := bearerToken(c.GetHeader("Authorization"))
+		if key == "" {
+			c.Next()
+			return
+		}
+		principal, err := auth.Authenticate(c.Request.Context(), key)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Set(PrincipalKey, principal)
+		c.Next()
+	}
+}
+
+// Require rejects the request with 401 if the caller is not authenticated,
+// or with 403 if the caller does not have the permission
+func Require(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := Principal(c)
+		if principal == nil {
+			err := errors.New("api key required")
+			c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+			c.Abort()
+			return
+		}
+		if !principal.Can(perm) {
+			err := errors.New("permission " + perm + " denied to " + principal.Name)
+			c.Error(er.New(err, er.PermissionDenied).SetStatus(http.StatusForbidden).Ignore())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Principal returns the caller of the request set by `Authenticate`, nil if not authenticated
+func Principal(c *gin.Context) *rbac.Principal {
+	v, _ := c.Get(PrincipalKey)
+	principal, _ := v.(*rbac.Principal)
+	return principal
+}
+
+// Can reports whether the caller of the request has the permission
+func Can(c *gin.Context, perm rbac.Permission) bool {
+	return Principal(c).Can(perm)
+}
+
+// bearerToken returns the token of an `Authorization: Bearer <token>` header, empty for other schemes
+func bearerToken(header string) string {
+	const scheme = "Bearer "
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(scheme):])
+}
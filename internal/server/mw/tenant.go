@@ -0,0 +1,31 @@
+package mw
+
+import (
+	"gouser/pkg/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader names the tenant of a request by its slug, it is sent back with the slug of the resolved tenant
+const TenantHeader = "X-Tenant"
+
+// ResolveTenant resolves the tenant of the request, see `tenant.Service.Resolve`, and scopes the request
+// context to it, so that handlers passing the context on only see and change the users of the tenant.
+// It must run after `Authenticate`.
+func ResolveTenant(tenants *tenant.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var keyTenantID int
+		if p := Principal(c); p != nil {
+			keyTenantID = p.TenantID
+		}
+		t, err := tenants.Resolve(c.Request.Context(), keyTenantID, c.GetHeader(TenantHeader), c.Request.Host)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Header(TenantHeader, t.Slug)
+		c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), t.ID))
+		c.Next()
+	}
+}
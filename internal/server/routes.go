@@ -2,6 +2,7 @@ package server
 
 import (
 	"gouser/internal/server/mw"
+	"gouser/pkg/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,11 +11,108 @@ func v1Routes(router *gin.RouterGroup, o *Options) {
 	r := router.Group("/v1/")
 
 	// middlewares
-	r.Use(mw.ErrorHandlerX(o.Log))
+	r.Use(mw.ErrorHandlerX(o.Log), mw.Authenticate(o.RBAC))
 
-	//add new routes here
-	r.POST("/users", o.UserHandler.CreateUser)
-	r.GET("/users/:user_id", o.UserHandler.FetchUserByID)
-	r.GET("/users", o.UserHandler.FetchAllUsers)
-	r.PUT("users/:user_id", o.UserHandler.UpdateUser)
+	// platform routes manage all tenants, they are not scoped to a tenant
+	platform := r.Group("")
+	platform.GET("/permissions", mw.Require(rbac.RBACAdmin), o.RBACHandler.FetchPermissions)
+	platform.GET("/roles", mw.Require(rbac.RBACAdmin), o.RBACHandler.FetchRoles)
+	platform.POST("/roles", mw.Require(rbac.RBACAdmin), o.RBACHandler.CreateRole)
+	platform.PUT("/roles/:role_id", mw.Require(rbac.RBACAdmin), o.RBACHandler.UpdateRole)
+	platform.DELETE("/roles/:role_id", mw.Require(rbac.RBACAdmin), o.RBACHandler.DeleteRole)
+	platform.GET("/api-keys", mw.Require(rbac.RBACAdmin), o.RBACHandler.FetchAPIKeys)
+	platform.POST("/api-keys", mw.Require(rbac.RBACAdmin), o.RBACHandler.CreateAPIKey)
+	platform.DELETE("/api-keys/:key_id", mw.Require(rbac.RBACAdmin), o.RBACHandler.RevokeAPIKey)
+	platform.PUT("/api-keys/:key_id/roles/:role_id", mw.Require(rbac.RBACAdmin), o.RBACHandler.AssignRole)
+	platform.DELETE("/api-keys/:key_id/roles/:role_id", mw.Require(rbac.RBACAdmin), o.RBACHandler.UnassignRole)
+
+	platform.GET("/tenants", mw.Require(rbac.TenantsAdmin), o.TenantHandler.FetchTenants)
+	platform.POST("/tenants", mw.Require(rbac.TenantsAdmin), o.TenantHandler.CreateTenant)
+	platform.GET("/tenants/:tenant_id", mw.Require(rbac.TenantsAdmin), o.TenantHandler.FetchTenant)
+	platform.PUT("/tenants/:tenant_id", mw.Require(rbac.TenantsAdmin), o.TenantHandler.UpdateTenant)
+
+	// all other routes serve the users of the tenant of the request
+	r.Use(mw.ResolveTenant(o.Tenants))
+
+	// add new routes here along with the permission they require, routes without one are public
+	r.POST("/users", mw.Require(rbac.UsersWrite), o.UserHandler.CreateUser)
+	r.GET("/users/:user_id", mw.Require(rbac.UsersRead), o.UserHandler.FetchUserByID)
+	r.GET("/users", mw.Require(rbac.UsersRead), o.UserHandler.FetchAllUsers)
+	r.PUT("users/:user_id", mw.Require(rbac.UsersWrite), o.UserHandler.UpdateUser)
+	r.PATCH("/users/:user_id", mw.Require(rbac.UsersWrite), o.UserHandler.PatchUser)
+	r.DELETE("/users/:user_id", mw.Require(rbac.UsersWrite), o.UserHandler.DeleteUser)
+	r.POST("/users/:user_id/restore", mw.Require(rbac.UsersWrite), o.UserHandler.RestoreUser)
+	r.GET("/users/:user_id/history", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchUserHistory)
+	r.PUT("/users/:user_id/status", mw.Require(rbac.UsersWrite), o.UserHandler.ChangeUserStatus)
+	r.GET("/users/:user_id/status/history", mw.Require(rbac.UsersRead), o.UserHandler.FetchStatusHistory)
+	r.PUT("/users/:user_id/referrer", mw.Require(rbac.UsersWrite), o.UserHandler.SetReferrer)
+	r.GET("/users/:user_id/referrals", mw.Require(rbac.UsersRead), o.UserHandler.FetchReferralTree)
+	r.GET("/users/:user_id/referrals/stats", mw.Require(rbac.UsersRead), o.UserHandler.FetchReferralStats)
+	r.GET("/referrals/stats", mw.Require(rbac.UsersRead), o.UserHandler.FetchReferrerStats)
+	r.PUT("/users/:user_id/picture", mw.Require(rbac.UsersWrite), o.UserHandler.UploadProfilePicture)
+	r.POST("/users/:user_id/picture/uploads", mw.Require(rbac.UsersWrite), o.UserHandler.CreatePictureUpload)
+	r.POST("/users/:user_id/picture/uploads/:upload_id/finalize", mw.Require(rbac.UsersWrite), o.UserHandler.FinalizePictureUpload)
+	r.POST("/users/:user_id/merges", mw.Require(rbac.UsersMerge), o.UserHandler.MergeUsers)
+	r.GET("/users/:user_id/merges", mw.Require(rbac.UsersRead), o.UserHandler.FetchUserMerges)
+	r.GET("/duplicates", mw.Require(rbac.UsersRead), o.UserHandler.FetchDuplicates)
+	r.POST("/duplicates/detection", mw.Require(rbac.UsersMerge), o.UserHandler.DetectDuplicates)
+	r.GET("/users/:user_id/preferences", mw.Require(rbac.UsersRead), o.UserHandler.FetchPreferences)
+	r.PUT("/users/:user_id/preferences", mw.Require(rbac.UsersWrite), o.UserHandler.UpdatePreferences)
+	r.GET("/preferences", mw.Require(rbac.UsersRead), o.UserHandler.FetchBulkPreferences)
+	r.GET("/preferences/definitions", mw.Require(rbac.UsersRead), o.UserHandler.FetchPreferenceDefinitions)
+	r.POST("/users/:user_id/consents", mw.Require(rbac.UsersWrite), o.UserHandler.RecordConsent)
+	r.GET("/users/:user_id/consents", mw.Require(rbac.UsersRead), o.UserHandler.FetchConsents)
+	r.GET("/users/:user_id/consents/history", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchConsentHistory)
+	r.POST("/users/:user_id/erasure", mw.Require(rbac.UsersErase), o.UserHandler.ScheduleErasure)
+	r.GET("/users/:user_id/erasure", mw.Require(rbac.UsersRead), o.UserHandler.FetchErasure)
+	r.DELETE("/users/:user_id/erasure", mw.Require(rbac.UsersErase), o.UserHandler.CancelErasure)
+
+	r.POST("/users/:user_id/phones", mw.Require(rbac.UsersWrite), o.UserHandler.CreatePhone)
+	r.GET("/users/:user_id/phones", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchPhones)
+	r.GET("/users/:user_id/phones/:phone_id", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchPhone)
+	r.PUT("/users/:user_id/phones/:phone_id", mw.Require(rbac.UsersWrite), o.UserHandler.UpdatePhone)
+	r.DELETE("/users/:user_id/phones/:phone_id", mw.Require(rbac.UsersWrite), o.UserHandler.DeletePhone)
+
+	r.POST("/users/:user_id/emails", mw.Require(rbac.UsersWrite), o.UserHandler.CreateEmail)
+	r.GET("/users/:user_id/emails", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchEmails)
+	r.GET("/users/:user_id/emails/:email_id", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchEmail)
+	r.PUT("/users/:user_id/emails/:email_id", mw.Require(rbac.UsersWrite), o.UserHandler.UpdateEmail)
+	r.DELETE("/users/:user_id/emails/:email_id", mw.Require(rbac.UsersWrite), o.UserHandler.DeleteEmail)
+	r.POST("/users/:user_id/emails/:email_id/verification", mw.Require(rbac.UsersWrite), o.UserHandler.SendEmailVerification)
+	// verification links are opened by the owners of the emails
+	r.POST("/emails/verify", o.UserHandler.VerifyEmail)
+
+	r.POST("/users/:user_id/addresses", mw.Require(rbac.UsersWrite), o.UserHandler.CreateAddress)
+	r.GET("/users/:user_id/addresses", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchAddresses)
+	r.GET("/users/:user_id/addresses/:address_id", mw.Require(rbac.UsersReadPII), o.UserHandler.FetchAddress)
+	r.PUT("/users/:user_id/addresses/:address_id", mw.Require(rbac.UsersWrite), o.UserHandler.UpdateAddress)
+	r.DELETE("/users/:user_id/addresses/:address_id", mw.Require(rbac.UsersWrite), o.UserHandler.DeleteAddress)
+
+	r.GET("/users/:user_id/tags", mw.Require(rbac.UsersRead), o.UserHandler.FetchUserTags)
+	r.PUT("/users/:user_id/tags/:tag", mw.Require(rbac.UsersWrite), o.UserHandler.AddUserTag)
+	r.DELETE("/users/:user_id/tags/:tag", mw.Require(rbac.UsersWrite), o.UserHandler.RemoveUserTag)
+	r.GET("/tags", mw.Require(rbac.UsersRead), o.UserHandler.FetchTagCatalogue)
+	r.POST("/tags/assignments", mw.Require(rbac.UsersWrite), o.UserHandler.AssignTags)
+
+	r.POST("/users/:user_id/relationships", mw.Require(rbac.UsersWrite), o.UserHandler.CreateRelationship)
+	r.GET("/users/:user_id/relationships", mw.Require(rbac.UsersRead), o.UserHandler.FetchRelationships)
+	r.GET("/users/:user_id/relationships/:relationship_id", mw.Require(rbac.UsersRead), o.UserHandler.FetchRelationship)
+	r.POST("/users/:user_id/relationships/:relationship_id/accept", mw.Require(rbac.UsersWrite), o.UserHandler.AcceptRelationship)
+	r.DELETE("/users/:user_id/relationships/:relationship_id", mw.Require(rbac.UsersWrite), o.UserHandler.RemoveRelationship)
+
+	r.GET("/users/:user_id/groups", mw.Require(rbac.UsersRead), o.UserHandler.FetchUserGroups)
+	r.POST("/groups", mw.Require(rbac.UsersWrite), o.UserHandler.CreateGroup)
+	r.GET("/groups/:group_id", mw.Require(rbac.UsersRead), o.UserHandler.FetchGroup)
+	r.GET("/groups/:group_id/members", mw.Require(rbac.UsersRead), o.UserHandler.FetchGroupMembers)
+	r.POST("/groups/:group_id/members", mw.Require(rbac.UsersWrite), o.UserHandler.AddGroupMember)
+	r.PUT("/groups/:group_id/members/:user_id", mw.Require(rbac.UsersWrite), o.UserHandler.UpdateGroupMember)
+	r.DELETE("/groups/:group_id/members/:user_id", mw.Require(rbac.UsersWrite), o.UserHandler.RemoveGroupMember)
+
+	r.POST("/consent-documents", mw.Require(rbac.ConsentsWrite), o.UserHandler.PublishConsentDocument)
+	r.GET("/consent-documents", mw.Require(rbac.UsersRead), o.UserHandler.FetchConsentDocuments)
+	r.GET("/consent-documents/:document_id", mw.Require(rbac.UsersRead), o.UserHandler.FetchConsentDocument)
+
+	r.GET("/metadata/schemas", mw.Require(rbac.UsersRead), o.UserHandler.FetchMetadataSchemas)
+	r.GET("/metadata/schemas/:namespace/:version", mw.Require(rbac.UsersRead), o.UserHandler.FetchMetadataSchema)
+	r.PUT("/metadata/schemas/:namespace/:version", mw.Require(rbac.MetadataWrite), o.UserHandler.SaveMetadataSchema)
 }
@@ -1,8 +1,14 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"gouser/internal/server/handler"
+	"gouser/internal/server/mw"
+	"gouser/pkg/blob"
+	"gouser/pkg/rbac"
+	"gouser/pkg/tenant"
+	"net"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,20 +34,47 @@ const (
 type Options struct {
 	fx.In
 
-	Config *viper.Viper
-	Log    *logrus.Logger
+	Lifecycle fx.Lifecycle
+	Config    *viper.Viper
+	Log       *logrus.Logger
 
 	PostgresDB *pg.DB `name:"gouserDB"`
 
-	UserHandler *handler.UserHandler
+	UserHandler   *handler.UserHandler
+	RBACHandler   *handler.RBACHandler
+	TenantHandler *handler.TenantHandler
+	RBAC          *rbac.Service
+	Tenants       *tenant.Service
+	Blobs         blob.Store
 }
 
-// Run starts the mainserver REST API server
+// Run starts the mainserver REST API server when the app starts and shuts it down gracefully when it stops.
+// The server is started from a start hook so that the invocations of the modules after this one still run.
 func Run(o Options) {
-	router := SetupRouter(&o)
-	router.Run(fmt.Sprintf("%s:%s", addr, o.Config.GetString("port")))
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", addr, o.Config.GetString("port")),
+		Handler: SetupRouter(&o),
+	}
 
-	return
+	o.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			// the port is bound before the app is started so that an address in use fails the start
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					o.Log.WithField("error", err.Error()).Error("server stopped")
+				}
+			}()
+			o.Log.WithField("addr", srv.Addr).Info("server started")
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx)
+		},
+	})
 }
 
 // SetupRouter creates gin router and registers all user routes to it
@@ -56,11 +89,17 @@ func SetupRouter(o *Options) (router *gin.Engine) {
 
 	// Logs all panic to error log
 	router.Use(ginlogrus.Logger(o.Log), gin.Recovery())
+	router.Use(mw.RequestID())
 
 	// Health routes
 	router.GET("/_healthz", HealthHandler(o))
 	router.GET("/_readyz", HealthHandler(o))
 
+	// files of the local blob store, eg. profile pictures
+	if local, ok := o.Blobs.(*blob.LocalStore); ok {
+		router.Static(blob.LocalRoute, local.Dir())
+	}
+
 	rootRouter := router.Group("/")
 
 	v1Routes(rootRouter, o)
@@ -0,0 +1,156 @@
+// Package worker runs the periodic background jobs of the application
+package worker
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+	"gouser/pkg/user"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// Module invokes the worker
+var Module = fx.Options(
+	fx.Invoke(
+		Run,
+	),
+)
+
+// Options is function arguments struct of `Run` function.
+type Options struct {
+	fx.In
+
+	Config    *viper.Viper
+	Log       *logrus.Logger
+	Lifecycle fx.Lifecycle
+
+	UserService   *user.Service
+	TenantService *tenant.Service
+}
+
+// job is a unit of work run at every worker interval
+type job struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Run starts running all jobs at every `worker_interval` until the app is stopped
+func Run(o Options) {
+	jobs := []job{
+		{name: "erasure", run: func(ctx context.Context) error {
+			erased, err := o.UserService.ProcessDueErasures(ctx)
+			if erased > 0 {
+				o.Log.WithField("erased", erased).Info("due erasures processed")
+			}
+			return err
+		}},
+		{name: "picture_uploads", run: func(ctx context.Context) error {
+			collected, err := o.UserService.CollectPictureUploads(ctx)
+			if collected > 0 {
+				o.Log.WithField("collected", collected).Info("abandoned picture uploads deleted")
+			}
+			return err
+		}},
+		{name: "duplicates", run: detectDuplicates(o)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	o.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go loop(ctx, o, jobs, done)
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+// detectDuplicates returns the job detecting the duplicate users of the tenants which requested a detection,
+// and of every tenant at most once every `duplicate_detection_interval`
+func detectDuplicates(o Options) func(ctx context.Context) error {
+	var lastRun time.Time
+	return func(ctx context.Context) error {
+		requests, err := o.UserService.FetchDuplicateDetectionRequests(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range requests {
+			if err = detectTenantDuplicates(ctx, o, requests[i].TenantID); err != nil {
+				return err
+			}
+			if err = o.UserService.CompleteDuplicateDetectionRequest(ctx, &requests[i]); err != nil {
+				return err
+			}
+		}
+
+		if time.Since(lastRun) < o.Config.GetDuration("duplicate_detection_interval") {
+			return nil
+		}
+		tenants, err := o.TenantService.FetchTenants(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range tenants {
+			if err = detectTenantDuplicates(ctx, o, t.ID); err != nil {
+				return err
+			}
+		}
+		lastRun = time.Now()
+		return nil
+	}
+}
+
+// detectTenantDuplicates detects the duplicate users of the tenant
+func detectTenantDuplicates(ctx context.Context, o Options, tenantID int) error {
+	detection, err := o.UserService.DetectDuplicates(tenant.WithID(ctx, tenantID))
+	if err != nil {
+		return err
+	}
+	o.Log.WithFields(logrus.Fields{
+		"tenant_id":      tenantID,
+		"users":          detection.Users,
+		"pairs":          detection.Pairs,
+		"skipped_blocks": detection.SkippedBlocks,
+	}).Info("duplicate users detected")
+	return nil
+}
+
+func loop(ctx context.Context, o Options, jobs []job, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(o.Config.GetDuration("worker_interval"))
+	defer ticker.Stop()
+
+	// jobs process the rows of all tenants
+	ctx = tenant.Unscoped(ctx)
+	for {
+		for _, j := range jobs {
+			if err := j.run(ctx); err != nil && ctx.Err() == nil {
+				sentry.CaptureException(err)
+				o.Log.WithFields(logrus.Fields{
+					"job":   j.name,
+					"error": err.Error(),
+				}).Error("worker job failed")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "user" (
+    "id" bigserial,
+    "first_name" text,
+    "last_name" text,
+    "mobile" text UNIQUE,
+    "profile_picture" text,
+    "dob" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "metadata" jsonb,
+    PRIMARY KEY ("id")
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "user_phones" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "number" text NOT NULL UNIQUE,
+    "label" text,
+    "is_primary" boolean NOT NULL,
+    "verified_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
+
+-- the mobile of existing users becomes their primary phone
+INSERT INTO "user_phones" ("user_id", "number", "label", "is_primary", "created_at", "updated_at")
+SELECT u."id", u."mobile", 'primary', TRUE, now(), now()
+FROM "user" u
+WHERE u."mobile" <> ''
+    AND NOT EXISTS (SELECT 1 FROM "user_phones" p WHERE p."user_id" = u."id" AND p."is_primary")
+    AND NOT EXISTS (SELECT 1 FROM "user_phones" p WHERE p."number" = u."mobile");
@@ -0,0 +1,9 @@
+ALTER TABLE "user_phones"
+    DROP COLUMN IF EXISTS "country_code",
+    DROP COLUMN IF EXISTS "national_number",
+    DROP COLUMN IF EXISTS "region",
+    DROP COLUMN IF EXISTS "line_type";
+
+ALTER TABLE "user"
+    DROP COLUMN IF EXISTS "country_code",
+    DROP COLUMN IF EXISTS "national_number";
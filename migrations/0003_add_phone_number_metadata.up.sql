@@ -0,0 +1,9 @@
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS "country_code" bigint,
+    ADD COLUMN IF NOT EXISTS "national_number" text;
+
+ALTER TABLE "user_phones"
+    ADD COLUMN IF NOT EXISTS "country_code" bigint,
+    ADD COLUMN IF NOT EXISTS "national_number" text,
+    ADD COLUMN IF NOT EXISTS "region" text,
+    ADD COLUMN IF NOT EXISTS "line_type" text;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "user_emails" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "address" text NOT NULL,
+    "is_primary" boolean NOT NULL,
+    "verified_at" timestamptz,
+    "verification_token_hash" text,
+    "verification_sent_at" timestamptz,
+    "verification_expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "user_emails_address_key" ON "user_emails" (lower("address"));
+CREATE UNIQUE INDEX IF NOT EXISTS "user_emails_verification_token_hash_key" ON "user_emails" ("verification_token_hash");
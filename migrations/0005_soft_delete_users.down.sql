@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "user_phones_number_key";
+ALTER TABLE "user_phones" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "user_phones" ADD CONSTRAINT "user_phones_number_key" UNIQUE ("number");
+
+DROP INDEX IF EXISTS "user_mobile_key";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "user" ADD CONSTRAINT "user_mobile_key" UNIQUE ("mobile");
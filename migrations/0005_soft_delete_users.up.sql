@@ -0,0 +1,9 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+ALTER TABLE "user_phones" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+
+-- mobile numbers are unique only among users which are not deleted
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS "user_mobile_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_mobile_key" ON "user" ("mobile") WHERE "deleted_at" IS NULL;
+
+ALTER TABLE "user_phones" DROP CONSTRAINT IF EXISTS "user_phones_number_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_phones_number_key" ON "user_phones" ("number") WHERE "deleted_at" IS NULL;
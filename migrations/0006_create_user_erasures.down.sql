@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "user_erasures";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "erased_at";
@@ -0,0 +1,20 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "erased_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "user_erasures" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "requested_by" text,
+    "reason" text,
+    "requested_at" timestamptz,
+    "scheduled_for" timestamptz NOT NULL,
+    "cancelled_at" timestamptz,
+    "completed_at" timestamptz,
+    "receipt" jsonb,
+    "receipt_hash" text,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+);
+
+-- a user can have only one pending erasure
+CREATE UNIQUE INDEX IF NOT EXISTS "user_erasures_pending_key" ON "user_erasures" ("user_id")
+    WHERE "cancelled_at" IS NULL AND "completed_at" IS NULL;
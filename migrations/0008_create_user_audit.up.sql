@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "user_audit" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "action" text NOT NULL,
+    "actor" text,
+    "request_id" text,
+    "source_ip" text,
+    "changes" jsonb NOT NULL DEFAULT '[]',
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "user_audit_user_id_created_at_idx" ON "user_audit" ("user_id", "created_at" DESC);
+
+-- history filter by field, eg. changes @> '[{"field": "mobile"}]'
+CREATE INDEX IF NOT EXISTS "user_audit_changes_idx" ON "user_audit" USING GIN ("changes" jsonb_path_ops);
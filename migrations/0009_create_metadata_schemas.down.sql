@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "metadata_schemas";
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS "metadata_schema_version";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "metadata_namespace";
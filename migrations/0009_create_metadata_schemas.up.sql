@@ -0,0 +1,13 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "metadata_namespace" text;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "metadata_schema_version" integer;
+
+CREATE TABLE IF NOT EXISTS "metadata_schemas" (
+    "id" serial,
+    "namespace" text NOT NULL,
+    "version" integer NOT NULL CHECK ("version" > 0),
+    "schema" jsonb NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    UNIQUE ("namespace", "version")
+);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS "user_addresses" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "label" text,
+    "line1" text NOT NULL,
+    "line2" text,
+    "city" text NOT NULL,
+    "state" text,
+    "postal_code" text,
+    "country" char(2) NOT NULL,
+    "is_default" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "user_addresses_user_id_idx" ON "user_addresses" ("user_id");
+
+-- a user has at most one default address
+CREATE UNIQUE INDEX IF NOT EXISTS "user_addresses_default_key" ON "user_addresses" ("user_id") WHERE "is_default";
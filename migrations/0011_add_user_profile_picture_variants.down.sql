@@ -0,0 +1,2 @@
+ALTER TABLE "user" DROP COLUMN IF EXISTS "profile_picture_key";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "profile_picture_variants";
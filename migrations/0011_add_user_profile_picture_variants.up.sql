@@ -0,0 +1,2 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "profile_picture_variants" jsonb;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "profile_picture_key" text;
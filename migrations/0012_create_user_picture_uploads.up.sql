@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "user_picture_uploads" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "key" text NOT NULL,
+    "created_at" timestamptz,
+    "url_expires_at" timestamptz NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "finalized_at" timestamptz,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "user_picture_uploads_user_id_idx" ON "user_picture_uploads" ("user_id");
+CREATE INDEX IF NOT EXISTS "user_picture_uploads_expires_at_idx" ON "user_picture_uploads" ("expires_at");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_status_changes";
+DROP INDEX IF EXISTS "user_status_idx";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "status";
@@ -0,0 +1,18 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "status" text NOT NULL DEFAULT 'active';
+
+CREATE INDEX IF NOT EXISTS "user_status_idx" ON "user" ("status");
+
+CREATE TABLE IF NOT EXISTS "user_status_changes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "from_status" text NOT NULL,
+    "to_status" text NOT NULL,
+    "reason" text NOT NULL,
+    "actor" text NOT NULL,
+    "request_id" text,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "user_status_changes_user_id_created_at_idx" ON "user_status_changes" ("user_id", "created_at" DESC);
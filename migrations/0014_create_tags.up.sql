@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "tags" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name")
+);
+
+CREATE TABLE IF NOT EXISTS "user_tags" (
+    "user_id" bigint NOT NULL,
+    "tag_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("user_id", "tag_id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("tag_id") REFERENCES "tags" ("id") ON DELETE CASCADE
+);
+
+-- tag filters and usage counts look up users by tag
+CREATE INDEX IF NOT EXISTS "user_tags_tag_id_idx" ON "user_tags" ("tag_id");
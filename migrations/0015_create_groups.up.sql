@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS "groups" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "group_members" (
+    "group_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "role" text NOT NULL,
+    "joined_at" timestamptz,
+    PRIMARY KEY ("group_id", "user_id"),
+    FOREIGN KEY ("group_id") REFERENCES "groups" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
+
+-- groups of a user
+CREATE INDEX IF NOT EXISTS "group_members_user_id_idx" ON "group_members" ("user_id");
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS "rbac_roles" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    "permissions" text[] NOT NULL DEFAULT '{}',
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "key_prefix" text NOT NULL,
+    "key_hash" text NOT NULL,
+    "created_at" timestamptz,
+    "revoked_at" timestamptz,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name"),
+    UNIQUE ("key_hash")
+);
+
+CREATE TABLE IF NOT EXISTS "api_key_roles" (
+    "api_key_id" bigint NOT NULL,
+    "role_id" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("api_key_id", "role_id"),
+    FOREIGN KEY ("api_key_id") REFERENCES "api_keys" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("role_id") REFERENCES "rbac_roles" ("id") ON DELETE CASCADE
+);
+
+-- built-in roles, they can be changed or deleted like any other role
+INSERT INTO "rbac_roles" ("name", "description", "permissions", "created_at", "updated_at") VALUES
+    ('admin', 'Full access including roles and API keys', '{metadata:write,rbac:admin,users:erase,users:read,users:read_pii,users:write}', now(), now()),
+    ('editor', 'Read and change users', '{users:read,users:read_pii,users:write}', now(), now()),
+    ('support', 'Read users including their PII', '{users:read,users:read_pii}', now(), now()),
+    ('reader', 'Read users with their PII masked', '{users:read}', now(), now())
+ON CONFLICT ("name") DO NOTHING;
@@ -0,0 +1,36 @@
+UPDATE "rbac_roles" SET "permissions" = array_remove("permissions", 'tenants:admin');
+
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "tenant_id";
+
+-- fails if tenants have registered the same mobiles, emails, tags or schemas
+DROP INDEX IF EXISTS "metadata_schemas_tenant_id_namespace_version_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "metadata_schemas_namespace_version_key" ON "metadata_schemas" ("namespace", "version");
+
+DROP INDEX IF EXISTS "tags_tenant_id_name_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "tags_name_key" ON "tags" ("name");
+
+DROP INDEX IF EXISTS "user_emails_address_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_emails_address_key" ON "user_emails" (lower("address"));
+
+DROP INDEX IF EXISTS "user_phones_number_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_phones_number_key" ON "user_phones" ("number") WHERE "deleted_at" IS NULL;
+
+DROP INDEX IF EXISTS "user_mobile_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_mobile_key" ON "user" ("mobile") WHERE "deleted_at" IS NULL;
+
+DROP INDEX IF EXISTS "user_tenant_id_idx";
+ALTER TABLE "group_members" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "groups" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_tags" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "tags" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "metadata_schemas" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_status_changes" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_picture_uploads" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_audit" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_erasures" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_addresses" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_emails" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_phones" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "tenant_id";
+
+DROP TABLE IF EXISTS "tenants";
@@ -0,0 +1,94 @@
+CREATE TABLE IF NOT EXISTS "tenants" (
+    "id" bigserial,
+    "slug" text NOT NULL,
+    "name" text NOT NULL,
+    "hosts" text[] NOT NULL DEFAULT '{}',
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    UNIQUE ("slug")
+);
+
+-- tenants are looked up by the host of requests
+CREATE INDEX IF NOT EXISTS "tenants_hosts_idx" ON "tenants" USING GIN ("hosts");
+
+-- existing rows belong to the default tenant
+INSERT INTO "tenants" ("slug", "name", "created_at", "updated_at") VALUES ('default', 'Default', now(), now())
+ON CONFLICT ("slug") DO NOTHING;
+
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_phones" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_phones" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_phones" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_emails" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_emails" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_emails" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_addresses" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_addresses" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_addresses" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_erasures" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_erasures" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_erasures" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_audit" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_audit" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_audit" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_picture_uploads" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_picture_uploads" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_picture_uploads" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_status_changes" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_status_changes" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_status_changes" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "metadata_schemas" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "metadata_schemas" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "metadata_schemas" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "tags" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "tags" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "tags" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "user_tags" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "user_tags" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_tags" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "groups" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "groups" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "groups" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+ALTER TABLE "group_members" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id");
+UPDATE "group_members" SET "tenant_id" = (SELECT "id" FROM "tenants" WHERE "slug" = 'default') WHERE "tenant_id" IS NULL;
+ALTER TABLE "group_members" ALTER COLUMN "tenant_id" SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "user_tenant_id_idx" ON "user" ("tenant_id", "id");
+
+-- mobiles, phone numbers, emails, tags and metadata schemas are unique per tenant
+DROP INDEX IF EXISTS "user_mobile_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_mobile_key" ON "user" ("tenant_id", "mobile") WHERE "deleted_at" IS NULL;
+
+DROP INDEX IF EXISTS "user_phones_number_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_phones_number_key" ON "user_phones" ("tenant_id", "number") WHERE "deleted_at" IS NULL;
+
+DROP INDEX IF EXISTS "user_emails_address_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "user_emails_address_key" ON "user_emails" ("tenant_id", lower("address"));
+
+ALTER TABLE "tags" DROP CONSTRAINT IF EXISTS "tags_name_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "tags_tenant_id_name_key" ON "tags" ("tenant_id", "name");
+
+ALTER TABLE "metadata_schemas" DROP CONSTRAINT IF EXISTS "metadata_schemas_namespace_version_key";
+CREATE UNIQUE INDEX IF NOT EXISTS "metadata_schemas_tenant_id_namespace_version_key"
+    ON "metadata_schemas" ("tenant_id", "namespace", "version");
+
+-- API keys of a tenant only access that tenant
+ALTER TABLE "api_keys" ADD COLUMN IF NOT EXISTS "tenant_id" bigint REFERENCES "tenants" ("id") ON DELETE CASCADE;
+
+UPDATE "rbac_roles" SET "permissions" = array_append("permissions", 'tenants:admin')
+WHERE "name" = 'admin' AND NOT 'tenants:admin' = ANY ("permissions");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "user_referred_by_idx";
+DROP INDEX IF EXISTS "user_referral_code_key";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "referred_at";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "referred_by";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "referral_code";
@@ -0,0 +1,32 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "referral_code" text;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "referred_by" bigint REFERENCES "user" ("id");
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "referred_at" timestamptz;
+
+-- existing users get a random code of the alphabet of `referralCodeAlphabet`, codes taken twice are drawn again
+CREATE OR REPLACE FUNCTION "gouser_referral_code"() RETURNS text AS $$
+    SELECT string_agg(substr('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', floor(random() * 32)::int + 1, 1), '')
+    FROM generate_series(1, 8)
+$$ LANGUAGE sql VOLATILE;
+
+UPDATE "user" SET "referral_code" = "gouser_referral_code"() WHERE "referral_code" IS NULL;
+
+DO $$
+BEGIN
+    LOOP
+        UPDATE "user" SET "referral_code" = "gouser_referral_code"()
+        WHERE "id" IN (
+            SELECT "id" FROM (
+                SELECT "id", row_number() OVER (PARTITION BY "tenant_id", "referral_code" ORDER BY "id") AS "n" FROM "user"
+            ) AS "codes" WHERE "n" > 1
+        );
+        EXIT WHEN NOT FOUND;
+    END LOOP;
+END
+$$;
+
+DROP FUNCTION "gouser_referral_code"();
+
+ALTER TABLE "user" ALTER COLUMN "referral_code" SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "user_referral_code_key" ON "user" ("tenant_id", "referral_code");
+CREATE INDEX IF NOT EXISTS "user_referred_by_idx" ON "user" ("referred_by");
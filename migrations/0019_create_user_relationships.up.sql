@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "user_relationships" (
+    "id" bigserial,
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "type" text NOT NULL,
+    "user_id" bigint NOT NULL,
+    "related_user_id" bigint NOT NULL,
+    "status" text NOT NULL,
+    "metadata" jsonb,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "accepted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("related_user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    CHECK ("user_id" <> "related_user_id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "user_relationships_type_user_id_related_user_id_key"
+    ON "user_relationships" ("type", "user_id", "related_user_id");
+-- relationships to a user
+CREATE INDEX IF NOT EXISTS "user_relationships_related_user_id_idx" ON "user_relationships" ("related_user_id");
@@ -0,0 +1,3 @@
+UPDATE "rbac_roles" SET "permissions" = array_remove("permissions", 'users:merge');
+DROP TABLE IF EXISTS "user_merges";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "merged_into";
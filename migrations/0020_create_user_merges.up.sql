@@ -0,0 +1,22 @@
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "merged_into" bigint REFERENCES "user" ("id");
+
+CREATE TABLE IF NOT EXISTS "user_merges" (
+    "id" bigserial,
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "survivor_id" bigint NOT NULL,
+    "merged_user_id" bigint NOT NULL,
+    "precedence" jsonb NOT NULL DEFAULT '{}',
+    "taken_fields" text[] NOT NULL DEFAULT '{}',
+    "moved" jsonb NOT NULL DEFAULT '{}',
+    "actor" text,
+    "request_id" text,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("survivor_id") REFERENCES "user" ("id"),
+    FOREIGN KEY ("merged_user_id") REFERENCES "user" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "user_merges_survivor_id_idx" ON "user_merges" ("survivor_id");
+
+UPDATE "rbac_roles" SET "permissions" = array_append("permissions", 'users:merge')
+WHERE "name" = 'admin' AND NOT 'users:merge' = ANY ("permissions");
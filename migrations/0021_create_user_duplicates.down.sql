@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "user_phones_national_number_idx";
+DROP INDEX IF EXISTS "user_dob_idx";
+DROP TABLE IF EXISTS "user_duplicates";
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "user_duplicates" (
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "user_id" bigint NOT NULL,
+    "duplicate_user_id" bigint NOT NULL,
+    "score" double precision NOT NULL,
+    "reasons" text[] NOT NULL DEFAULT '{}',
+    "detected_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("user_id", "duplicate_user_id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("duplicate_user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    CHECK ("user_id" < "duplicate_user_id")
+);
+
+CREATE INDEX IF NOT EXISTS "user_duplicates_tenant_id_score_idx" ON "user_duplicates" ("tenant_id", "score");
+-- candidates of duplicate checks of new users, by phone and by date of birth
+CREATE INDEX IF NOT EXISTS "user_phones_national_number_idx" ON "user_phones" ("national_number") WHERE "deleted_at" IS NULL;
+CREATE INDEX IF NOT EXISTS "user_dob_idx" ON "user" ("tenant_id", "dob") WHERE "deleted_at" IS NULL;
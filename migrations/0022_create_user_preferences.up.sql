@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "user_preferences" (
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "user_id" bigint NOT NULL,
+    "key" text NOT NULL,
+    "value" jsonb NOT NULL,
+    "updated_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("user_id", "key"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+UPDATE "rbac_roles" SET "permissions" = array_remove("permissions", 'consents:write');
+DROP TABLE IF EXISTS "consent_events";
+DROP TABLE IF EXISTS "consent_documents";
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS "consent_documents" (
+    "id" bigserial,
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "purpose" text NOT NULL,
+    "version" int NOT NULL,
+    "title" text NOT NULL,
+    "url" text,
+    "content" text NOT NULL,
+    "content_hash" text NOT NULL,
+    "published_at" timestamptz NOT NULL DEFAULT now(),
+    "published_by" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "consent_documents_tenant_id_purpose_version_key"
+    ON "consent_documents" ("tenant_id", "purpose", "version");
+
+CREATE TABLE IF NOT EXISTS "consent_events" (
+    "id" bigserial,
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id"),
+    "user_id" bigint NOT NULL,
+    "document_id" bigint NOT NULL REFERENCES "consent_documents" ("id"),
+    "purpose" text NOT NULL,
+    "version" int NOT NULL,
+    "action" text NOT NULL,
+    "ip_address" text,
+    "user_agent" text,
+    "actor" text,
+    "request_id" text,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE,
+    CHECK ("action" IN ('grant', 'withdraw'))
+);
+
+-- latest event of a user per purpose, and users by consent
+CREATE INDEX IF NOT EXISTS "consent_events_user_id_purpose_id_idx" ON "consent_events" ("user_id", "purpose", "id");
+CREATE INDEX IF NOT EXISTS "consent_events_tenant_id_purpose_idx" ON "consent_events" ("tenant_id", "purpose");
+
+UPDATE "rbac_roles" SET "permissions" = array_append("permissions", 'consents:write')
+WHERE "name" = 'admin' AND NOT 'consents:write' = ANY ("permissions");
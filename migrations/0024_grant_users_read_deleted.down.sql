@@ -0,0 +1 @@
+UPDATE "rbac_roles" SET "permissions" = array_remove("permissions", 'users:read_deleted');
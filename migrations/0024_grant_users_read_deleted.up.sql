@@ -0,0 +1,3 @@
+-- soft deleted users are read with `include_deleted=true` by admins only
+UPDATE "rbac_roles" SET "permissions" = array_append("permissions", 'users:read_deleted')
+WHERE "name" = 'admin' AND NOT 'users:read_deleted' = ANY ("permissions");
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "user_merged_into_idx";
+
+ALTER TABLE "consent_events" DROP CONSTRAINT IF EXISTS "consent_events_user_id_fkey";
+ALTER TABLE "consent_events" ADD CONSTRAINT "consent_events_user_id_fkey"
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE;
+
+DROP TRIGGER IF EXISTS "consent_events_immutable" ON "consent_events";
+DROP FUNCTION IF EXISTS "consent_events_immutable"();
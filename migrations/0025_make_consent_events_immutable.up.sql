@@ -0,0 +1,29 @@
+-- consent events prove which account consented, they are never changed or deleted. Erasure of the user
+-- only clears the IP address and user agent of its events.
+CREATE OR REPLACE FUNCTION "consent_events_immutable"() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND NEW."ip_address" IS NULL AND NEW."user_agent" IS NULL
+        AND (NEW."id", NEW."tenant_id", NEW."user_id", NEW."document_id", NEW."purpose", NEW."version",
+             NEW."action", NEW."actor", NEW."request_id", NEW."created_at")
+            IS NOT DISTINCT FROM
+            (OLD."id", OLD."tenant_id", OLD."user_id", OLD."document_id", OLD."purpose", OLD."version",
+             OLD."action", OLD."actor", OLD."request_id", OLD."created_at")
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'consent event % is immutable', OLD."id" USING ERRCODE = 'integrity_constraint_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "consent_events_immutable" ON "consent_events";
+CREATE TRIGGER "consent_events_immutable" BEFORE UPDATE OR DELETE ON "consent_events"
+    FOR EACH ROW EXECUTE PROCEDURE "consent_events_immutable"();
+
+-- users with consent events are never deleted, deleted and erased users are kept as tombstones
+ALTER TABLE "consent_events" DROP CONSTRAINT IF EXISTS "consent_events_user_id_fkey";
+ALTER TABLE "consent_events" ADD CONSTRAINT "consent_events_user_id_fkey"
+    FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE RESTRICT;
+
+-- the consent of a user is made of its events and the events of the users merged into it
+CREATE INDEX IF NOT EXISTS "user_merged_into_idx" ON "user" ("merged_into") WHERE "merged_into" IS NOT NULL;
@@ -0,0 +1,6 @@
+-- detections requested through the API are run by the worker, one pending request per tenant
+CREATE TABLE IF NOT EXISTS "duplicate_detection_requests" (
+    "tenant_id" bigint NOT NULL REFERENCES "tenants" ("id") ON DELETE CASCADE,
+    "requested_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("tenant_id")
+);
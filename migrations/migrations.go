@@ -0,0 +1,11 @@
+// Package migrations embeds the versioned SQL migrations of the database schema.
+// Every migration is a pair of files `<version>_<name>.up.sql` and `<version>_<name>.down.sql`.
+// Create new migrations with `MODE=migrate go run . create <name>`
+package migrations
+
+import "embed"
+
+// FS contains all migration files
+//
+//go:embed *.sql
+var FS embed.FS
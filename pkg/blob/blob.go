@@ -0,0 +1,96 @@
+// Package blob stores files such as user uploads.
+// Stores are pluggable, `BLOB_DRIVER` selects the store used by the application.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// Module provides the configured blob store
+var Module = fx.Options(
+	fx.Provide(
+		New,
+	),
+)
+
+// Blob drivers
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// LocalRoute is the route the API server serves the files of the local store at
+const LocalRoute = "/files"
+
+// ErrInvalidKey is returned for keys which are empty, absolute or escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// ErrNotFound is returned when the file of a key does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Info describes a stored file
+type Info struct {
+	Size int64
+}
+
+// Store stores files by key, eg. `users/1/avatar/3f2a/256.jpg`.
+// Keys are slash separated paths.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the file, the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix deletes all files under the prefix, eg. `users/1/`
+	DeletePrefix(ctx context.Context, prefix string) error
+	// URL returns the URL the file is served at
+	URL(key string) string
+}
+
+// Presigner is implemented by stores which clients can upload files to directly
+type Presigner interface {
+	// PresignPut returns a URL the file of the key can be uploaded to with a PUT request until it expires
+	PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// New returns the blob store selected by `blob_driver` config
+func New(conf *viper.Viper, log *logrus.Logger) (Store, error) {
+	switch conf.GetString("blob_driver") {
+	case DriverLocal, "":
+		return NewLocalStore(conf.GetString("blob_local_dir"), conf.GetString("blob_base_url"))
+	case DriverS3:
+		return NewS3Store(S3Options{
+			Endpoint:  conf.GetString("s3_endpoint"),
+			Region:    conf.GetString("s3_region"),
+			Bucket:    conf.GetString("s3_bucket"),
+			AccessKey: conf.GetString("s3_access_key"),
+			SecretKey: conf.GetString("s3_secret_key"),
+			UseSSL:    conf.GetBool("s3_use_ssl"),
+			BaseURL:   conf.GetString("s3_base_url"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", conf.GetString("blob_driver"))
+	}
+}
+
+// validKey reports whether the key is a relative slash separated path without `.` or `..` elements
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore stores files in a directory of the local filesystem.
+// The API server serves the directory at `LocalRoute`, so `baseURL` is the public URL of that route.
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore returns a store writing files under dir, creating the directory if needed
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Dir returns the directory files are stored in
+func (s *LocalStore) Dir() string {
+	return s.dir
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (err error) {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+
+	// written to a temporary file first so that a partial file is never served
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (info Info, err error) {
+	if !validKey(key) {
+		return info, ErrInvalidKey
+	}
+	fi, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) || (err == nil && fi.IsDir()) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return
+	}
+	info.Size = fi.Size()
+	return
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStore) DeletePrefix(ctx context.Context, prefix string) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !validKey(prefix) {
+		return ErrInvalidKey
+	}
+	return os.RemoveAll(filepath.Join(s.dir, filepath.FromSlash(prefix)))
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
@@ -0,0 +1,156 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configures an `S3Store`
+type S3Options struct {
+	// Endpoint is the host of the S3 API, eg. `s3.amazonaws.com` or `127.0.0.1:9000` for a local MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// BaseURL is the public URL of the bucket, eg. a CDN. Files are served from the endpoint if empty.
+	BaseURL string
+}
+
+// S3Store stores files in a bucket of an S3 compatible object storage, eg. AWS S3 or MinIO.
+// Clients can upload files to the bucket directly with pre-signed URLs.
+type S3Store struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Store returns a store writing files to the bucket. The bucket must exist.
+func NewS3Store(o S3Options) (*S3Store, error) {
+	if o.Endpoint == "" || o.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	client, err := minio.New(o.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(o.AccessKey, o.SecretKey, ""),
+		Secure: o.UseSSL,
+		// with a region the bucket location is never looked up, so that URLs are signed without a request
+		Region: o.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := strings.TrimSuffix(o.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = client.EndpointURL().String() + "/" + o.Bucket
+	}
+	return &S3Store{client: client, bucket: o.Bucket, baseURL: baseURL}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	// the object is only requested on first use, so a missing object is detected here
+	if _, err = obj.Stat(); err != nil {
+		obj.Close()
+		return nil, s3Error(err)
+	}
+	return obj, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (info Info, err error) {
+	if !validKey(key) {
+		return info, ErrInvalidKey
+	}
+	obj, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return info, s3Error(err)
+	}
+	info.Size = obj.Size
+	return
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	// deleting a missing object succeeds
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !validKey(prefix) {
+		return ErrInvalidKey
+	}
+
+	var listErr error
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		opts := minio.ListObjectsOptions{Prefix: prefix + "/", Recursive: true}
+		for obj := range s.client.ListObjects(ctx, s.bucket, opts) {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			select {
+			case objects <- obj:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for rErr := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if err == nil {
+			err = rErr.Err
+		}
+	}
+	if err == nil {
+		err = listErr
+	}
+	return
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *S3Store) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, key, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// s3Error returns `ErrNotFound` for errors of missing objects
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
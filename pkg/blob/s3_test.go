@@ -0,0 +1,322 @@
+package blob
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBucket = "uploads"
+
+// s3StandIn is an in-memory stand-in of a MinIO server serving the path-style requests of one bucket
+// made by `S3Store`, signatures are not checked
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string]s3StandInObject
+}
+
+type s3StandInObject struct {
+	body        []byte
+	contentType string
+}
+
+func newS3StandIn(t *testing.T) (*s3StandIn, *httptest.Server) {
+	s := &s3StandIn{objects: map[string]s3StandInObject{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if bucket != testBucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r.URL.Query().Get("prefix"))
+	case key == "" && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		s.deleteMany(w, r)
+	case r.Method == http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			s.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = s3StandInObject{body: body, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(body))+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"`+strconv.Itoa(len(obj.body))+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.body)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *s3StandIn) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (s *s3StandIn) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key  string
+		Size int
+	}
+	res := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []content
+	}{Name: testBucket, Prefix: prefix}
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			res.Contents = append(res.Contents, content{Key: key, Size: len(obj.body)})
+		}
+	}
+	sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+	res.KeyCount = len(res.Contents)
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+func (s *s3StandIn) deleteMany(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}{}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	for _, obj := range req.Objects {
+		delete(s.objects, obj.Key)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><DeleteResult></DeleteResult>`)
+}
+
+func (s *s3StandIn) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readS3Body reads the body of a PUT request, decoding the aws-chunked encoding of streaming signatures
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var body bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err = io.CopyN(&body, br, size); err != nil {
+			return nil, err
+		}
+		if _, err = br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newTestS3Store(t *testing.T, srv *httptest.Server, baseURL string) *S3Store {
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewS3Store(S3Options{
+		Endpoint:  u.Host,
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		BaseURL:   baseURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3Store(t *testing.T) {
+	standIn, srv := newS3StandIn(t)
+	store := newTestS3Store(t, srv, "")
+	ctx := context.Background()
+
+	content := []byte("picture")
+	if err := store.Put(ctx, "users/1/avatar/64.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "users/1/avatar/256.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "users/2/avatar/64.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := store.Stat(ctx, "users/1/avatar/64.jpg")
+	if err != nil || info.Size != int64(len(content)) {
+		t.Fatalf("Stat = %+v, %v, want size %d", info, err, len(content))
+	}
+
+	rc, err := store.Get(ctx, "users/1/avatar/64.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Get read %q, %v, want %q", got, err, content)
+	}
+
+	if _, err = store.Get(ctx, "users/1/missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key = %v, want ErrNotFound", err)
+	}
+	if _, err = store.Stat(ctx, "users/1/missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat of a missing key = %v, want ErrNotFound", err)
+	}
+
+	if err = store.Delete(ctx, "users/2/avatar/64.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err = store.Delete(ctx, "users/2/avatar/64.jpg"); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+	if err = store.Put(ctx, "users/10/avatar/64.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// the prefix is a directory, users/10 is not under users/1
+	if err = store.DeletePrefix(ctx, "users/1/"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	if keys := standIn.keys(); len(keys) != 1 || keys[0] != "users/10/avatar/64.jpg" {
+		t.Errorf("keys after DeletePrefix = %v, want [users/10/avatar/64.jpg]", keys)
+	}
+}
+
+func TestS3StoreInvalidKeys(t *testing.T) {
+	_, srv := newS3StandIn(t)
+	store := newTestS3Store(t, srv, "")
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/users/1", "users/../../etc"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.PresignPut(ctx, key, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("PresignPut(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestS3StorePresignPut(t *testing.T) {
+	standIn, srv := newS3StandIn(t)
+	store := newTestS3Store(t, srv, "")
+
+	signed, err := store.PresignPut(context.Background(), "uploads/abc/original", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/"+testBucket+"/uploads/abc/original" {
+		t.Errorf("presigned path = %s", u.Path)
+	}
+	if q := u.Query(); q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Expires") != "900" {
+		t.Errorf("presigned query = %s, want a signature expiring in 900s", u.RawQuery)
+	}
+
+	// the client uploads to the URL directly
+	req, _ := http.NewRequest(http.MethodPut, signed, strings.NewReader("raw"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if keys := standIn.keys(); res.StatusCode != http.StatusOK || len(keys) != 1 || keys[0] != "uploads/abc/original" {
+		t.Errorf("upload status %d, keys %v", res.StatusCode, keys)
+	}
+}
+
+func TestS3StoreURL(t *testing.T) {
+	_, srv := newS3StandIn(t)
+
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"", srv.URL + "/" + testBucket + "/users/1/64.jpg"},
+		{"https://cdn.example.com/", "https://cdn.example.com/users/1/64.jpg"},
+	}
+	for _, tt := range tests {
+		if got := newTestS3Store(t, srv, tt.baseURL).URL("users/1/64.jpg"); got != tt.want {
+			t.Errorf("URL with base %q = %s, want %s", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestNewS3StoreRequiresEndpointAndBucket(t *testing.T) {
+	for _, o := range []S3Options{{Bucket: testBucket}, {Endpoint: "127.0.0.1:9000"}} {
+		if _, err := NewS3Store(o); err == nil {
+			t.Errorf("NewS3Store(%+v) succeeded, want an error", o)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogSender only logs the emails instead of sending them.
+// It is meant for local development and testing.
+type LogSender struct {
+	log *logrus.Logger
+}
+
+// NewLogSender returns a sender writing emails to the log
+func NewLogSender(log *logrus.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(ctx context.Context, m *Message) error {
+	s.log.WithContext(ctx).WithFields(logrus.Fields{
+		"to":      m.To,
+		"subject": m.Subject,
+		"body":    m.Body,
+	}).Info("mail sent")
+	return nil
+}
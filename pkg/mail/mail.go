@@ -0,0 +1,55 @@
+// Package mail sends outbound emails.
+// Senders are pluggable, `MAIL_DRIVER` selects the sender used by the application.
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// Module provides the configured mail sender
+var Module = fx.Options(
+	fx.Provide(
+		New,
+	),
+)
+
+// Mail drivers
+const (
+	DriverLog  = "log"
+	DriverSMTP = "smtp"
+)
+
+// Message is an email to be sent
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender sends an email message
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// New returns the mail sender selected by `mail_driver` config
+func New(conf *viper.Viper, log *logrus.Logger) (Sender, error) {
+	switch conf.GetString("mail_driver") {
+	case DriverSMTP:
+		return NewSMTPSender(SMTPOptions{
+			Host:     conf.GetString("smtp_host"),
+			Port:     conf.GetString("smtp_port"),
+			Username: conf.GetString("smtp_username"),
+			Password: conf.GetString("smtp_password"),
+			From:     conf.GetString("mail_from"),
+		}), nil
+	case DriverLog, "":
+		return NewLogSender(log), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", conf.GetString("mail_driver"))
+	}
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPOptions is the SMTP server configuration of `SMTPSender`
+type SMTPOptions struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	o SMTPOptions
+}
+
+// NewSMTPSender returns a sender for the SMTP server
+func NewSMTPSender(o SMTPOptions) *SMTPSender {
+	return &SMTPSender{o: o}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, m *Message) error {
+	var auth smtp.Auth
+	if s.o.Username != "" {
+		auth = smtp.PlainAuth("", s.o.Username, s.o.Password, s.o.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s",
+		s.o.From, strings.Join(m.To, ", "), m.Subject, m.Body)
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- smtp.SendMail(net.JoinHostPort(s.o.Host, s.o.Port), auth, s.o.From, m.To, []byte(msg))
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
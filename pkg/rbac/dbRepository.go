@@ -0,0 +1,191 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	CreateRole(dCtx context.Context, r *Role) error
+	UpdateRole(dCtx context.Context, r *Role) error
+	DeleteRole(dCtx context.Context, roleID int) error
+	FetchRole(dCtx context.Context, roleID int) (role *Role, err error)
+	FetchRoles(dCtx context.Context) (roles []Role, err error)
+	FetchRolesByName(dCtx context.Context, names []string) (roles []Role, err error)
+
+	CreateAPIKey(dCtx context.Context, k *APIKey) error
+	RevokeAPIKey(dCtx context.Context, keyID int, at time.Time) error
+	FetchAPIKey(dCtx context.Context, keyID int) (key *APIKey, err error)
+	FetchAPIKeys(dCtx context.Context) (keys []APIKey, err error)
+	FetchAPIKeyByHash(dCtx context.Context, hash string) (key *APIKey, err error)
+	AssignRole(dCtx context.Context, keyID, roleID int) error
+	UnassignRole(dCtx context.Context, keyID, roleID int) error
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"gouserDB"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns the postgres RBAC repository
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+	return
+}
+
+func (r *PGRepo) CreateRole(ctx context.Context, role *Role) (err error) {
+	_, err = r.db.ModelContext(ctx, role).Insert()
+	return
+}
+
+func (r *PGRepo) UpdateRole(ctx context.Context, role *Role) (err error) {
+	res, err := r.db.ModelContext(ctx, role).
+		Column("name", "description", "permissions", "updated_at").
+		WherePK().
+		Update()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+// DeleteRole deletes the role, API keys having the role lose its permissions
+func (r *PGRepo) DeleteRole(ctx context.Context, roleID int) (err error) {
+	res, err := r.db.ModelContext(ctx, (*Role)(nil)).
+		Where("id = ?", roleID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+func (r *PGRepo) FetchRole(ctx context.Context, roleID int) (role *Role, err error) {
+	role = &Role{ID: roleID}
+	err = r.db.ModelContext(ctx, role).WherePK().Select()
+	return
+}
+
+func (r *PGRepo) FetchRoles(ctx context.Context) (roles []Role, err error) {
+	roles = []Role{}
+	err = r.db.ModelContext(ctx, &roles).Order("name ASC").Select()
+	return
+}
+
+func (r *PGRepo) FetchRolesByName(ctx context.Context, names []string) (roles []Role, err error) {
+	roles = []Role{}
+	err = r.db.ModelContext(ctx, &roles).Where("name IN (?)", pg.In(names)).Select()
+	return
+}
+
+// CreateAPIKey inserts the API key along with the assignments of its roles
+func (r *PGRepo) CreateAPIKey(ctx context.Context, k *APIKey) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tx.ModelContext(ctx, k).Insert(); err != nil {
+			return
+		}
+		for _, role := range k.Roles {
+			assignment := &APIKeyRole{APIKeyID: k.ID, RoleID: role.ID, CreatedAt: k.CreatedAt}
+			if _, err = tx.ModelContext(ctx, assignment).Insert(); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// RevokeAPIKey revokes the API key, requests made with a revoked key are rejected
+func (r *PGRepo) RevokeAPIKey(ctx context.Context, keyID int, at time.Time) (err error) {
+	res, err := r.db.ModelContext(ctx, (*APIKey)(nil)).
+		Set("revoked_at = ?", at).
+		Where("id = ?", keyID).
+		Where("revoked_at IS NULL").
+		Update()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+func (r *PGRepo) FetchAPIKey(ctx context.Context, keyID int) (key *APIKey, err error) {
+	key = &APIKey{ID: keyID}
+	err = r.db.ModelContext(ctx, key).
+		Relation("Roles", orderByName).
+		WherePK().
+		Select()
+	return
+}
+
+func (r *PGRepo) FetchAPIKeys(ctx context.Context) (keys []APIKey, err error) {
+	keys = []APIKey{}
+	err = r.db.ModelContext(ctx, &keys).
+		Relation("Roles", orderByName).
+		Order("api_key.id ASC").
+		Select()
+	return
+}
+
+// FetchAPIKeyByHash fetches the API key, not revoked, of the hash along with its roles
+func (r *PGRepo) FetchAPIKeyByHash(ctx context.Context, hash string) (key *APIKey, err error) {
+	key = &APIKey{}
+	err = r.db.ModelContext(ctx, key).
+		Relation("Roles").
+		Where("api_key.key_hash = ?", hash).
+		Where("api_key.revoked_at IS NULL").
+		Select()
+	return
+}
+
+// AssignRole assigns the role to the API key, assigning a role the key already has succeeds
+func (r *PGRepo) AssignRole(ctx context.Context, keyID, roleID int) (err error) {
+	now := time.Now()
+	_, err = r.db.ModelContext(ctx, &APIKeyRole{APIKeyID: keyID, RoleID: roleID, CreatedAt: &now}).
+		OnConflict("DO NOTHING").
+		Insert()
+	return
+}
+
+func (r *PGRepo) UnassignRole(ctx context.Context, keyID, roleID int) (err error) {
+	res, err := r.db.ModelContext(ctx, (*APIKeyRole)(nil)).
+		Where("api_key_id = ?", keyID).
+		Where("role_id = ?", roleID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+// orderByName orders relations by name, eg. roles
+func orderByName(q *orm.Query) (*orm.Query, error) {
+	return q.Order("name ASC"), nil
+}
@@ -0,0 +1,196 @@
+// Package rbac authenticates API clients by their API keys and authorizes them by the permissions of their roles.
+// Roles, API keys and their assignments are stored in Postgres and managed through the admin API.
+package rbac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.uber.org/fx"
+)
+
+// Module provides the RBAC repository and service
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// Permission allows a kind of request, eg. `users:read`
+type Permission = string
+
+// permissions checked by the API
+const (
+	UsersRead Permission = "users:read"
+	// UsersReadPII allows reading unmasked PII of users, eg. mobile numbers, emails and addresses
+	UsersReadPII Permission = "users:read_pii"
+	// UsersReadDeleted allows reading soft deleted users with `include_deleted=true`
+	UsersReadDeleted Permission = "users:read_deleted"
+	UsersWrite       Permission = "users:write"
+	UsersErase       Permission = "users:erase"
+	MetadataWrite    Permission = "metadata:write"
+	RBACAdmin        Permission = "rbac:admin"
+	TenantsAdmin     Permission = "tenants:admin"
+
+	// UsersMerge allows merging duplicate users, which deletes the merged user
+	UsersMerge Permission = "users:merge"
+
+	// ConsentsWrite allows publishing consent documents, eg. a new version of the privacy policy
+	ConsentsWrite Permission = "consents:write"
+)
+
+// Permissions are all permissions which can be granted to roles
+var Permissions = []Permission{UsersRead, UsersReadPII, UsersReadDeleted, UsersWrite, UsersErase, UsersMerge, MetadataWrite, ConsentsWrite, RBACAdmin, TenantsAdmin}
+
+// platformPermissions manage all tenants, they are never granted to API keys of a tenant
+var platformPermissions = []Permission{RBACAdmin, TenantsAdmin}
+
+func init() {
+	// join table of `APIKey.Roles`
+	orm.RegisterTable((*APIKeyRole)(nil))
+}
+
+type (
+	// Role is a named set of permissions
+	Role struct {
+		tableName   struct{}     `pg:"rbac_roles,discard_unknown_columns"`
+		ID          int          `json:"id" pg:"id"`
+		Name        string       `json:"name" pg:"name,notnull"`
+		Description string       `json:"description" pg:"description"`
+		Permissions []Permission `json:"permissions" pg:"permissions,array,notnull"`
+		CreatedAt   *time.Time   `json:"created_at" pg:"created_at"`
+		UpdatedAt   *time.Time   `json:"updated_at" pg:"updated_at"`
+	}
+
+	// APIKey authenticates an API client. Only the sha256 hash of the key is stored,
+	// the key itself is returned once when the API key is created.
+	// An API key of a tenant only accesses the users of that tenant.
+	APIKey struct {
+		tableName struct{}   `pg:"api_keys,discard_unknown_columns"`
+		ID        int        `json:"id" pg:"id"`
+		Name      string     `json:"name" pg:"name,notnull"`
+		Key       string     `json:"key,omitempty" pg:"-"`
+		KeyPrefix string     `json:"key_prefix" pg:"key_prefix,notnull"`
+		KeyHash   string     `json:"-" pg:"key_hash,notnull"`
+		TenantID  *int       `json:"tenant_id,omitempty" pg:"tenant_id"`
+		Roles     []Role     `json:"roles" pg:"many2many:api_key_roles"`
+		CreatedAt *time.Time `json:"created_at" pg:"created_at"`
+		RevokedAt *time.Time `json:"revoked_at,omitempty" pg:"revoked_at"`
+	}
+
+	// APIKeyRole assigns a role to an API key
+	APIKeyRole struct {
+		tableName struct{}   `pg:"api_key_roles"`
+		APIKeyID  int        `pg:"api_key_id,pk"`
+		RoleID    int        `pg:"role_id,pk"`
+		CreatedAt *time.Time `pg:"created_at"`
+	}
+
+	// Principal is the authenticated caller of a request along with its permissions
+	Principal struct {
+		// Name is the name of the API key, recorded as the actor of changes
+		Name     string
+		APIKeyID int
+		// TenantID is the tenant of the API key, 0 if the key may access any tenant
+		TenantID    int
+		Permissions map[Permission]bool
+	}
+
+	// RoleRequest is the request body of create/update role APIs
+	RoleRequest struct {
+		Name        string       `json:"name" binding:"required"`
+		Description string       `json:"description,omitempty"`
+		Permissions []Permission `json:"permissions"`
+	}
+
+	// APIKeyRequest is the request body of create API key API, roles are role names
+	APIKeyRequest struct {
+		Name  string   `json:"name" binding:"required"`
+		Roles []string `json:"roles,omitempty"`
+		// TenantID restricts the API key to the tenant
+		TenantID *int `json:"tenant_id,omitempty"`
+	}
+)
+
+// Can reports whether the principal has the permission
+func (p *Principal) Can(perm Permission) bool {
+	return p != nil && p.Permissions[perm]
+}
+
+// newPrincipal returns the principal of the API key with the permissions of all its roles,
+// except the `platformPermissions` if the key is of a tenant
+func newPrincipal(key *APIKey) *Principal {
+	p := &Principal{Name: key.Name, APIKeyID: key.ID, Permissions: map[Permission]bool{}}
+	for _, role := range key.Roles {
+		for _, perm := range role.Permissions {
+			p.Permissions[perm] = true
+		}
+	}
+	if key.TenantID != nil {
+		p.TenantID = *key.TenantID
+		for _, perm := range platformPermissions {
+			delete(p.Permissions, perm)
+		}
+	}
+	return p
+}
+
+// allPermissions returns a principal granted every permission, eg. the bootstrap admin key
+func allPermissions(name string) *Principal {
+	p := &Principal{Name: name, Permissions: map[Permission]bool{}}
+	for _, perm := range Permissions {
+		p.Permissions[perm] = true
+	}
+	return p
+}
+
+// validPermission reports whether perm is one of `Permissions`
+func validPermission(perm Permission) bool {
+	for _, p := range Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// hashKey returns the hex encoded sha256 hash an API key is stored and looked up by
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedPermissions returns the permissions without duplicates in sorted order
+func sortedPermissions(perms []Permission) []Permission {
+	seen := make(map[Permission]bool, len(perms))
+	sorted := make([]Permission, 0, len(perms))
+	for _, p := range perms {
+		if !seen[p] {
+			seen[p] = true
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// postgres error codes
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+func isUniqueViolation(err error) bool {
+	pgErr, ok := err.(pg.Error)
+	return ok && pgErr.Field('C') == pgUniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	pgErr, ok := err.(pg.Error)
+	return ok && pgErr.Field('C') == pgForeignKeyViolation
+}
@@ -0,0 +1,226 @@
+package rbac
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// keyPrefix starts every generated API key, so that leaked keys are easy to recognize
+const keyPrefix = "gu_"
+
+type Service struct {
+	conf *viper.Viper
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns a RBAC service object.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository) *Service {
+	return &Service{conf: conf, log: log, Repo: Repo}
+}
+
+// Enabled reports whether requests are authorized, when disabled every request is allowed
+func (s *Service) Enabled() bool {
+	return s.conf.GetBool("rbac_enabled")
+}
+
+// Anonymous returns the unnamed principal of requests when RBAC is disabled, granted every permission
+func (s *Service) Anonymous() *Principal {
+	return allPermissions("")
+}
+
+// Authenticate returns the principal of the API key, or `er.Unauthorized` if the key is unknown or revoked.
+// The `rbac_admin_key` config is a bootstrap key granted every permission, eg. to create the first API keys.
+func (s *Service) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	if admin := s.conf.GetString("rbac_admin_key"); admin != "" && subtle.ConstantTimeCompare([]byte(key), []byte(admin)) == 1 {
+		return allPermissions("admin"), nil
+	}
+	apiKey, err := s.Repo.FetchAPIKeyByHash(ctx, hashKey(key))
+	if err == pg.ErrNoRows {
+		return nil, er.New(errors.New("unknown api key"), er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newPrincipal(apiKey), nil
+}
+
+func (s *Service) CreateRole(ctx context.Context, req *RoleRequest) (role *Role, err error) {
+	now := time.Now()
+	role = &Role{CreatedAt: &now}
+	if err = s.setRole(role, req); err != nil {
+		return
+	}
+	err = s.Repo.CreateRole(ctx, role)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.RoleAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+// UpdateRole replaces the name, description and permissions of the role.
+// API keys having the role get its new permissions on their next request.
+func (s *Service) UpdateRole(ctx context.Context, roleID int, req *RoleRequest) (role *Role, err error) {
+	if role, err = s.FetchRole(ctx, roleID); err != nil {
+		return
+	}
+	if err = s.setRole(role, req); err != nil {
+		return
+	}
+	err = s.Repo.UpdateRole(ctx, role)
+	switch {
+	case err == pg.ErrNoRows:
+		err = er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+	case isUniqueViolation(err):
+		err = er.New(err, er.RoleAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+func (s *Service) DeleteRole(ctx context.Context, roleID int) (err error) {
+	err = s.Repo.DeleteRole(ctx, roleID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchRole(ctx context.Context, roleID int) (role *Role, err error) {
+	role, err = s.Repo.FetchRole(ctx, roleID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchRoles(ctx context.Context) (roles []Role, err error) {
+	return s.Repo.FetchRoles(ctx)
+}
+
+// CreateAPIKey generates an API key with the requested roles, of the requested tenant if any.
+// The returned `APIKey.Key` is the only time the key is available, only its hash is stored.
+func (s *Service) CreateAPIKey(ctx context.Context, req *APIKeyRequest) (key *APIKey, err error) {
+	roles := []Role{}
+	if len(req.Roles) > 0 {
+		if roles, err = s.Repo.FetchRolesByName(ctx, req.Roles); err != nil {
+			return
+		}
+		if missing := missingRoles(req.Roles, roles); len(missing) > 0 {
+			err = fmt.Errorf("roles %v not found", missing)
+			return nil, er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+		}
+	}
+
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	now := time.Now()
+	key = &APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Key:       keyPrefix + hex.EncodeToString(b),
+		TenantID:  req.TenantID,
+		Roles:     roles,
+		CreatedAt: &now,
+	}
+	key.KeyPrefix = key.Key[:len(keyPrefix)+8]
+	key.KeyHash = hashKey(key.Key)
+	err = s.Repo.CreateAPIKey(ctx, key)
+	switch {
+	case isUniqueViolation(err):
+		err = er.New(err, er.APIKeyAlreadyExists).SetStatus(http.StatusConflict)
+	case isForeignKeyViolation(err):
+		err = er.New(err, er.TenantNotFound).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, keyID int) (err error) {
+	err = s.Repo.RevokeAPIKey(ctx, keyID, time.Now())
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.APIKeyNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchAPIKey(ctx context.Context, keyID int) (key *APIKey, err error) {
+	key, err = s.Repo.FetchAPIKey(ctx, keyID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.APIKeyNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// FetchAPIKeys lists the API keys along with their roles, revoked keys included
+func (s *Service) FetchAPIKeys(ctx context.Context) (keys []APIKey, err error) {
+	return s.Repo.FetchAPIKeys(ctx)
+}
+
+// AssignRole assigns the role to the API key and returns the API key with its roles
+func (s *Service) AssignRole(ctx context.Context, keyID, roleID int) (key *APIKey, err error) {
+	if _, err = s.FetchAPIKey(ctx, keyID); err != nil {
+		return
+	}
+	if _, err = s.FetchRole(ctx, roleID); err != nil {
+		return
+	}
+	if err = s.Repo.AssignRole(ctx, keyID, roleID); err != nil {
+		return
+	}
+	return s.FetchAPIKey(ctx, keyID)
+}
+
+// UnassignRole removes the role from the API key and returns the API key with its remaining roles
+func (s *Service) UnassignRole(ctx context.Context, keyID, roleID int) (key *APIKey, err error) {
+	err = s.Repo.UnassignRole(ctx, keyID, roleID)
+	if err == pg.ErrNoRows {
+		err = er.New(errors.New("role is not assigned to the api key"), er.RoleNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	return s.FetchAPIKey(ctx, keyID)
+}
+
+// setRole sets the fields of the request to the role after validating its permissions
+func (s *Service) setRole(role *Role, req *RoleRequest) error {
+	for _, perm := range req.Permissions {
+		if !validPermission(perm) {
+			err := errors.New("invalid permission " + perm)
+			return er.New(err, er.InvalidPermission).SetStatus(http.StatusUnprocessableEntity)
+		}
+	}
+	now := time.Now()
+	role.Name = strings.TrimSpace(req.Name)
+	role.Description = req.Description
+	role.Permissions = sortedPermissions(req.Permissions)
+	role.UpdatedAt = &now
+	return nil
+}
+
+// missingRoles returns the names which are not names of the roles
+func missingRoles(names []string, roles []Role) (missing []string) {
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	CreateTenant(dCtx context.Context, t *Tenant) error
+	UpdateTenant(dCtx context.Context, t *Tenant) error
+	FetchTenant(dCtx context.Context, tenantID int) (tenant *Tenant, err error)
+	FetchTenants(dCtx context.Context) (tenants []Tenant, err error)
+	FetchTenantBySlug(dCtx context.Context, slug string) (tenant *Tenant, err error)
+	FetchTenantByHost(dCtx context.Context, host string) (tenant *Tenant, err error)
+	HostsTaken(dCtx context.Context, hosts []string, exceptID int) (taken bool, err error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"gouserDB"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns the postgres tenant repository
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+	return
+}
+
+func (r *PGRepo) CreateTenant(ctx context.Context, t *Tenant) (err error) {
+	_, err = r.db.ModelContext(ctx, t).Insert()
+	return
+}
+
+func (r *PGRepo) UpdateTenant(ctx context.Context, t *Tenant) (err error) {
+	res, err := r.db.ModelContext(ctx, t).
+		Column("slug", "name", "hosts", "updated_at").
+		WherePK().
+		Update()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+func (r *PGRepo) FetchTenant(ctx context.Context, tenantID int) (tenant *Tenant, err error) {
+	tenant = &Tenant{ID: tenantID}
+	err = r.db.ModelContext(ctx, tenant).WherePK().Select()
+	return
+}
+
+func (r *PGRepo) FetchTenants(ctx context.Context) (tenants []Tenant, err error) {
+	tenants = []Tenant{}
+	err = r.db.ModelContext(ctx, &tenants).Order("id ASC").Select()
+	return
+}
+
+func (r *PGRepo) FetchTenantBySlug(ctx context.Context, slug string) (tenant *Tenant, err error) {
+	tenant = &Tenant{}
+	err = r.db.ModelContext(ctx, tenant).Where("slug = ?", slug).Select()
+	return
+}
+
+// FetchTenantByHost fetches the tenant served at the host
+func (r *PGRepo) FetchTenantByHost(ctx context.Context, host string) (tenant *Tenant, err error) {
+	tenant = &Tenant{}
+	err = r.db.ModelContext(ctx, tenant).Where("? = ANY(hosts)", host).Limit(1).Select()
+	return
+}
+
+// HostsTaken reports whether any of the hosts is served by a tenant other than `exceptID`
+func (r *PGRepo) HostsTaken(ctx context.Context, hosts []string, exceptID int) (taken bool, err error) {
+	return r.db.ModelContext(ctx, (*Tenant)(nil)).
+		Where("hosts && ?", pg.Array(hosts)).
+		Where("id != ?", exceptID).
+		Exists()
+}
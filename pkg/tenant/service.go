@@ -0,0 +1,146 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type Service struct {
+	conf *viper.Viper
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns a tenant service object.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository) *Service {
+	return &Service{conf: conf, log: log, Repo: Repo}
+}
+
+// Resolve returns the tenant of a request. A request made with the API key of a tenant belongs to that tenant
+// and may only name that tenant. Other requests belong to the tenant named by the slug, eg. of the `X-Tenant`
+// header, else to the tenant served at the host, else to the `tenant_default` tenant.
+func (s *Service) Resolve(ctx context.Context, keyTenantID int, slug, host string) (t *Tenant, err error) {
+	if keyTenantID != 0 {
+		if t, err = s.FetchTenant(ctx, keyTenantID); err != nil {
+			return
+		}
+		if slug != "" && slug != t.Slug {
+			err = fmt.Errorf("api key of tenant %s used for tenant %s", t.Slug, slug)
+			return nil, er.New(err, er.TenantAccessDenied).SetStatus(http.StatusForbidden).Ignore()
+		}
+		return
+	}
+
+	if slug != "" {
+		t, err = s.Repo.FetchTenantBySlug(ctx, slug)
+		if err == pg.ErrNoRows {
+			err = er.New(fmt.Errorf("tenant %s not found", slug), er.TenantNotFound).SetStatus(http.StatusBadRequest).Ignore()
+		}
+		return
+	}
+	if host = normalizeHost(host); host != "" {
+		if t, err = s.Repo.FetchTenantByHost(ctx, host); err != pg.ErrNoRows {
+			return
+		}
+	}
+
+	slug = s.conf.GetString("tenant_default")
+	if slug == "" {
+		err = errors.New("tenant missing in request")
+		return nil, er.New(err, er.TenantRequired).SetStatus(http.StatusBadRequest).Ignore()
+	}
+	t, err = s.Repo.FetchTenantBySlug(ctx, slug)
+	if err == pg.ErrNoRows {
+		err = fmt.Errorf("default tenant %s not found", slug)
+	}
+	return
+}
+
+func (s *Service) CreateTenant(ctx context.Context, req *TenantRequest) (t *Tenant, err error) {
+	now := time.Now()
+	t = &Tenant{CreatedAt: &now}
+	if err = s.setTenant(ctx, t, req); err != nil {
+		return
+	}
+	err = s.Repo.CreateTenant(ctx, t)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.TenantAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+// UpdateTenant replaces the slug, name and hosts of the tenant.
+// Clients naming the tenant by its slug must be changed along with its slug.
+func (s *Service) UpdateTenant(ctx context.Context, tenantID int, req *TenantRequest) (t *Tenant, err error) {
+	if t, err = s.FetchTenant(ctx, tenantID); err != nil {
+		return
+	}
+	if err = s.setTenant(ctx, t, req); err != nil {
+		return
+	}
+	err = s.Repo.UpdateTenant(ctx, t)
+	switch {
+	case err == pg.ErrNoRows:
+		err = er.New(err, er.TenantNotFound).SetStatus(http.StatusNotFound)
+	case isUniqueViolation(err):
+		err = er.New(err, er.TenantAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+func (s *Service) FetchTenant(ctx context.Context, tenantID int) (t *Tenant, err error) {
+	t, err = s.Repo.FetchTenant(ctx, tenantID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.TenantNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchTenants(ctx context.Context) (tenants []Tenant, err error) {
+	return s.Repo.FetchTenants(ctx)
+}
+
+// setTenant sets the fields of the request to the tenant after validating its slug,
+// and checking its hosts are not served by another tenant
+func (s *Service) setTenant(ctx context.Context, t *Tenant, req *TenantRequest) error {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !validSlug(slug) {
+		err := errors.New("invalid tenant slug " + req.Slug)
+		return er.New(err, er.InvalidTenant).SetStatus(http.StatusUnprocessableEntity)
+	}
+	hosts := normalizeHosts(req.Hosts)
+	if len(hosts) > 0 {
+		taken, err := s.Repo.HostsTaken(ctx, hosts, t.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			err = fmt.Errorf("hosts %v are served by another tenant", hosts)
+			return er.New(err, er.TenantAlreadyExists).SetStatus(http.StatusConflict)
+		}
+	}
+	now := time.Now()
+	t.Slug = slug
+	t.Name = strings.TrimSpace(req.Name)
+	t.Hosts = hosts
+	t.UpdatedAt = &now
+	return nil
+}
+
+// pgUniqueViolation is the postgres error code of `unique_violation`
+const pgUniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	pgErr, ok := err.(pg.Error)
+	return ok && pgErr.Field('C') == pgUniqueViolation
+}
@@ -0,0 +1,144 @@
+// Package tenant isolates the data of tenants, eg. the brands served by one deployment.
+// The tenant of a request is carried in its context, and queries of models owned by a tenant
+// built with `Query` only see and change the rows of that tenant.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"go.uber.org/fx"
+)
+
+// Module provides the tenant repository and service
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// ErrNoTenant is returned by queries of owned models when the context carries no tenant
+var ErrNoTenant = errors.New("tenant missing in context")
+
+// slugPattern is the format of tenant slugs, eg. `acme` or `acme-eu`
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type (
+	// Tenant is an isolated set of users, eg. a brand. Users, their phones, emails, tags, groups and
+	// metadata schemas belong to one tenant, and mobiles and emails are unique per tenant.
+	Tenant struct {
+		tableName struct{} `pg:"tenants,discard_unknown_columns"`
+		ID        int      `json:"id" pg:"id"`
+		Slug      string   `json:"slug" pg:"slug,notnull"`
+		Name      string   `json:"name" pg:"name,notnull"`
+		// Hosts are the host names the tenant is served at, eg. `api.acme.com`
+		Hosts     []string   `json:"hosts" pg:"hosts,array,notnull"`
+		CreatedAt *time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt *time.Time `json:"updated_at" pg:"updated_at"`
+	}
+
+	// TenantRequest is the request body of create/update tenant APIs
+	TenantRequest struct {
+		Slug  string   `json:"slug" binding:"required"`
+		Name  string   `json:"name" binding:"required"`
+		Hosts []string `json:"hosts,omitempty" binding:"max=20"`
+	}
+
+	// Owned is embedded by the models owned by a tenant. Queries of owned models built with `Query`
+	// are scoped to the tenant of the context, and inserted models are assigned to it.
+	Owned struct {
+		TenantID int `json:"-" pg:"tenant_id,notnull"`
+	}
+
+	// scope is the value of the tenant in a context
+	scope struct {
+		id  int
+		all bool
+	}
+
+	scopeKey struct{}
+)
+
+// WithID returns a copy of the context scoped to the tenant
+func WithID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{id: id})
+}
+
+// Unscoped returns a copy of the context whose queries see the rows of all tenants, eg. for background jobs.
+// Models inserted with it must have their `Owned.TenantID` set.
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{all: true})
+}
+
+// FromContext returns the tenant the context is scoped to
+func FromContext(ctx context.Context) (id int, ok bool) {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.id, s.id != 0
+}
+
+// isUnscoped reports whether the context sees the rows of all tenants
+func isUnscoped(ctx context.Context) bool {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.all
+}
+
+// Query returns the query of the models, scoped to the tenant of the context if the models are `Owned`.
+// The query fails with `ErrNoTenant` if the context is neither scoped to a tenant nor `Unscoped`.
+func Query(ctx context.Context, db orm.DB, models ...interface{}) *orm.Query {
+	q := db.ModelContext(ctx, models...)
+	tm := q.TableModel()
+	if tm == nil || !tm.Table().HasField("tenant_id") || isUnscoped(ctx) {
+		return q
+	}
+	id, ok := FromContext(ctx)
+	if !ok {
+		return q.Apply(func(q *orm.Query) (*orm.Query, error) {
+			return q, ErrNoTenant
+		})
+	}
+	return q.Where("?TableAlias.tenant_id = ?", id)
+}
+
+// BeforeInsert assigns the model to the tenant of the context
+func (o *Owned) BeforeInsert(ctx context.Context) (context.Context, error) {
+	if id, ok := FromContext(ctx); ok {
+		o.TenantID = id
+	}
+	if o.TenantID == 0 {
+		return ctx, ErrNoTenant
+	}
+	return ctx, nil
+}
+
+// validSlug reports whether the slug is a valid tenant slug
+func validSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+// normalizeHosts lowercases the hosts, strips their ports and drops duplicates
+func normalizeHosts(hosts []string) []string {
+	seen := make(map[string]bool, len(hosts))
+	normalized := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = normalizeHost(h)
+		if h != "" && !seen[h] {
+			seen[h] = true
+			normalized = append(normalized, h)
+		}
+	}
+	return normalized
+}
+
+// normalizeHost lowercases the host of a `Host` header and strips its port
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return host
+}
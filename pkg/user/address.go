@@ -0,0 +1,106 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+type (
+	// Address is a postal address of a user, eg. for delivery or billing.
+	// A user has at most one default address.
+	Address struct {
+		tableName  struct{}   `pg:"user_addresses,discard_unknown_columns"`
+		ID         int        `json:"id" pg:"id"`
+		UserID     int        `json:"user_id" pg:"user_id,notnull,on_delete:CASCADE"`
+		User       *User      `json:"-" pg:"rel:has-one"`
+		Label      string     `json:"label" pg:"label"`
+		Line1      string     `json:"line1" pg:"line1,notnull"`
+		Line2      string     `json:"line2" pg:"line2"`
+		City       string     `json:"city" pg:"city,notnull"`
+		State      string     `json:"state" pg:"state"`
+		PostalCode string     `json:"postal_code" pg:"postal_code"`
+		Country    string     `json:"country" pg:"country,notnull"`
+		IsDefault  bool       `json:"is_default" pg:"is_default,notnull,use_zero"`
+		CreatedAt  *time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt  *time.Time `json:"updated_at" pg:"updated_at"`
+
+		tenant.Owned
+	}
+
+	// AddressRequest is the request body of create/update address APIs.
+	// Country is an ISO 3166-1 alpha-2 code, eg. IN.
+	AddressRequest struct {
+		Label      string `json:"label,omitempty"`
+		Line1      string `json:"line1" binding:"required"`
+		Line2      string `json:"line2,omitempty"`
+		City       string `json:"city" binding:"required"`
+		State      string `json:"state,omitempty"`
+		PostalCode string `json:"postal_code,omitempty"`
+		Country    string `json:"country" binding:"required"`
+		IsDefault  bool   `json:"is_default,omitempty"`
+	}
+)
+
+// postalCodes are the postal code formats of countries, after normalization to upper case.
+// Postal codes of other countries are only trimmed.
+var postalCodes = map[string]*regexp.Regexp{
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"IN": regexp.MustCompile(`^[1-9]\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// address returns a new address with the fields of the request
+func (req AddressRequest) address() *Address {
+	return &Address{
+		Label:      req.Label,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		City:       req.City,
+		State:      req.State,
+		PostalCode: req.PostalCode,
+		Country:    req.Country,
+	}
+}
+
+// normalize trims the fields of the address and validates its country and postal code
+func (a *Address) normalize() error {
+	a.Label = strings.TrimSpace(a.Label)
+	a.Line1 = strings.TrimSpace(a.Line1)
+	a.Line2 = strings.TrimSpace(a.Line2)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	a.PostalCode = strings.ToUpper(strings.TrimSpace(a.PostalCode))
+
+	if _, ok := phonenumbers.GetSupportedRegions()[a.Country]; !ok {
+		return er.New(errors.New("unknown country "+a.Country), er.InvalidCountry).
+			SetStatus(http.StatusUnprocessableEntity).
+			SetFields(er.FieldError{Path: "/country", Message: "expected an ISO 3166-1 alpha-2 country code"})
+	}
+	if re, ok := postalCodes[a.Country]; ok && !re.MatchString(a.PostalCode) {
+		return er.New(errors.New("invalid postal code "+a.PostalCode+" for "+a.Country), er.InvalidPostalCode).
+			SetStatus(http.StatusUnprocessableEntity).
+			SetFields(er.FieldError{Path: "/postal_code", Message: "invalid postal code for " + a.Country})
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"context"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func (r *PGRepo) CreateAddress(ctx context.Context, a *Address) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if a.IsDefault {
+			if err = r.demoteAddresses(ctx, tx, a); err != nil {
+				return
+			}
+		}
+		if _, err = tenant.Query(ctx, tx, a).Insert(); err != nil {
+			return
+		}
+		return r.touchUsers(ctx, tx, a.UserID)
+	})
+}
+
+func (r *PGRepo) UpdateAddress(ctx context.Context, a *Address) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if a.IsDefault {
+			if err = r.demoteAddresses(ctx, tx, a); err != nil {
+				return
+			}
+		}
+		res, err := tenant.Query(ctx, tx, a).
+			Column("label", "line1", "line2", "city", "state", "postal_code", "country", "is_default", "updated_at").
+			WherePK().
+			Where("user_id = ?user_id").
+			Update()
+		if err != nil {
+			return
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		return r.touchUsers(ctx, tx, a.UserID)
+	})
+}
+
+func (r *PGRepo) DeleteAddress(ctx context.Context, userID, addressID int) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		res, err := tenant.Query(ctx, tx, (*Address)(nil)).
+			Where("id = ?", addressID).
+			Where("user_id = ?", userID).
+			Delete()
+		if err != nil {
+			return
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		return r.touchUsers(ctx, tx, userID)
+	})
+}
+
+func (r *PGRepo) FetchAddress(ctx context.Context, userID, addressID int) (address *Address, err error) {
+	address = &Address{}
+	err = tenant.Query(ctx, r.db, address).
+		Where("id = ?", addressID).
+		Where("user_id = ?", userID).
+		Select()
+	return
+}
+
+func (r *PGRepo) FetchAddresses(ctx context.Context, userID int) (addresses []Address, err error) {
+	addresses = []Address{}
+	err = tenant.Query(ctx, r.db, &addresses).
+		Where("user_id = ?", userID).
+		Order("is_default DESC", "id ASC").
+		Select()
+	return
+}
+
+// demoteAddresses unsets the default flag of the other addresses of the user of `a`.
+// It runs before `a` is saved as default, as a user can have only one default address.
+func (r *PGRepo) demoteAddresses(ctx context.Context, tx *pg.Tx, a *Address) (err error) {
+	_, err = tenant.Query(ctx, tx, (*Address)(nil)).
+		Set("is_default = FALSE").
+		Set("updated_at = ?", a.UpdatedAt).
+		Where("user_id = ?", a.UserID).
+		Where("id <> ?", a.ID).
+		Where("is_default").
+		Update()
+	return
+}
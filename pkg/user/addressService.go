@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// CreateAddress adds a postal address to the user. The first address of a user is always made default.
+func (s *Service) CreateAddress(ctx context.Context, userID int, req AddressRequest) (address *Address, err error) {
+	addresses, err := s.addressesOf(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	address = req.address()
+	address.UserID = userID
+	address.IsDefault = req.IsDefault || len(addresses) == 0
+	address.CreatedAt = &now
+	address.UpdatedAt = &now
+	if err = address.normalize(); err != nil {
+		return
+	}
+	err = s.Repo.CreateAddress(ctx, address)
+	return
+}
+
+// UpdateAddress replaces the fields of a user address. The default address stays default.
+func (s *Service) UpdateAddress(ctx context.Context, userID, addressID int, req AddressRequest) (address *Address, err error) {
+	saved, err := s.FetchAddress(ctx, userID, addressID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	address = req.address()
+	address.ID = saved.ID
+	address.UserID = saved.UserID
+	address.IsDefault = saved.IsDefault || req.IsDefault
+	address.CreatedAt = saved.CreatedAt
+	address.UpdatedAt = &now
+	if err = address.normalize(); err != nil {
+		return
+	}
+	err = s.Repo.UpdateAddress(ctx, address)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.AddressNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) DeleteAddress(ctx context.Context, userID, addressID int) (err error) {
+	err = s.Repo.DeleteAddress(ctx, userID, addressID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.AddressNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchAddress(ctx context.Context, userID, addressID int) (address *Address, err error) {
+	address, err = s.Repo.FetchAddress(ctx, userID, addressID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.AddressNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchAddresses(ctx context.Context, userID int) (addresses []Address, err error) {
+	return s.addressesOf(ctx, userID)
+}
+
+// addressesOf returns addresses of an existing user, or `er.UserNotFound` if user does not exist
+func (s *Service) addressesOf(ctx context.Context, userID int) (addresses []Address, err error) {
+	if _, err = s.Repo.Fetch(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+	return s.Repo.FetchAddresses(ctx, userID)
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"testing"
+
+	"gouser/er"
+)
+
+func TestAddressNormalize(t *testing.T) {
+	a := &Address{
+		Label:      " home ",
+		Line1:      " 221B Baker Street ",
+		City:       " London ",
+		PostalCode: " nw1 6xe ",
+		Country:    " gb ",
+	}
+	if err := a.normalize(); err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	want := Address{Label: "home", Line1: "221B Baker Street", City: "London", PostalCode: "NW1 6XE", Country: "GB"}
+	if *a != want {
+		t.Errorf("normalized %+v, want %+v", *a, want)
+	}
+}
+
+func TestAddressPostalCodes(t *testing.T) {
+	tests := []struct {
+		country    string
+		postalCode string
+		valid      bool
+	}{
+		{"US", "94103", true},
+		{"US", "94103-1234", true},
+		{"US", "9410", false},
+		{"US", "94103 1234", false},
+		{"IN", "560001", true},
+		{"IN", "060001", false},
+		{"IN", "56000", false},
+		{"GB", "SW1A 1AA", true},
+		{"GB", "sw1a1aa", true},
+		{"GB", "M1 1AE", true},
+		{"GB", "12345", false},
+		{"CA", "K1A 0B1", true},
+		{"CA", "k1a0b1", true},
+		{"CA", "D1A 0B1", false},
+		{"NL", "1012 AB", true},
+		{"NL", "1012", false},
+		{"JP", "100-0001", true},
+		{"JP", "1000001", true},
+		{"BR", "01310-100", true},
+		{"DE", "10115", true},
+		{"DE", "1011", false},
+		// a postal code is required in countries with a known format
+		{"FR", "", false},
+		// postal codes of other countries are only trimmed
+		{"IE", "D02 X285", true},
+		{"AE", "", true},
+	}
+	for _, tt := range tests {
+		a := &Address{Line1: "1 Main Street", City: "City", Country: tt.country, PostalCode: tt.postalCode}
+		err := a.normalize()
+		if tt.valid && err != nil {
+			t.Errorf("postal code %q of %s failed: %v", tt.postalCode, tt.country, err)
+		}
+		if !tt.valid && !er.IsCodeEq(err, er.InvalidPostalCode) {
+			t.Errorf("postal code %q of %s error = %v, want InvalidPostalCode", tt.postalCode, tt.country, err)
+		}
+	}
+}
+
+func TestAddressInvalidCountry(t *testing.T) {
+	for _, country := range []string{"", "XX", "USA", "United Kingdom"} {
+		a := &Address{Line1: "1 Main Street", City: "City", Country: country}
+		if err := a.normalize(); !er.IsCodeEq(err, er.InvalidCountry) {
+			t.Errorf("country %q error = %v, want InvalidCountry", country, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"gouser/pkg/tenant"
+)
+
+// actions recorded in the audit trail
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	// AuditErase records which fields were erased, without their values
+	AuditErase = "erase"
+)
+
+type (
+	// Audit is an append-only record of a change made to a user
+	Audit struct {
+		tableName struct{}      `pg:"user_audit,discard_unknown_columns"`
+		ID        int64         `json:"id" pg:"id"`
+		UserID    int           `json:"user_id" pg:"user_id,notnull"`
+		Action    string        `json:"action" pg:"action,notnull"`
+		Actor     string        `json:"actor,omitempty" pg:"actor"`
+		RequestID string        `json:"request_id,omitempty" pg:"request_id"`
+		SourceIP  string        `json:"source_ip,omitempty" pg:"source_ip"`
+		Changes   []FieldChange `json:"changes" pg:"changes,type:jsonb"`
+		CreatedAt time.Time     `json:"created_at" pg:"created_at"`
+
+		tenant.Owned
+	}
+
+	// FieldChange is the value of a field of `User` before and after a change
+	FieldChange struct {
+		Field  string      `json:"field"`
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+
+	// AuditInfo identifies who made a change, it is passed along in the context with `WithAuditInfo`
+	AuditInfo struct {
+		Actor     string
+		RequestID string
+		SourceIP  string
+		// UserAgent is recorded with consent events only
+		UserAgent string
+	}
+
+	// HistoryRequest is the query of user history API
+	HistoryRequest struct {
+		Field *string    `form:"field,omitempty"`
+		From  *time.Time `form:"from,omitempty"`
+		To    *time.Time `form:"to,omitempty"`
+		Page  int        `form:"page,default=1" binding:"min=1"`
+		Limit int        `form:"limit,default=20" binding:"min=1,max=100"`
+	}
+
+	auditInfoKey struct{}
+)
+
+// unauditedFields are the fields of `User` left out of the audit diff
+var unauditedFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+	"phones":     true,
+	"emails":     true,
+	"addresses":  true,
+	"tags":       true,
+}
+
+// WithAuditInfo returns a copy of ctx carrying the audit info recorded with the changes made in ctx
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// auditInfoFrom returns the audit info of ctx, empty if ctx has none
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+// newAudit returns the audit of the change of the user from `before` to `after`.
+// `before` is nil for a created user.
+func newAudit(ctx context.Context, action string, before, after *User) (*Audit, error) {
+	changes, err := diffUsers(before, after)
+	if err != nil {
+		return nil, err
+	}
+	info := auditInfoFrom(ctx)
+	return &Audit{
+		UserID:    after.ID,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		SourceIP:  info.SourceIP,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// diffUsers returns the changed fields of the user, keyed by their JSON names and sorted by field
+func diffUsers(before, after *User) ([]FieldChange, error) {
+	if before == nil {
+		before = &User{}
+	}
+	b, err := userFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := userFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	for field, av := range a {
+		if unauditedFields[field] {
+			continue
+		}
+		if bv := b[field]; !reflect.DeepEqual(bv, av) {
+			changes = append(changes, FieldChange{Field: field, Before: bv, After: av})
+		}
+	}
+	for field, bv := range b {
+		if _, ok := a[field]; !ok && !unauditedFields[field] {
+			changes = append(changes, FieldChange{Field: field, Before: bv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// userFields returns the JSON representation of the user as a map
+func userFields(u *User) (fields map[string]interface{}, err error) {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &fields)
+	return
+}
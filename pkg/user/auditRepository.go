@@ -0,0 +1,55 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// FetchAudits fetches the audit trail of the user along with the audit trails of the users merged into it,
+// latest first
+func (r *PGRepo) FetchAudits(ctx context.Context, userID int, req *HistoryRequest) (audits []Audit, pagination Pagination, err error) {
+	audits = []Audit{}
+	query := tenant.Query(ctx, r.db, &audits).
+		Where("user_id IN "+mergedUserIDs, userID, userID)
+	if req.Field != nil {
+		field, err := json.Marshal([]map[string]string{{"field": *req.Field}})
+		if err != nil {
+			return audits, pagination, err
+		}
+		query.Where("changes @> ?::jsonb", string(field))
+	}
+	if req.From != nil {
+		query.Where("created_at >= ?", *req.From)
+	}
+	if req.To != nil {
+		query.Where("created_at < ?", *req.To)
+	}
+
+	count, err := query.
+		Order("created_at DESC", "id DESC").
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return
+}
+
+// insertAudit records the change of the user from `before` to `after` in the transaction of the change
+func (r *PGRepo) insertAudit(ctx context.Context, tx *pg.Tx, action string, before, after *User) (err error) {
+	audit, err := newAudit(ctx, action, before, after)
+	if err != nil {
+		return
+	}
+	_, err = tenant.Query(ctx, tx, audit).Insert()
+	return
+}
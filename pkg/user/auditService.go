@@ -0,0 +1,22 @@
+package user
+
+import (
+	"context"
+	"net/http"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// FetchUserHistory lists the audit trail of the user, latest first.
+// The history of soft deleted users is listed as well.
+func (s *Service) FetchUserHistory(ctx context.Context, userID int, req *HistoryRequest) (audits []Audit, pagination Pagination, err error) {
+	if _, err = s.Repo.FetchIncludingDeleted(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+	return s.Repo.FetchAudits(ctx, userID, req)
+}
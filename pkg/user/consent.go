@@ -0,0 +1,182 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// ConsentAction is the kind of a consent event
+type ConsentAction string
+
+const (
+	ConsentGrant    ConsentAction = "grant"
+	ConsentWithdraw ConsentAction = "withdraw"
+)
+
+// ConsentStatus is the current consent of a user to a purpose, the action of its latest consent event
+type ConsentStatus string
+
+const (
+	ConsentGranted   ConsentStatus = "granted"
+	ConsentWithdrawn ConsentStatus = "withdrawn"
+	// ConsentNone is the status of a purpose the user never granted nor withdrew
+	ConsentNone ConsentStatus = "none"
+)
+
+// consentFilterCurrent is the suffix of consent filters of users who granted the latest version of a purpose
+const consentFilterCurrent = "current"
+
+// consentPurposePattern is the format of consent purposes, eg. `privacy_policy` or `marketing_email`
+var consentPurposePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// ErrConsentNotGranted is returned when a user withdraws a consent it has not granted
+var ErrConsentNotGranted = errors.New("consent not granted")
+
+type (
+	// ConsentDocument is a version of the document a user consents to for a purpose, eg. the privacy policy.
+	// Documents are never changed, a new version is published instead.
+	ConsentDocument struct {
+		tableName struct{} `pg:"consent_documents,alias:document,discard_unknown_columns"`
+		ID        int      `json:"id" pg:"id"`
+		Purpose   string   `json:"purpose" pg:"purpose,notnull"`
+		Version   int      `json:"version" pg:"version,notnull"`
+		Title     string   `json:"title" pg:"title,notnull"`
+		URL       string   `json:"url,omitempty" pg:"url"`
+		Content   string   `json:"content" pg:"content,notnull"`
+		// ContentHash is the hex sha256 of `Content`, a proof of the text consented to
+		ContentHash string    `json:"content_hash" pg:"content_hash,notnull"`
+		PublishedAt time.Time `json:"published_at" pg:"published_at"`
+		PublishedBy string    `json:"published_by,omitempty" pg:"published_by"`
+
+		tenant.Owned
+	}
+
+	// ConsentEvent records a user granting or withdrawing its consent to a version of a consent document.
+	// Events are never changed, the latest event of a purpose is the current consent of the user.
+	ConsentEvent struct {
+		tableName  struct{}      `pg:"consent_events,alias:event,discard_unknown_columns"`
+		ID         int           `json:"id" pg:"id"`
+		UserID     int           `json:"user_id" pg:"user_id,notnull"`
+		DocumentID int           `json:"document_id" pg:"document_id,notnull"`
+		Purpose    string        `json:"purpose" pg:"purpose,notnull"`
+		Version    int           `json:"version" pg:"version,notnull"`
+		Action     ConsentAction `json:"action" pg:"action,notnull"`
+		// IPAddress and UserAgent are those of the request recording the event, cleared when the user is erased
+		IPAddress string    `json:"ip_address,omitempty" pg:"ip_address"`
+		UserAgent string    `json:"user_agent,omitempty" pg:"user_agent"`
+		Actor     string    `json:"actor,omitempty" pg:"actor"`
+		RequestID string    `json:"request_id,omitempty" pg:"request_id"`
+		CreatedAt time.Time `json:"created_at" pg:"created_at"`
+
+		tenant.Owned
+	}
+
+	// ConsentState is the current consent of a user to a purpose
+	ConsentState struct {
+		Purpose string        `json:"purpose"`
+		Status  ConsentStatus `json:"status"`
+		// Version is the version of the document granted or withdrawn, LatestVersion the latest published version
+		Version       int `json:"version,omitempty"`
+		LatestVersion int `json:"latest_version"`
+		// Current is set if the user granted the latest version
+		Current   bool       `json:"current"`
+		UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	}
+
+	// ConsentDocumentRequest is the request body of publish consent document API
+	ConsentDocumentRequest struct {
+		Purpose string `json:"purpose" binding:"required"`
+		Title   string `json:"title" binding:"required"`
+		URL     string `json:"url,omitempty"`
+		Content string `json:"content" binding:"required"`
+	}
+
+	// ConsentDocumentsRequest is the query of consent documents API
+	ConsentDocumentsRequest struct {
+		Purpose *string `form:"purpose,omitempty"`
+	}
+
+	// ConsentRequest is the request body of record consent API. A grant consents to `Version`, the latest
+	// version of the purpose by default, and a withdrawal withdraws the version granted.
+	ConsentRequest struct {
+		Purpose string        `json:"purpose" binding:"required"`
+		Action  ConsentAction `json:"action" binding:"required"`
+		Version int           `json:"version,omitempty"`
+	}
+
+	// ConsentEventsRequest is the query of consent history API
+	ConsentEventsRequest struct {
+		Purpose *string `form:"purpose,omitempty"`
+		Page    int     `form:"page,default=1"`
+		Limit   int     `form:"limit,default=20"`
+	}
+
+	// ConsentFilter filters users who currently consent to the purpose, to its latest version if `Current` is set
+	ConsentFilter struct {
+		Purpose string
+		Current bool
+	}
+)
+
+// hashContent returns the hex sha256 of the content of the document
+func (d *ConsentDocument) hashContent() string {
+	sum := sha256.Sum256([]byte(d.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateConsentPurpose returns `er.InvalidConsent` if the purpose is not in the format of purposes
+func validateConsentPurpose(purpose string) error {
+	if !consentPurposePattern.MatchString(purpose) {
+		err := fmt.Errorf("invalid consent purpose %q, purposes are lowercase letters, digits and _", purpose)
+		return er.New(err, er.InvalidConsent).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return nil
+}
+
+// ParseConsentFilter parses a consent filter of users, `purpose` or `purpose:current`
+func ParseConsentFilter(s string) (f ConsentFilter, err error) {
+	purpose, suffix, hasSuffix := strings.Cut(strings.TrimSpace(s), ":")
+	if hasSuffix && suffix != consentFilterCurrent {
+		err = fmt.Errorf("invalid consent filter %q, expected purpose or purpose:current", s)
+		return f, er.New(err, er.InvalidRequestBody).
+			SetStatus(http.StatusUnprocessableEntity).
+			SetFields(er.FieldError{Path: "consent", Message: err.Error()})
+	}
+	if err = validateConsentPurpose(purpose); err != nil {
+		return
+	}
+	return ConsentFilter{Purpose: purpose, Current: hasSuffix}, nil
+}
+
+// consentStates returns the consent of the user to every purpose of the latest documents,
+// given the latest event of the user per purpose
+func consentStates(latest []ConsentDocument, events []ConsentEvent) []ConsentState {
+	byPurpose := make(map[string]*ConsentEvent, len(events))
+	for i := range events {
+		byPurpose[events[i].Purpose] = &events[i]
+	}
+	states := make([]ConsentState, 0, len(latest))
+	for _, d := range latest {
+		state := ConsentState{Purpose: d.Purpose, Status: ConsentNone, LatestVersion: d.Version}
+		if e := byPurpose[d.Purpose]; e != nil {
+			state.Version = e.Version
+			state.UpdatedAt = &e.CreatedAt
+			state.Status = ConsentWithdrawn
+			if e.Action == ConsentGrant {
+				state.Status = ConsentGranted
+				state.Current = e.Version == d.Version
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"math"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// consentDocumentLockKey is the advisory lock taken, along with the tenant ID, to publish consent documents
+// of a tenant, so that two versions of a purpose are not published concurrently
+const consentDocumentLockKey = 21
+
+// CreateConsentDocument publishes the document as the next version of its purpose
+func (r *PGRepo) CreateConsentDocument(ctx context.Context, d *ConsentDocument) (err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, ?::int)", consentDocumentLockKey, tenantID); err != nil {
+			return
+		}
+		err = tenant.Query(ctx, tx, (*ConsentDocument)(nil)).
+			ColumnExpr("coalesce(max(version), 0) + 1").
+			Where("purpose = ?", d.Purpose).
+			Select(pg.Scan(&d.Version))
+		if err != nil {
+			return
+		}
+		_, err = tenant.Query(ctx, tx, d).Insert()
+		return
+	})
+}
+
+func (r *PGRepo) FetchConsentDocument(ctx context.Context, documentID int) (document *ConsentDocument, err error) {
+	document = &ConsentDocument{ID: documentID}
+	err = tenant.Query(ctx, r.db, document).WherePK().Select()
+	return
+}
+
+// FetchConsentDocumentVersion fetches the version of the document of the purpose, the latest version if 0
+func (r *PGRepo) FetchConsentDocumentVersion(ctx context.Context, purpose string, version int) (document *ConsentDocument, err error) {
+	document = &ConsentDocument{}
+	query := tenant.Query(ctx, r.db, document).Where("purpose = ?", purpose)
+	if version != 0 {
+		query.Where("version = ?", version)
+	}
+	err = query.Order("version DESC").Limit(1).Select()
+	return
+}
+
+// FetchConsentDocuments fetches all versions of the documents, latest version first, without their content
+func (r *PGRepo) FetchConsentDocuments(ctx context.Context, req *ConsentDocumentsRequest) (documents []ConsentDocument, err error) {
+	documents = []ConsentDocument{}
+	query := tenant.Query(ctx, r.db, &documents).ExcludeColumn("content")
+	if req.Purpose != nil {
+		query.Where("purpose = ?", *req.Purpose)
+	}
+	err = query.Order("purpose ASC", "version DESC").Select()
+	return
+}
+
+// FetchLatestConsentDocuments fetches the latest version of the document of every purpose, without its content
+func (r *PGRepo) FetchLatestConsentDocuments(ctx context.Context) (documents []ConsentDocument, err error) {
+	documents = []ConsentDocument{}
+	err = tenant.Query(ctx, r.db, &documents).
+		ExcludeColumn("content").
+		DistinctOn("purpose").
+		Order("purpose ASC", "version DESC").
+		Select()
+	return
+}
+
+// CreateConsentEvent records the consent event of the user. A withdrawal withdraws the version granted
+// by the latest event of the purpose, it returns `ErrConsentNotGranted` if the purpose is not granted.
+// A consent granted by a user merged into the user is withdrawn by an event of the user.
+func (r *PGRepo) CreateConsentEvent(ctx context.Context, e *ConsentEvent) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		// events of a user are recorded one at a time, so that the latest event is the current consent
+		if err = r.lockUsers(ctx, tx, e.UserID); err != nil {
+			return
+		}
+		if e.Action == ConsentWithdraw {
+			latest := &ConsentEvent{}
+			err = tenant.Query(ctx, tx, latest).
+				Where("user_id IN "+mergedUserIDs, e.UserID, e.UserID).
+				Where("purpose = ?", e.Purpose).
+				Order("id DESC").
+				Limit(1).
+				Select()
+			if err == pg.ErrNoRows || (err == nil && latest.Action != ConsentGrant) {
+				return ErrConsentNotGranted
+			}
+			if err != nil {
+				return
+			}
+			e.DocumentID = latest.DocumentID
+			e.Version = latest.Version
+		}
+		_, err = tenant.Query(ctx, tx, e).Insert()
+		return
+	})
+}
+
+// FetchLatestConsentEvents fetches the latest consent event of the user, or of a user merged into it,
+// for every purpose
+func (r *PGRepo) FetchLatestConsentEvents(ctx context.Context, userID int) (events []ConsentEvent, err error) {
+	events = []ConsentEvent{}
+	err = tenant.Query(ctx, r.db, &events).
+		DistinctOn("purpose").
+		Where("user_id IN "+mergedUserIDs, userID, userID).
+		Order("purpose ASC", "id DESC").
+		Select()
+	return
+}
+
+// FetchConsentEvents fetches the consent events of the user and of the users merged into it, latest first
+func (r *PGRepo) FetchConsentEvents(ctx context.Context, userID int, req *ConsentEventsRequest) (events []ConsentEvent, pagination Pagination, err error) {
+	events = []ConsentEvent{}
+	query := tenant.Query(ctx, r.db, &events).Where("user_id IN "+mergedUserIDs, userID, userID)
+	if req.Purpose != nil {
+		query.Where("purpose = ?", *req.Purpose)
+	}
+	count, err := query.
+		Order("id DESC").
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return
+}
+
+// applyConsentFilter filters the users whose latest consent event of the purpose, among their events
+// and the events of the users merged into them, is a grant, of the latest version of the purpose if `f.Current` is set
+func applyConsentFilter(query *orm.Query, f ConsentFilter) {
+	sub := `?TableAlias.id IN (SELECT coalesce(o.merged_into, o.id) FROM consent_events AS e
+		JOIN "user" AS o ON o.id = e.user_id
+		WHERE e.tenant_id = ?TableAlias.tenant_id AND e.purpose = ? AND e.action = ?
+		AND NOT EXISTS (SELECT 1 FROM consent_events AS l WHERE l.purpose = e.purpose AND l.id > e.id
+			AND l.user_id IN (SELECT s.id FROM "user" AS s
+				WHERE s.id = coalesce(o.merged_into, o.id) OR s.merged_into = coalesce(o.merged_into, o.id)))`
+	if f.Current {
+		query.Where(sub+`
+			AND e.version = (SELECT max(d.version) FROM consent_documents AS d WHERE d.tenant_id = e.tenant_id AND d.purpose = e.purpose))`,
+			f.Purpose, ConsentGrant)
+		return
+	}
+	query.Where(sub+`)`, f.Purpose, ConsentGrant)
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// PublishConsentDocument publishes the document as the next version of its purpose, version 1 for a new purpose.
+// Users who granted an earlier version keep their consent, which is no longer `ConsentState.Current`.
+func (s *Service) PublishConsentDocument(ctx context.Context, req *ConsentDocumentRequest) (document *ConsentDocument, err error) {
+	if err = validateConsentPurpose(req.Purpose); err != nil {
+		return
+	}
+	document = &ConsentDocument{
+		Purpose:     req.Purpose,
+		Title:       req.Title,
+		URL:         req.URL,
+		Content:     req.Content,
+		PublishedAt: time.Now(),
+		PublishedBy: auditInfoFrom(ctx).Actor,
+	}
+	document.ContentHash = document.hashContent()
+	if err = s.Repo.CreateConsentDocument(ctx, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func (s *Service) FetchConsentDocument(ctx context.Context, documentID int) (document *ConsentDocument, err error) {
+	document, err = s.Repo.FetchConsentDocument(ctx, documentID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.ConsentDocumentNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// FetchConsentDocuments lists all versions of the consent documents, without their content
+func (s *Service) FetchConsentDocuments(ctx context.Context, req *ConsentDocumentsRequest) (documents []ConsentDocument, err error) {
+	return s.Repo.FetchConsentDocuments(ctx, req)
+}
+
+// RecordConsent records the user granting or withdrawing its consent to a purpose, along with the actor,
+// the IP address and the user agent of the request. A grant consents to the requested version of the document
+// of the purpose, the latest by default, and a withdrawal withdraws the version granted.
+func (s *Service) RecordConsent(ctx context.Context, userID int, req *ConsentRequest) (event *ConsentEvent, err error) {
+	if err = validateConsentPurpose(req.Purpose); err != nil {
+		return
+	}
+	info := auditInfoFrom(ctx)
+	event = &ConsentEvent{
+		UserID:    userID,
+		Purpose:   req.Purpose,
+		Action:    req.Action,
+		IPAddress: info.SourceIP,
+		UserAgent: info.UserAgent,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		CreatedAt: time.Now(),
+	}
+
+	switch req.Action {
+	case ConsentGrant:
+		document, err := s.Repo.FetchConsentDocumentVersion(ctx, req.Purpose, req.Version)
+		if err == pg.ErrNoRows {
+			err = fmt.Errorf("no consent document of purpose %s version %d", req.Purpose, req.Version)
+			return nil, er.New(err, er.ConsentDocumentNotFound).SetStatus(http.StatusUnprocessableEntity)
+		}
+		if err != nil {
+			return nil, err
+		}
+		event.DocumentID = document.ID
+		event.Version = document.Version
+	case ConsentWithdraw:
+		if req.Version != 0 {
+			err = errors.New("a withdrawal withdraws the version granted, version must be left out")
+			return nil, er.New(err, er.InvalidConsent).SetStatus(http.StatusUnprocessableEntity)
+		}
+	default:
+		err = errors.New("action must be grant or withdraw")
+		return nil, er.New(err, er.InvalidConsent).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	err = s.Repo.CreateConsentEvent(ctx, event)
+	switch err {
+	case nil:
+		return event, nil
+	case pg.ErrNoRows:
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	case ErrConsentNotGranted:
+		return nil, er.New(err, er.ConsentNotGranted).SetStatus(http.StatusConflict)
+	default:
+		return nil, err
+	}
+}
+
+// FetchConsentStates returns the current consent of the user to every purpose with a published document
+func (s *Service) FetchConsentStates(ctx context.Context, userID int) (states []ConsentState, err error) {
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	documents, err := s.Repo.FetchLatestConsentDocuments(ctx)
+	if err != nil {
+		return
+	}
+	events, err := s.Repo.FetchLatestConsentEvents(ctx, userID)
+	if err != nil {
+		return
+	}
+	return consentStates(documents, events), nil
+}
+
+// FetchConsentEvents lists the consent events of the user, latest first
+func (s *Service) FetchConsentEvents(ctx context.Context, userID int, req *ConsentEventsRequest) (events []ConsentEvent, pagination Pagination, err error) {
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	return s.Repo.FetchConsentEvents(ctx, userID, req)
+}
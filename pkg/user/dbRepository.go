@@ -6,8 +6,11 @@ import (
 	"strings"
 	"time"
 
+	"gouser/pkg/tenant"
+
 	"github.com/go-pg/pg/v10"
 	_pg "github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 )
@@ -15,9 +18,106 @@ import (
 type Repository interface {
 	CreateUser(dCtx context.Context, u *User) error
 	UpdateUser(dCtx context.Context, u *User) error
+	DeleteUser(dCtx context.Context, rID int) error
+	RestoreUser(dCtx context.Context, u *User) error
 	Fetch(dCtx context.Context, rID int) (user *User, err error)
+	FetchIncludingDeleted(dCtx context.Context, rID int) (user *User, err error)
 	FetchByMobileNumber(dCtx context.Context, mobile string) (user *User, err error)
 	FetchAllUsers(dCtx context.Context, req *UserRequest) (users []User, pagination Pagination, err error)
+
+	CreatePhone(dCtx context.Context, p *Phone) error
+	UpdatePhone(dCtx context.Context, p *Phone) error
+	DeletePhone(dCtx context.Context, userID, phoneID int) error
+	FetchPhone(dCtx context.Context, userID, phoneID int) (phone *Phone, err error)
+	FetchPhones(dCtx context.Context, userID int) (phones []Phone, err error)
+
+	CreateEmail(dCtx context.Context, e *Email) error
+	UpdateEmail(dCtx context.Context, e *Email) error
+	DeleteEmail(dCtx context.Context, userID, emailID int) error
+	FetchEmail(dCtx context.Context, userID, emailID int) (email *Email, err error)
+	FetchEmails(dCtx context.Context, userID int) (emails []Email, err error)
+	FetchEmailByToken(dCtx context.Context, tokenHash string) (email *Email, err error)
+	FetchByEmail(dCtx context.Context, address string) (user *User, err error)
+
+	UpdateProfilePicture(dCtx context.Context, u *User) error
+	CreatePictureUpload(dCtx context.Context, u *PictureUpload) error
+	FetchPictureUpload(dCtx context.Context, userID, uploadID int) (upload *PictureUpload, err error)
+	FinalizePictureUpload(dCtx context.Context, u *PictureUpload) error
+	FetchExpiredPictureUploads(dCtx context.Context, now time.Time, limit int) (uploads []PictureUpload, err error)
+	DeletePictureUpload(dCtx context.Context, uploadID int) error
+
+	CreateAddress(dCtx context.Context, a *Address) error
+	UpdateAddress(dCtx context.Context, a *Address) error
+	DeleteAddress(dCtx context.Context, userID, addressID int) error
+	FetchAddress(dCtx context.Context, userID, addressID int) (address *Address, err error)
+	FetchAddresses(dCtx context.Context, userID int) (addresses []Address, err error)
+
+	CreateErasure(dCtx context.Context, e *Erasure) error
+	CancelErasure(dCtx context.Context, e *Erasure) error
+	FetchErasure(dCtx context.Context, userID int) (erasure *Erasure, err error)
+	FetchDueErasures(dCtx context.Context, now time.Time, after *Erasure, limit int) (erasures []Erasure, err error)
+	EraseUser(dCtx context.Context, e *Erasure) error
+
+	AssignTags(dCtx context.Context, userIDs []int, add, remove []string) (result TagAssignmentResult, err error)
+	FetchUserTags(dCtx context.Context, userID int) (tags []Tag, err error)
+	FetchTagUsages(dCtx context.Context) (usages []TagUsage, err error)
+	FetchUserIDs(dCtx context.Context, ids []int) (found []int, err error)
+
+	CreateGroup(dCtx context.Context, g *Group, owner *GroupMember) error
+	FetchGroup(dCtx context.Context, groupID int) (group *Group, err error)
+	AddGroupMember(dCtx context.Context, m *GroupMember) error
+	UpdateGroupMember(dCtx context.Context, m *GroupMember) error
+	RemoveGroupMember(dCtx context.Context, groupID, userID int) error
+	FetchGroupMember(dCtx context.Context, groupID, userID int) (member *GroupMember, err error)
+	FetchGroupMembers(dCtx context.Context, groupID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error)
+	FetchUserGroups(dCtx context.Context, userID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error)
+
+	ChangeUserStatus(dCtx context.Context, change *StatusChange) error
+	FetchStatusChanges(dCtx context.Context, userID int, req *StatusHistoryRequest) (changes []StatusChange, pagination Pagination, err error)
+
+	CreateRelationship(dCtx context.Context, rel *Relationship) error
+	AcceptRelationship(dCtx context.Context, userID, relationshipID int, at time.Time) error
+	DeleteRelationship(dCtx context.Context, relationshipID int) error
+	FetchRelationship(dCtx context.Context, userID, relationshipID int) (rel *Relationship, err error)
+	FetchRelationships(dCtx context.Context, userID int, req *RelationshipsRequest) (rels []Relationship, pagination Pagination, err error)
+
+	MergeUsers(dCtx context.Context, survivor, merged *User, merge *UserMerge) error
+	FetchUserMerges(dCtx context.Context, survivorID int) (merges []UserMerge, err error)
+
+	FetchDuplicateCandidates(dCtx context.Context, afterID, limit int) (candidates []duplicateCandidate, err error)
+	FetchDuplicateCandidatesOf(dCtx context.Context, c *duplicateCandidate, limit int) (candidates []duplicateCandidate, err error)
+	ReplaceDuplicatePairs(dCtx context.Context, pairs []DuplicatePair) error
+	FetchDuplicatePairs(dCtx context.Context, minScore float64) (pairs []DuplicatePair, err error)
+	SaveDuplicateDetectionRequest(dCtx context.Context, req *DuplicateDetectionRequest) error
+	FetchDuplicateDetectionRequests(dCtx context.Context) (requests []DuplicateDetectionRequest, err error)
+	DeleteDuplicateDetectionRequest(dCtx context.Context, req *DuplicateDetectionRequest) error
+
+	FetchPreferences(dCtx context.Context, userIDs []int) (prefs []Preference, err error)
+	SavePreferences(dCtx context.Context, userID int, set []Preference, reset []string) error
+
+	CreateConsentDocument(dCtx context.Context, d *ConsentDocument) error
+	FetchConsentDocument(dCtx context.Context, documentID int) (document *ConsentDocument, err error)
+	FetchConsentDocumentVersion(dCtx context.Context, purpose string, version int) (document *ConsentDocument, err error)
+	FetchConsentDocuments(dCtx context.Context, req *ConsentDocumentsRequest) (documents []ConsentDocument, err error)
+	FetchLatestConsentDocuments(dCtx context.Context) (documents []ConsentDocument, err error)
+	CreateConsentEvent(dCtx context.Context, e *ConsentEvent) error
+	FetchLatestConsentEvents(dCtx context.Context, userID int) (events []ConsentEvent, err error)
+	FetchConsentEvents(dCtx context.Context, userID int, req *ConsentEventsRequest) (events []ConsentEvent, pagination Pagination, err error)
+
+	FetchByReferralCode(dCtx context.Context, code string) (user *User, err error)
+	SetReferrer(dCtx context.Context, userID, referrerID int, at time.Time) error
+	FetchReferralTree(dCtx context.Context, userID, depth, limit int) (nodes []*ReferralNode, err error)
+	CountReferrals(dCtx context.Context, userID, depth int) (count int, err error)
+	FetchReferralStats(dCtx context.Context, userID int) (stats *ReferralStats, err error)
+	FetchReferrerStats(dCtx context.Context, req *ReferralStatsRequest) (stats []ReferralStats, pagination Pagination, err error)
+
+	FetchAudits(dCtx context.Context, userID int, req *HistoryRequest) (audits []Audit, pagination Pagination, err error)
+
+	SaveMetadataSchema(dCtx context.Context, m *MetadataSchema) error
+	FetchMetadataSchema(dCtx context.Context, namespace string, version int) (schema *MetadataSchema, err error)
+	FetchMetadataSchemas(dCtx context.Context, namespace string) (schemas []MetadataSchema, err error)
+	CreateMetadataIndex(dCtx context.Context, idx MetadataIndex) error
+	CreateMetadataGINIndex(dCtx context.Context) error
 }
 
 // NewRepositoryIn is function param struct of func `NewRepository`
@@ -46,55 +146,141 @@ func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
 	return
 }
 
+// CreateUser inserts the user along with its phones and records the creation in the audit trail
 func (r *PGRepo) CreateUser(ctx context.Context, u *User) (err error) {
-	_, err = r.db.ModelContext(ctx, u).Insert()
-	return
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, u).Insert(); err != nil {
+			return
+		}
+		// the mobile of the user is kept as its primary phone in `u.Phones`
+		for i := range u.Phones {
+			u.Phones[i].UserID = u.ID
+			if _, err = tenant.Query(ctx, tx, &u.Phones[i]).Insert(); err != nil {
+				return
+			}
+		}
+		return r.insertAudit(ctx, tx, AuditCreate, nil, u)
+	})
 }
 
+// UpdateUser updates the user and records the changed fields in the audit trail
 func (r *PGRepo) UpdateUser(ctx context.Context, u *User) (err error) {
-	// var err error
-	query := r.db.ModelContext(ctx, u) //.WherePK().Update()
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		before := &User{ID: u.ID}
+		if err = tenant.Query(ctx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		if err = r.updateUser(ctx, tx, u); err != nil {
+			return
+		}
+		after := &User{ID: u.ID}
+		if err = tenant.Query(ctx, tx, after).WherePK().Select(); err != nil {
+			return
+		}
+		return r.insertAudit(ctx, tx, AuditUpdate, before, after)
+	})
+}
 
-	if u.Mobile != "" {
-		query.Set("mobile=?", u.Mobile)
-	}
-	if u.ProfilePicture != "" {
-		query.Set("profile_picture=?", u.ProfilePicture)
-	}
-	if u.Metadata != nil {
-		query.Set("metadata=?", u.Metadata)
-	}
-	if !u.DOB.IsZero() {
-		query.Set("dob=?", u.DOB)
-	}
-	if u.FirstName != "" {
-		query.Set("first_name=?first_name,last_name=?last_name")
-	}
-	query.Set("updated_at=?", time.Now())
+// updateUser replaces the editable fields of the user, zero values clear the fields
+func (r *PGRepo) updateUser(ctx context.Context, tx *pg.Tx, u *User) (err error) {
+	now := time.Now()
+	u.UpdatedAt = &now
+	query := tenant.Query(ctx, tx, u).
+		Set("first_name=?first_name").
+		Set("last_name=?last_name").
+		Set("mobile=?mobile").
+		Set("country_code=?country_code").
+		Set("national_number=?national_number").
+		// uploaded picture variants are dropped once the picture is changed, the old row values are compared
+		Set("profile_picture_variants=CASE WHEN profile_picture IS NOT DISTINCT FROM ?profile_picture THEN profile_picture_variants END").
+		Set("profile_picture_key=CASE WHEN profile_picture IS NOT DISTINCT FROM ?profile_picture THEN profile_picture_key END").
+		Set("profile_picture=?profile_picture").
+		Set("dob=?dob").
+		Set("metadata=?metadata").
+		Set("metadata_namespace=?metadata_namespace").
+		Set("metadata_schema_version=?metadata_schema_version").
+		Set("updated_at=?updated_at")
+
+	// optimistic concurrency, the update fails if the user was changed since `u.Version` was read
+	query.Set("version=version+1").Where("version=?", u.Version).Returning("version")
 	k, err := query.WherePK().Update()
 	if err != nil {
 		r.log.Error(err.Error())
+		return
 	}
 	r.log.Info(k)
+	if k.RowsAffected() == 0 {
+		return ErrVersionConflict
+	}
 
+	if p := u.primaryPhone(); p != nil {
+		err = r.syncPrimaryPhone(ctx, tx, u.ID, p, now)
+	}
 	return err
 }
 
+// UpdateProfilePicture sets the uploaded profile picture of the user and records the change in the audit trail
+func (r *PGRepo) UpdateProfilePicture(ctx context.Context, u *User) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		before := &User{ID: u.ID}
+		if err = tenant.Query(ctx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		_, err = tenant.Query(ctx, tx, u).
+			Set("profile_picture=?profile_picture").
+			Set("profile_picture_variants=?profile_picture_variants").
+			Set("profile_picture_key=?profile_picture_key").
+			Set("updated_at=?updated_at").
+			Set("version=version+1").
+			WherePK().
+			Returning("version").
+			Update()
+		if err != nil {
+			return
+		}
+		after := &User{ID: u.ID}
+		if err = tenant.Query(ctx, tx, after).WherePK().Select(); err != nil {
+			return
+		}
+		return r.insertAudit(ctx, tx, AuditUpdate, before, after)
+	})
+}
+
 func (r *PGRepo) FetchAllUsers(dCtx context.Context, req *UserRequest) (users []User, pagination Pagination, err error) {
 	users = []User{}
-	query := r.db.ModelContext(dCtx, &User{}).Returning("*")
+	query := tenant.Query(dCtx, r.db, &User{}).Returning("*")
+	if req.IncludeDeleted {
+		query.AllWithDeleted()
+	}
 	if req.Mobile != nil {
-		query.Where(`mobile ILIKE '%` + *req.Mobile + `%'`)
+		query.Where("mobile ILIKE ?", "%"+*req.Mobile+"%")
+	}
+	if req.Email != nil {
+		query.Where("?TableAlias.id IN (SELECT user_id FROM user_emails WHERE lower(address) = lower(?))", *req.Email)
+	}
+	if len(req.Status) > 0 {
+		query.Where("status IN (?)", pg.In(req.Status))
+	}
+	if len(req.Tag) > 0 {
+		applyTagFilter(query, req.Tag, req.TagMatch)
+	}
+	for _, f := range req.ConsentFilters {
+		applyConsentFilter(query, f)
 	}
 	if req.Name != nil {
 		nameString := strings.Split(*req.Name, " ")
 
 		if len(nameString) == 1 {
-			query.Where(`first_name ILIKE '%` + nameString[0] + `%'`)
+			query.Where("first_name ILIKE ?", "%"+nameString[0]+"%")
 		}
 		if len(nameString) >= 2 {
-			query.Where(`first_name ILIKE '%` + nameString[0] + `%'`)
-			query.Where(`last_name ILIKE '%` + nameString[1] + `%'`)
+			query.Where("first_name ILIKE ?", "%"+nameString[0]+"%")
+			query.Where("last_name ILIKE ?", "%"+nameString[1]+"%")
+		}
+	}
+	for _, f := range req.MetadataFilters {
+		if err = f.apply(query); err != nil {
+			return
 		}
 	}
 	var count int
@@ -125,24 +311,143 @@ func (r *PGRepo) FetchAllUsers(dCtx context.Context, req *UserRequest) (users []
 	return users, pagination, nil
 }
 
+// DeleteUser soft deletes the user along with its phones and records the deletion in the audit trail
+func (r *PGRepo) DeleteUser(dCtx context.Context, rID int) (err error) {
+	return r.db.RunInTransaction(dCtx, func(tx *pg.Tx) (err error) {
+		now := time.Now()
+		before := &User{ID: rID}
+		if err = tenant.Query(dCtx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		after := *before
+		after.DeletedAt = &now
+		_, err = tenant.Query(dCtx, tx, &after).
+			Set("deleted_at = ?deleted_at").
+			Set("version = version + 1").
+			WherePK().
+			Returning("version").
+			Update()
+		if err != nil {
+			return
+		}
+		_, err = tenant.Query(dCtx, tx, (*Phone)(nil)).
+			Set("deleted_at = ?", now).
+			Where("user_id = ?", rID).
+			Update()
+		if err != nil {
+			return
+		}
+		return r.insertAudit(dCtx, tx, AuditDelete, before, &after)
+	})
+}
+
+// RestoreUser clears `deleted_at` of the user and of the phones deleted along with it
+// and records the restore in the audit trail
+func (r *PGRepo) RestoreUser(dCtx context.Context, u *User) (err error) {
+	return r.db.RunInTransaction(dCtx, func(tx *pg.Tx) (err error) {
+		now := time.Now()
+		before := &User{ID: u.ID}
+		if err = tenant.Query(dCtx, tx, before).AllWithDeleted().WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+
+		_, err = tenant.Query(dCtx, tx, (*Phone)(nil)).AllWithDeleted().
+			Set("deleted_at = NULL").
+			Set("updated_at = ?", now).
+			Where("user_id = ?", u.ID).
+			Where("deleted_at = ?", u.DeletedAt).
+			Update()
+		if err != nil {
+			return
+		}
+
+		u.DeletedAt = nil
+		u.UpdatedAt = &now
+		_, err = tenant.Query(dCtx, tx, u).AllWithDeleted().
+			Set("deleted_at = NULL").
+			Set("updated_at = ?updated_at").
+			Set("version = version + 1").
+			WherePK().
+			Returning("version").
+			Update()
+		if err != nil {
+			return
+		}
+		after := *before
+		after.DeletedAt = nil
+		after.UpdatedAt = &now
+		after.Version = u.Version
+		return r.insertAudit(dCtx, tx, AuditRestore, before, &after)
+	})
+}
+
+// touchUsers bumps the version of the users after their phones, emails, addresses or tags changed,
+// the version is the ETag of the user which includes them
+func (r *PGRepo) touchUsers(ctx context.Context, tx *pg.Tx, userIDs ...int) (err error) {
+	if len(userIDs) == 0 {
+		return
+	}
+	_, err = tenant.Query(ctx, tx, (*User)(nil)).
+		Set("version = version + 1").
+		Where("id IN (?)", pg.In(uniqueInts(userIDs))).
+		AllWithDeleted().
+		Update()
+	return
+}
+
 func (r *PGRepo) Fetch(dCtx context.Context, rID int) (user *User, err error) {
 	user = &User{
 		ID: rID,
 	}
-	err = r.db.ModelContext(dCtx, user).Column("user.*").WherePK().Select()
+	err = tenant.Query(dCtx, r.db, user).Column("user.*").
+		Relation("Phones", orderByPrimary).
+		Relation("Emails", orderByPrimary).
+		Relation("Addresses", orderByDefault).
+		Relation("Tags", orderByName).
+		WherePK().Select()
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (r *PGRepo) FetchByMobileNumber(dCtx context.Context, mobile string) (user *User, err error) {
+// FetchIncludingDeleted fetches the user even if it is soft deleted
+func (r *PGRepo) FetchIncludingDeleted(dCtx context.Context, rID int) (user *User, err error) {
 	user = &User{
-		Mobile: mobile,
+		ID: rID,
 	}
-	err = r.db.ModelContext(dCtx, user).Column("user.*").Where("mobile=?mobile").Select()
+	err = tenant.Query(dCtx, r.db, user).Column("user.*").AllWithDeleted().
+		Relation("Phones", orderByPrimary).
+		Relation("Emails", orderByPrimary).
+		Relation("Addresses", orderByDefault).
+		Relation("Tags", orderByName).
+		WherePK().Select()
+	return
+}
+
+// FetchByMobileNumber resolves a user by any of the phone numbers owned by the user
+func (r *PGRepo) FetchByMobileNumber(dCtx context.Context, mobile string) (user *User, err error) {
+	user = &User{}
+	err = tenant.Query(dCtx, r.db, user).Column("user.*").
+		Where("?TableAlias.id IN (SELECT user_id FROM user_phones WHERE number = ? AND deleted_at IS NULL)", mobile).
+		Select()
 	if err != nil {
 		return
 	}
 	return
 }
+
+// orderByDefault orders has-many relations having a default flag, default first
+func orderByDefault(q *orm.Query) (*orm.Query, error) {
+	return q.Order("is_default DESC", "id ASC"), nil
+}
+
+// orderByPrimary orders has-many relations having a primary flag, primary first
+func orderByPrimary(q *orm.Query) (*orm.Query, error) {
+	return q.Order("is_primary DESC", "id ASC"), nil
+}
+
+// orderByName orders relations by name, eg. tags
+func orderByName(q *orm.Query) (*orm.Query, error) {
+	return q.Order("name ASC"), nil
+}
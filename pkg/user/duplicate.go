@@ -0,0 +1,366 @@
+package user
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gouser/pkg/tenant"
+)
+
+// weights of the signals of duplicate scores, they add up to 1. Names alone score at most 0.4,
+// so pairs scoring above 0.4 share a phone or a date of birth, which detection relies on.
+const (
+	duplicatePhoneWeight    = 0.4
+	duplicateDOBWeight      = 0.2
+	duplicateNameWeight     = 0.25
+	duplicatePhoneticWeight = 0.15
+)
+
+const (
+	// duplicateBlockMaxSize skips blocks of candidates too large to compare pairwise, eg. a common name
+	// born on the same day
+	duplicateBlockMaxSize = 100
+	// duplicateNameMatch is the name similarity reported as a `name` reason
+	duplicateNameMatch = 0.8
+)
+
+// reasons of duplicate scores
+const (
+	DuplicatePhone    = "phone"
+	DuplicateDOB      = "dob"
+	DuplicateName     = "name"
+	DuplicatePhonetic = "phonetic"
+)
+
+type (
+	// DuplicatePair is a pair of users found to be probably the same person, `UserID` is the lower ID
+	DuplicatePair struct {
+		tableName       struct{}  `pg:"user_duplicates,alias:duplicate,discard_unknown_columns"`
+		UserID          int       `json:"user_id" pg:"user_id,pk"`
+		DuplicateUserID int       `json:"duplicate_user_id" pg:"duplicate_user_id,pk"`
+		Score           float64   `json:"score" pg:"score,notnull"`
+		Reasons         []string  `json:"reasons" pg:"reasons,array"`
+		DetectedAt      time.Time `json:"detected_at" pg:"detected_at"`
+
+		tenant.Owned
+	}
+
+	// DuplicateCluster is a set of users connected by duplicate pairs, its score is the highest score of its pairs
+	DuplicateCluster struct {
+		UserIDs []int           `json:"user_ids"`
+		Score   float64         `json:"score"`
+		Pairs   []DuplicatePair `json:"pairs"`
+	}
+
+	// DuplicateMatch is a user probably the same person as a given user
+	DuplicateMatch struct {
+		UserID  int      `json:"user_id"`
+		Score   float64  `json:"score"`
+		Reasons []string `json:"reasons"`
+	}
+
+	// DuplicateDetection is the result of a detection run of a tenant
+	DuplicateDetection struct {
+		Users int `json:"users"`
+		Pairs int `json:"pairs"`
+		// SkippedBlocks is the number of blocks larger than `duplicateBlockMaxSize`, their users are not compared
+		SkippedBlocks int       `json:"skipped_blocks,omitempty"`
+		DetectedAt    time.Time `json:"detected_at"`
+	}
+
+	// DuplicateDetectionRequest asks the worker to detect the duplicate users of its tenant at its next run,
+	// rather than after `duplicate_detection_interval`
+	DuplicateDetectionRequest struct {
+		tableName   struct{}  `pg:"duplicate_detection_requests,alias:detection_request,discard_unknown_columns"`
+		RequestedAt time.Time `json:"requested_at" pg:"requested_at"`
+
+		tenant.Owned
+	}
+
+	// DuplicatesRequest is the query of list duplicates API, `MinScore` defaults to `duplicate_threshold`
+	DuplicatesRequest struct {
+		MinScore *float64 `form:"min_score,omitempty"`
+		Page     int      `form:"page,default=1"`
+		Limit    int      `form:"limit,default=20"`
+	}
+
+	// duplicateCandidate is the data of a user compared by duplicate detection
+	duplicateCandidate struct {
+		tableName       struct{}   `pg:"user,alias:user"`
+		ID              int        `pg:"id"`
+		FirstName       string     `pg:"first_name"`
+		LastName        string     `pg:"last_name"`
+		DOB             *time.Time `pg:"dob"`
+		NationalNumbers []string   `pg:"national_numbers,array"`
+
+		tenant.Owned
+	}
+)
+
+// scoreDuplicate scores how probably the users are the same person, from 0 to 1, along with the reasons
+func scoreDuplicate(a, b *duplicateCandidate) (score float64, reasons []string) {
+	reasons = []string{}
+	if sharesString(a.NationalNumbers, b.NationalNumbers) {
+		score += duplicatePhoneWeight
+		reasons = append(reasons, DuplicatePhone)
+	}
+	if a.DOB != nil && b.DOB != nil && a.DOB.Format("2006-01-02") == b.DOB.Format("2006-01-02") {
+		score += duplicateDOBWeight
+		reasons = append(reasons, DuplicateDOB)
+	}
+
+	nameA := normalizeName(a.FirstName + " " + a.LastName)
+	if nameA != "" {
+		// names are also compared swapped, eg. when first and last names were entered the other way round
+		sim := math.Max(
+			nameSimilarity(nameA, normalizeName(b.FirstName+" "+b.LastName)),
+			nameSimilarity(nameA, normalizeName(b.LastName+" "+b.FirstName)),
+		)
+		score += sim * duplicateNameWeight
+		if sim >= duplicateNameMatch {
+			reasons = append(reasons, DuplicateName)
+		}
+		phonetic := math.Max(
+			phoneticSimilarity(a.FirstName, a.LastName, b.FirstName, b.LastName),
+			phoneticSimilarity(a.FirstName, a.LastName, b.LastName, b.FirstName),
+		)
+		score += phonetic * duplicatePhoneticWeight
+		if phonetic > 0 {
+			reasons = append(reasons, DuplicatePhonetic)
+		}
+	}
+	return math.Round(score*1000) / 1000, reasons
+}
+
+// blockingKeys are the keys of the blocks of candidates the user is compared within:
+// its national numbers, and its date of birth along with the soundex of its first and of its last name
+func (c *duplicateCandidate) blockingKeys() (keys []string) {
+	for _, n := range c.NationalNumbers {
+		keys = append(keys, "n:"+n)
+	}
+	if c.DOB != nil {
+		dob := c.DOB.Format("2006-01-02")
+		for _, name := range []string{c.FirstName, c.LastName} {
+			if code := soundex(name); code != "" {
+				keys = append(keys, "d:"+dob+":"+code)
+			}
+		}
+	}
+	return
+}
+
+// findDuplicatePairs compares the candidates within their blocks and returns the pairs scoring at least `threshold`
+func findDuplicatePairs(candidates []duplicateCandidate, threshold float64, detectedAt time.Time) (pairs []DuplicatePair, skipped int) {
+	blocks := map[string][]int{}
+	for i := range candidates {
+		for _, key := range candidates[i].blockingKeys() {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+	compared := map[[2]int]bool{}
+	pairs = []DuplicatePair{}
+	for _, block := range blocks {
+		if len(block) > duplicateBlockMaxSize {
+			skipped++
+			continue
+		}
+		for i := 0; i < len(block); i++ {
+			for j := i + 1; j < len(block); j++ {
+				a, b := &candidates[block[i]], &candidates[block[j]]
+				if a.ID > b.ID {
+					a, b = b, a
+				}
+				key := [2]int{a.ID, b.ID}
+				if a.ID == b.ID || compared[key] {
+					continue
+				}
+				compared[key] = true
+				if score, reasons := scoreDuplicate(a, b); score >= threshold {
+					pairs = append(pairs, DuplicatePair{
+						UserID:          a.ID,
+						DuplicateUserID: b.ID,
+						Score:           score,
+						Reasons:         reasons,
+						DetectedAt:      detectedAt,
+					})
+				}
+			}
+		}
+	}
+	return
+}
+
+// clusterDuplicates groups the pairs into clusters of connected users, highest score first, then largest first
+func clusterDuplicates(pairs []DuplicatePair) []DuplicateCluster {
+	parent := map[int]int{}
+	var find func(id int) int
+	find = func(id int) int {
+		p, ok := parent[id]
+		if !ok || p == id {
+			parent[id] = id
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+	for _, p := range pairs {
+		a, b := find(p.UserID), find(p.DuplicateUserID)
+		if a != b {
+			parent[b] = a
+		}
+	}
+
+	byRoot := map[int]*DuplicateCluster{}
+	for _, p := range pairs {
+		root := find(p.UserID)
+		c := byRoot[root]
+		if c == nil {
+			c = &DuplicateCluster{}
+			byRoot[root] = c
+		}
+		c.Pairs = append(c.Pairs, p)
+		c.Score = math.Max(c.Score, p.Score)
+	}
+	clusters := make([]DuplicateCluster, 0, len(byRoot))
+	for _, c := range byRoot {
+		seen := map[int]bool{}
+		for _, p := range c.Pairs {
+			for _, id := range []int{p.UserID, p.DuplicateUserID} {
+				if !seen[id] {
+					seen[id] = true
+					c.UserIDs = append(c.UserIDs, id)
+				}
+			}
+		}
+		sort.Ints(c.UserIDs)
+		sort.Slice(c.Pairs, func(i, j int) bool { return c.Pairs[i].Score > c.Pairs[j].Score })
+		clusters = append(clusters, *c)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.UserIDs) != len(b.UserIDs) {
+			return len(a.UserIDs) > len(b.UserIDs)
+		}
+		return a.UserIDs[0] < b.UserIDs[0]
+	})
+	return clusters
+}
+
+// normalizeName lowercases the name and keeps its letters, words are separated by single spaces
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// nameSimilarity is 1 minus the edit distance of the names relative to the longest name
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single rune insertions, deletions and substitutions turning a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// phoneticSimilarity is the share of the first and last names sounding alike, 0, 0.5 or 1
+func phoneticSimilarity(firstA, lastA, firstB, lastB string) (sim float64) {
+	for _, names := range [][2]string{{firstA, firstB}, {lastA, lastB}} {
+		if code := soundex(names[0]); code != "" && code == soundex(names[1]) {
+			sim += 0.5
+		}
+	}
+	return
+}
+
+// soundexDigits are the soundex digits of the letters A to Z
+const soundexDigits = "01230120022455012623010202"
+
+// soundex returns the American Soundex code of the name, eg. R163 for Robert and Rupert.
+// Letters other than A to Z are ignored, it is empty if the name does not start with one of them.
+func soundex(name string) string {
+	code := make([]byte, 0, 4)
+	var last byte
+	for _, r := range strings.ToUpper(name) {
+		if r < 'A' || r > 'Z' {
+			if len(code) == 0 && unicode.IsLetter(r) {
+				return ""
+			}
+			continue
+		}
+		d := soundexDigits[r-'A']
+		if len(code) == 0 {
+			code = append(code, byte(r))
+		} else if d != '0' && d != last {
+			code = append(code, d)
+		}
+		// H and W do not separate letters of the same digit, vowels do
+		if r != 'H' && r != 'W' {
+			last = d
+		}
+		if len(code) == 4 {
+			break
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+func sharesString(a, b []string) bool {
+	for _, x := range a {
+		if x != "" && containsString(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"strings"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// duplicatePairInsertBatch is the number of duplicate pairs inserted per query
+const duplicatePairInsertBatch = 1000
+
+// duplicateCandidateQuery selects the users, not deleted, along with the national numbers of their phones
+func duplicateCandidateQuery(ctx context.Context, db orm.DB, model interface{}) *orm.Query {
+	return tenant.Query(ctx, db, model).
+		Column("id", "first_name", "last_name", "dob").
+		ColumnExpr(`ARRAY(SELECT p.national_number FROM user_phones AS p
+			WHERE p.user_id = ?TableAlias.id AND p.deleted_at IS NULL AND p.national_number IS NOT NULL) AS national_numbers`).
+		Where("?TableAlias.deleted_at IS NULL")
+}
+
+// FetchDuplicateCandidates fetches up to `limit` users with an ID greater than `afterID`, ordered by ID
+func (r *PGRepo) FetchDuplicateCandidates(ctx context.Context, afterID, limit int) (candidates []duplicateCandidate, err error) {
+	candidates = []duplicateCandidate{}
+	err = duplicateCandidateQuery(ctx, r.db, &candidates).
+		Where("?TableAlias.id > ?", afterID).
+		OrderExpr("?TableAlias.id ASC").
+		Limit(limit).
+		Select()
+	return
+}
+
+// FetchDuplicateCandidatesOf fetches up to `limit` users, other than the user, who share a national number
+// with the user, or its date of birth along with the initial of its first or last name. The initials are a coarser
+// block than the Soundex codes of detection, which start with the initial. Users sharing a national number come
+// first, then the newest users, so that the most probable duplicates are kept when a block exceeds the limit.
+func (r *PGRepo) FetchDuplicateCandidatesOf(ctx context.Context, c *duplicateCandidate, limit int) (candidates []duplicateCandidate, err error) {
+	candidates = []duplicateCandidate{}
+	initials := nameInitials(c.FirstName, c.LastName)
+	if len(c.NationalNumbers) == 0 && (c.DOB == nil || len(initials) == 0) {
+		return
+	}
+	sharesNumber := `EXISTS (SELECT 1 FROM user_phones AS p
+		WHERE p.user_id = ?TableAlias.id AND p.deleted_at IS NULL AND p.national_number IN (?))`
+	query := duplicateCandidateQuery(ctx, r.db, &candidates).Where("?TableAlias.id <> ?", c.ID)
+	query.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+		if len(c.NationalNumbers) > 0 {
+			q.WhereOr(sharesNumber, pg.In(c.NationalNumbers))
+		}
+		if c.DOB != nil && len(initials) > 0 {
+			q.WhereOrGroup(func(q *orm.Query) (*orm.Query, error) {
+				q.Where("?TableAlias.dob = ?", c.DOB.Format("2006-01-02")).
+					WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+						q.WhereOr("upper(left(?TableAlias.first_name, 1)) IN (?)", pg.In(initials)).
+							WhereOr("upper(left(?TableAlias.last_name, 1)) IN (?)", pg.In(initials))
+						return q, nil
+					})
+				return q, nil
+			})
+		}
+		return q, nil
+	})
+	if len(c.NationalNumbers) > 0 {
+		query.OrderExpr(sharesNumber+" DESC", pg.In(c.NationalNumbers))
+	}
+	err = query.OrderExpr("?TableAlias.id DESC").Limit(limit).Select()
+	return
+}
+
+// ReplaceDuplicatePairs replaces the duplicate pairs of the tenant with the pairs of a detection run
+func (r *PGRepo) ReplaceDuplicatePairs(ctx context.Context, pairs []DuplicatePair) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, (*DuplicatePair)(nil)).Delete(); err != nil {
+			return
+		}
+		for start := 0; start < len(pairs); start += duplicatePairInsertBatch {
+			end := start + duplicatePairInsertBatch
+			if end > len(pairs) {
+				end = len(pairs)
+			}
+			batch := pairs[start:end]
+			if _, err = tenant.Query(ctx, tx, &batch).Insert(); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// FetchDuplicatePairs fetches the duplicate pairs scoring at least `minScore`, pairs of deleted users
+// are left out, eg. once merged
+func (r *PGRepo) FetchDuplicatePairs(ctx context.Context, minScore float64) (pairs []DuplicatePair, err error) {
+	pairs = []DuplicatePair{}
+	err = tenant.Query(ctx, r.db, &pairs).
+		Where("score >= ?", minScore).
+		Where(`NOT EXISTS (SELECT 1 FROM "user" AS u
+			WHERE u.id IN (?TableAlias.user_id, ?TableAlias.duplicate_user_id) AND u.deleted_at IS NOT NULL)`).
+		Order("score DESC", "user_id ASC", "duplicate_user_id ASC").
+		Select()
+	return
+}
+
+// SaveDuplicateDetectionRequest requests a detection of the tenant, replacing the time of a pending request
+// so that a request made while the worker runs the pending one is kept
+func (r *PGRepo) SaveDuplicateDetectionRequest(ctx context.Context, req *DuplicateDetectionRequest) (err error) {
+	_, err = tenant.Query(ctx, r.db, req).
+		OnConflict("(tenant_id) DO UPDATE").
+		Set("requested_at = EXCLUDED.requested_at").
+		Insert()
+	return
+}
+
+// FetchDuplicateDetectionRequests fetches the pending detection requests, oldest first
+func (r *PGRepo) FetchDuplicateDetectionRequests(ctx context.Context) (requests []DuplicateDetectionRequest, err error) {
+	requests = []DuplicateDetectionRequest{}
+	err = tenant.Query(ctx, r.db, &requests).Order("requested_at ASC").Select()
+	return
+}
+
+// DeleteDuplicateDetectionRequest deletes the detection request once run, unless it was requested again since
+func (r *PGRepo) DeleteDuplicateDetectionRequest(ctx context.Context, req *DuplicateDetectionRequest) (err error) {
+	_, err = tenant.Query(ctx, r.db, (*DuplicateDetectionRequest)(nil)).
+		Where("tenant_id = ?", req.TenantID).
+		Where("requested_at <= ?", req.RequestedAt).
+		Delete()
+	return
+}
+
+// nameInitials returns the distinct uppercased initials of the names
+func nameInitials(names ...string) (initials []string) {
+	for _, name := range names {
+		for _, r := range strings.TrimSpace(name) {
+			if initial := strings.ToUpper(string(r)); !containsString(initials, initial) {
+				initials = append(initials, initial)
+			}
+			break
+		}
+	}
+	return
+}
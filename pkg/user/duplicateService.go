@@ -0,0 +1,130 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+const (
+	// duplicateCandidateBatchSize is the number of users loaded per query by duplicate detection
+	duplicateCandidateBatchSize = 1000
+	// duplicateCheckLimit is the number of candidates compared to a new user by `FindDuplicates`
+	duplicateCheckLimit = 200
+)
+
+// DetectDuplicates compares the users of the tenant of the context and replaces its duplicate pairs
+// with the pairs scoring at least `duplicate_threshold`. The users are loaded in batches and compared in memory
+// within blocks sharing a phone national number, or a date of birth along with the Soundex code of the first or
+// last name, see `blockingKeys` and `scoreDuplicate`. It is run by the worker, see `RequestDuplicateDetection`.
+func (s *Service) DetectDuplicates(ctx context.Context) (detection *DuplicateDetection, err error) {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return nil, tenant.ErrNoTenant
+	}
+	candidates := []duplicateCandidate{}
+	for afterID := 0; ; {
+		batch, err := s.Repo.FetchDuplicateCandidates(ctx, afterID, duplicateCandidateBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, batch...)
+		if len(batch) < duplicateCandidateBatchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+
+	detection = &DuplicateDetection{Users: len(candidates), DetectedAt: time.Now()}
+	pairs, skipped := findDuplicatePairs(candidates, s.conf.GetFloat64("duplicate_threshold"), detection.DetectedAt)
+	if err = s.Repo.ReplaceDuplicatePairs(ctx, pairs); err != nil {
+		return
+	}
+	detection.Pairs = len(pairs)
+	detection.SkippedBlocks = skipped
+	return
+}
+
+// RequestDuplicateDetection asks the worker to detect the duplicate users of the tenant of the context at its
+// next run, detection loads every user of the tenant and is too slow for a request
+func (s *Service) RequestDuplicateDetection(ctx context.Context) (req *DuplicateDetectionRequest, err error) {
+	req = &DuplicateDetectionRequest{RequestedAt: time.Now()}
+	err = s.Repo.SaveDuplicateDetectionRequest(ctx, req)
+	return
+}
+
+// FetchDuplicateDetectionRequests returns the pending detection requests of the tenants of the context
+func (s *Service) FetchDuplicateDetectionRequests(ctx context.Context) ([]DuplicateDetectionRequest, error) {
+	return s.Repo.FetchDuplicateDetectionRequests(ctx)
+}
+
+// CompleteDuplicateDetectionRequest removes the request once its detection is run
+func (s *Service) CompleteDuplicateDetectionRequest(ctx context.Context, req *DuplicateDetectionRequest) error {
+	return s.Repo.DeleteDuplicateDetectionRequest(ctx, req)
+}
+
+// FetchDuplicateClusters returns the clusters of probable duplicate users found by the last detection,
+// ranked by score. Pairs of users deleted since are left out.
+func (s *Service) FetchDuplicateClusters(ctx context.Context, req *DuplicatesRequest) (clusters []DuplicateCluster, pagination Pagination, err error) {
+	minScore := s.conf.GetFloat64("duplicate_threshold")
+	if req.MinScore != nil {
+		if *req.MinScore < 0 || *req.MinScore > 1 {
+			err = errors.New("min_score must be between 0 and 1")
+			return nil, pagination, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		}
+		minScore = *req.MinScore
+	}
+	pairs, err := s.Repo.FetchDuplicatePairs(ctx, minScore)
+	if err != nil {
+		return
+	}
+
+	all := clusterDuplicates(pairs)
+	pagination.TotalDataCount = len(all)
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(len(all)) / float64(req.Limit)))
+	clusters = []DuplicateCluster{}
+	if start := (req.Page - 1) * req.Limit; start < len(all) {
+		end := start + req.Limit
+		if end > len(all) {
+			end = len(all)
+		}
+		clusters = all[start:end]
+	}
+	return
+}
+
+// FindDuplicates returns the users probably the same person as the user, those scoring at least
+// `duplicate_threshold`, highest score first
+func (s *Service) FindDuplicates(ctx context.Context, user *User) (matches []DuplicateMatch, err error) {
+	c := &duplicateCandidate{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		DOB:       user.DOB,
+	}
+	for _, p := range user.Phones {
+		if p.NationalNumber != "" && !containsString(c.NationalNumbers, p.NationalNumber) {
+			c.NationalNumbers = append(c.NationalNumbers, p.NationalNumber)
+		}
+	}
+	candidates, err := s.Repo.FetchDuplicateCandidatesOf(ctx, c, duplicateCheckLimit)
+	if err != nil {
+		return
+	}
+
+	threshold := s.conf.GetFloat64("duplicate_threshold")
+	matches = []DuplicateMatch{}
+	for i := range candidates {
+		if score, reasons := scoreDuplicate(c, &candidates[i]); score >= threshold {
+			matches = append(matches, DuplicateMatch{UserID: candidates[i].ID, Score: score, Reasons: reasons})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return
+}
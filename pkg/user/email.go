@@ -0,0 +1,58 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gouser/pkg/tenant"
+)
+
+type (
+	// Email is an email address owned by a user.
+	// Addresses are unique case-insensitively across all users.
+	Email struct {
+		tableName             struct{}   `pg:"user_emails,discard_unknown_columns"`
+		ID                    int        `json:"id" pg:"id"`
+		UserID                int        `json:"user_id" pg:"user_id,notnull,on_delete:CASCADE"`
+		User                  *User      `json:"-" pg:"rel:has-one"`
+		Address               string     `json:"address" pg:"address,notnull"`
+		IsPrimary             bool       `json:"is_primary" pg:"is_primary,notnull,use_zero"`
+		VerifiedAt            *time.Time `json:"verified_at" pg:"verified_at"`
+		VerificationTokenHash string     `json:"-" pg:"verification_token_hash"`
+		VerificationSentAt    *time.Time `json:"verification_sent_at" pg:"verification_sent_at"`
+		VerificationExpiresAt *time.Time `json:"verification_expires_at" pg:"verification_expires_at"`
+		CreatedAt             *time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt             *time.Time `json:"updated_at" pg:"updated_at"`
+
+		tenant.Owned
+	}
+
+	// EmailRequest is the request body of create/update email APIs
+	EmailRequest struct {
+		Address   string `json:"address" binding:"required,email"`
+		IsPrimary bool   `json:"is_primary,omitempty"`
+	}
+
+	// VerifyEmailRequest is the request body of verify email API
+	VerifyEmailRequest struct {
+		Token string `json:"token" form:"token" binding:"required"`
+	}
+)
+
+// newVerificationToken returns a random token to be mailed to the user and its hash to be stored
+func newVerificationToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	token = hex.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex encoded sha256 hash of a verification token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
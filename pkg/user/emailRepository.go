@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func (r *PGRepo) CreateEmail(ctx context.Context, e *Email) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, e).Insert(); err != nil {
+			return
+		}
+		if e.IsPrimary {
+			if err = r.demoteEmails(ctx, tx, e); err != nil {
+				return
+			}
+		}
+		return r.touchUsers(ctx, tx, e.UserID)
+	})
+}
+
+func (r *PGRepo) UpdateEmail(ctx context.Context, e *Email) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		_, err = tenant.Query(ctx, tx, e).
+			Column("address", "is_primary", "verified_at", "verification_token_hash",
+				"verification_sent_at", "verification_expires_at", "updated_at").
+			WherePK().
+			Where("user_id = ?user_id").
+			Update()
+		if err != nil {
+			return
+		}
+		if e.IsPrimary {
+			if err = r.demoteEmails(ctx, tx, e); err != nil {
+				return
+			}
+		}
+		return r.touchUsers(ctx, tx, e.UserID)
+	})
+}
+
+func (r *PGRepo) DeleteEmail(ctx context.Context, userID, emailID int) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		res, err := tenant.Query(ctx, tx, (*Email)(nil)).
+			Where("id = ?", emailID).
+			Where("user_id = ?", userID).
+			Delete()
+		if err != nil {
+			return
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		return r.touchUsers(ctx, tx, userID)
+	})
+}
+
+func (r *PGRepo) FetchEmail(ctx context.Context, userID, emailID int) (email *Email, err error) {
+	email = &Email{}
+	err = tenant.Query(ctx, r.db, email).
+		Where("id = ?", emailID).
+		Where("user_id = ?", userID).
+		Select()
+	return
+}
+
+func (r *PGRepo) FetchEmails(ctx context.Context, userID int) (emails []Email, err error) {
+	emails = []Email{}
+	err = tenant.Query(ctx, r.db, &emails).
+		Where("user_id = ?", userID).
+		Order("is_primary DESC", "id ASC").
+		Select()
+	return
+}
+
+// FetchEmailByToken finds the email waiting for verification with the token hash
+func (r *PGRepo) FetchEmailByToken(ctx context.Context, tokenHash string) (email *Email, err error) {
+	email = &Email{}
+	err = tenant.Query(ctx, r.db, email).
+		Where("verification_token_hash = ?", tokenHash).
+		Select()
+	return
+}
+
+// FetchByEmail resolves a user by any of the email addresses owned by the user, case-insensitively
+func (r *PGRepo) FetchByEmail(ctx context.Context, address string) (user *User, err error) {
+	user = &User{}
+	err = tenant.Query(ctx, r.db, user).Column("user.*").
+		Where("?TableAlias.id IN (SELECT user_id FROM user_emails WHERE lower(address) = lower(?))", address).
+		Select()
+	return
+}
+
+// demoteEmails makes `e` the only primary email of its user
+func (r *PGRepo) demoteEmails(ctx context.Context, tx *pg.Tx, e *Email) (err error) {
+	_, err = tenant.Query(ctx, tx, (*Email)(nil)).
+		Set("is_primary = FALSE").
+		Set("updated_at = ?", e.UpdatedAt).
+		Where("user_id = ?", e.UserID).
+		Where("id <> ?", e.ID).
+		Where("is_primary").
+		Update()
+	return
+}
@@ -0,0 +1,219 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/mail"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// CreateEmail adds a new email address to the user and mails a verification token to it.
+// The first email of a user is always made primary. The email is kept if the mail fails, unsent reports it
+// then and a new token can be requested with `SendEmailVerification`.
+func (s *Service) CreateEmail(ctx context.Context, userID int, req EmailRequest) (email *Email, unsent bool, err error) {
+	emails, err := s.emailsOf(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	email = &Email{
+		UserID:    userID,
+		Address:   strings.TrimSpace(req.Address),
+		IsPrimary: req.IsPrimary || len(emails) == 0,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	token, err := s.issueVerification(email, now)
+	if err != nil {
+		return
+	}
+	err = s.Repo.CreateEmail(ctx, email)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.EmailAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	if err != nil {
+		return
+	}
+	unsent = !s.trySendVerification(ctx, email, token)
+	return
+}
+
+// UpdateEmail updates the address or primary flag of a user email.
+// A changed address has to be verified again, unsent reports that its verification mail failed as in `CreateEmail`.
+func (s *Service) UpdateEmail(ctx context.Context, userID, emailID int, req EmailRequest) (email *Email, unsent bool, err error) {
+	email, err = s.FetchEmail(ctx, userID, emailID)
+	if err != nil {
+		return
+	}
+
+	var (
+		now     = time.Now()
+		token   string
+		address = strings.TrimSpace(req.Address)
+	)
+	if !strings.EqualFold(email.Address, address) {
+		email.VerifiedAt = nil
+		if token, err = s.issueVerification(email, now); err != nil {
+			return
+		}
+	}
+	email.Address = address
+	email.IsPrimary = email.IsPrimary || req.IsPrimary
+	email.UpdatedAt = &now
+
+	err = s.Repo.UpdateEmail(ctx, email)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.EmailAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	if err != nil || token == "" {
+		return
+	}
+	unsent = !s.trySendVerification(ctx, email, token)
+	return
+}
+
+// DeleteEmail removes an email from the user. The primary email can not be deleted,
+// another email is made primary first.
+func (s *Service) DeleteEmail(ctx context.Context, userID, emailID int) (err error) {
+	email, err := s.FetchEmail(ctx, userID, emailID)
+	if err != nil {
+		return
+	}
+	if email.IsPrimary {
+		return er.New(errors.New("primary email can not be deleted"), er.PrimaryEmailRequired).SetStatus(http.StatusUnprocessableEntity)
+	}
+	err = s.Repo.DeleteEmail(ctx, userID, emailID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.EmailNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchEmail(ctx context.Context, userID, emailID int) (email *Email, err error) {
+	email, err = s.Repo.FetchEmail(ctx, userID, emailID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.EmailNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchEmails(ctx context.Context, userID int) (emails []Email, err error) {
+	return s.emailsOf(ctx, userID)
+}
+
+// FetchByEmail finds the user owning the email address
+func (s *Service) FetchByEmail(ctx context.Context, address string) (user *User, err error) {
+	return s.Repo.FetchByEmail(ctx, strings.TrimSpace(address))
+}
+
+// SendEmailVerification issues a new verification token for the email and mails it.
+// Tokens issued earlier for the email stop working.
+func (s *Service) SendEmailVerification(ctx context.Context, userID, emailID int) (email *Email, err error) {
+	email, err = s.FetchEmail(ctx, userID, emailID)
+	if err != nil {
+		return
+	}
+	if email.VerifiedAt != nil {
+		err = er.New(errors.New("email is already verified"), er.EmailAlreadyVerified).SetStatus(http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	token, err := s.issueVerification(email, now)
+	if err != nil {
+		return
+	}
+	email.UpdatedAt = &now
+	if err = s.Repo.UpdateEmail(ctx, email); err != nil {
+		return
+	}
+	err = s.sendVerification(ctx, email, token)
+	return
+}
+
+// ConfirmEmail marks the email of the verification token as verified
+func (s *Service) ConfirmEmail(ctx context.Context, token string) (email *Email, err error) {
+	email, err = s.Repo.FetchEmailByToken(ctx, hashToken(token))
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.InvalidVerificationToken).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if email.VerificationExpiresAt == nil || now.After(*email.VerificationExpiresAt) {
+		err = er.New(errors.New("verification token expired"), er.VerificationTokenExpired).SetStatus(http.StatusGone)
+		return
+	}
+
+	email.VerifiedAt = &now
+	email.VerificationTokenHash = ""
+	email.VerificationExpiresAt = nil
+	email.UpdatedAt = &now
+	err = s.Repo.UpdateEmail(ctx, email)
+	return
+}
+
+// emailsOf returns emails of an existing user, or `er.UserNotFound` if user does not exist
+func (s *Service) emailsOf(ctx context.Context, userID int) (emails []Email, err error) {
+	if _, err = s.Repo.Fetch(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+	return s.Repo.FetchEmails(ctx, userID)
+}
+
+// issueVerification sets a new verification token on the email and returns the plain token
+func (s *Service) issueVerification(email *Email, now time.Time) (token string, err error) {
+	token, hash, err := newVerificationToken()
+	if err != nil {
+		return
+	}
+	expiresAt := now.Add(s.conf.GetDuration("email_verification_ttl"))
+	email.VerificationTokenHash = hash
+	email.VerificationSentAt = &now
+	email.VerificationExpiresAt = &expiresAt
+	return
+}
+
+// trySendVerification mails the verification token of a saved email, a failure is logged and reported as false
+// so that the saved email is still returned
+func (s *Service) trySendVerification(ctx context.Context, email *Email, token string) bool {
+	if err := s.sendVerification(ctx, email, token); err != nil {
+		s.log.WithFields(logrus.Fields{
+			"email_id": email.ID,
+			"user_id":  email.UserID,
+			"error":    err.Error(),
+		}).Error("error sending email verification")
+		return false
+	}
+	return true
+}
+
+// sendVerification mails the verification token to the email address
+func (s *Service) sendVerification(ctx context.Context, email *Email, token string) error {
+	body := fmt.Sprintf("Use the token below to verify your email address %s.\n\n%s\n\nThe token expires at %s.\n",
+		email.Address, token, email.VerificationExpiresAt.Format(time.RFC1123))
+	if link := s.conf.GetString("email_verification_url"); link != "" {
+		body += fmt.Sprintf("\nOr open %s?token=%s\n", link, url.QueryEscape(token))
+	}
+
+	return s.mailer.Send(ctx, &mail.Message{
+		To:      []string{email.Address},
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
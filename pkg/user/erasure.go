@@ -0,0 +1,105 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gouser/pkg/tenant"
+)
+
+type (
+	// Erasure is a request to permanently erase the PII of a user.
+	// The user is erased once `ScheduledFor` has passed, unless the erasure is cancelled before.
+	// An erased user is kept as a tombstone row so that references to it keep working.
+	Erasure struct {
+		tableName    struct{}        `pg:"user_erasures,discard_unknown_columns"`
+		ID           int             `json:"id" pg:"id"`
+		UserID       int             `json:"user_id" pg:"user_id,notnull"`
+		User         *User           `json:"-" pg:"rel:has-one"`
+		RequestedBy  string          `json:"requested_by" pg:"requested_by"`
+		Reason       string          `json:"reason" pg:"reason"`
+		RequestedAt  *time.Time      `json:"requested_at" pg:"requested_at"`
+		ScheduledFor *time.Time      `json:"scheduled_for" pg:"scheduled_for,notnull"`
+		CancelledAt  *time.Time      `json:"cancelled_at" pg:"cancelled_at"`
+		CompletedAt  *time.Time      `json:"completed_at" pg:"completed_at"`
+		Receipt      *ErasureReceipt `json:"receipt,omitempty" pg:"receipt,type:jsonb"`
+		ReceiptHash  string          `json:"receipt_hash,omitempty" pg:"receipt_hash"`
+
+		tenant.Owned
+	}
+
+	// ErasureFailures are the errors of the erasures `Service.ProcessDueErasures` failed to process, by erasure ID
+	ErasureFailures map[int]error
+
+	// ErasureReceipt records what was erased, as a proof of compliance
+	ErasureReceipt struct {
+		ErasureID        int       `json:"erasure_id"`
+		UserID           int       `json:"user_id"`
+		ErasedFields     []string  `json:"erased_fields"`
+		DeletedPhones    int       `json:"deleted_phones"`
+		DeletedEmails    int       `json:"deleted_emails"`
+		DeletedAddresses int       `json:"deleted_addresses"`
+		ErasedAt         time.Time `json:"erased_at"`
+
+		// DeletedRelationships and DeletedPreferences are omitted when zero, so that receipts made before
+		// relationships and preferences were erased still match their hash
+		DeletedRelationships int `json:"deleted_relationships,omitempty"`
+		DeletedPreferences   int `json:"deleted_preferences,omitempty"`
+
+		// AnonymizedConsentEvents is the number of consent events kept without IP address and user agent
+		AnonymizedConsentEvents int `json:"anonymized_consent_events,omitempty"`
+	}
+
+	// ErasureRequest is the request body of schedule erasure API
+	ErasureRequest struct {
+		RequestedBy string `json:"requested_by" binding:"required"`
+		Reason      string `json:"reason,omitempty"`
+	}
+)
+
+// erasedFields are the PII columns of `User` cleared on erasure
+var erasedFields = []string{
+	"first_name",
+	"last_name",
+	"mobile",
+	"country_code",
+	"national_number",
+	"profile_picture",
+	"profile_picture_variants",
+	"profile_picture_key",
+	"dob",
+	"metadata",
+}
+
+// IsPending reports whether the erasure is neither cancelled nor completed yet
+func (e *Erasure) IsPending() bool {
+	return e.CancelledAt == nil && e.CompletedAt == nil
+}
+
+// hash returns the hex encoded sha256 hash of the JSON encoded receipt
+func (r *ErasureReceipt) hash() (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (f ErasureFailures) Error() string {
+	ids := make([]int, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("erasure %d: %s", id, f[id])
+	}
+	return fmt.Sprintf("%d erasure(s) failed: %s", len(f), strings.Join(msgs, "; "))
+}
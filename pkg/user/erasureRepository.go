@@ -0,0 +1,217 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// CreateErasure inserts the erasure and soft deletes its user unless it is already deleted,
+// the deletion is recorded in the audit trail
+func (r *PGRepo) CreateErasure(ctx context.Context, e *Erasure) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, e).Insert(); err != nil {
+			return
+		}
+
+		// a user scheduled for erasure is hidden right away
+		before := &User{ID: e.UserID}
+		if err = tenant.Query(ctx, tx, before).AllWithDeleted().WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		if before.DeletedAt != nil {
+			return
+		}
+		after := *before
+		after.DeletedAt = e.RequestedAt
+		after.UpdatedAt = e.RequestedAt
+		_, err = tenant.Query(ctx, tx, &after).
+			Set("deleted_at = ?deleted_at").
+			Set("updated_at = ?updated_at").
+			Set("version = version + 1").
+			WherePK().
+			Returning("version").
+			Update()
+		if err != nil {
+			return
+		}
+		_, err = tenant.Query(ctx, tx, (*Phone)(nil)).
+			Set("deleted_at = ?", e.RequestedAt).
+			Where("user_id = ?", e.UserID).
+			Update()
+		if err != nil {
+			return
+		}
+		return r.insertAudit(ctx, tx, AuditDelete, before, &after)
+	})
+}
+
+func (r *PGRepo) CancelErasure(ctx context.Context, e *Erasure) (err error) {
+	res, err := tenant.Query(ctx, r.db, e).
+		Column("cancelled_at").
+		WherePK().
+		Where("cancelled_at IS NULL").
+		Where("completed_at IS NULL").
+		Update()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+// FetchErasure fetches the latest erasure requested for the user
+func (r *PGRepo) FetchErasure(ctx context.Context, userID int) (erasure *Erasure, err error) {
+	erasure = &Erasure{}
+	err = tenant.Query(ctx, r.db, erasure).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(1).
+		Select()
+	return
+}
+
+// FetchDueErasures fetches pending erasures whose grace period has ended by `now`, in the order they are due.
+// Erasures up to `after` are skipped if it is not nil.
+func (r *PGRepo) FetchDueErasures(ctx context.Context, now time.Time, after *Erasure, limit int) (erasures []Erasure, err error) {
+	erasures = []Erasure{}
+	query := tenant.Query(ctx, r.db, &erasures).
+		Where("scheduled_for <= ?", now).
+		Where("cancelled_at IS NULL").
+		Where("completed_at IS NULL")
+	if after != nil {
+		query.Where("(scheduled_for, id) > (?, ?)", after.ScheduledFor, after.ID)
+	}
+	err = query.
+		Order("scheduled_for ASC", "id ASC").
+		Limit(limit).
+		Select()
+	return
+}
+
+// EraseUser clears the PII of the user of a pending erasure, deletes its phones, emails and addresses,
+// records the erasure in the audit trail, strips the values from the audit trail and completes the erasure
+// with its receipt.
+// The user row is kept as a tombstone.
+// An erasure cancelled or completed in the meanwhile is left untouched.
+func (r *PGRepo) EraseUser(ctx context.Context, e *Erasure) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if err = tenant.Query(ctx, tx, e).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		if !e.IsPending() {
+			return
+		}
+
+		now := time.Now()
+		before := &User{ID: e.UserID}
+		if err = tenant.Query(ctx, tx, before).AllWithDeleted().WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		query := tenant.Query(ctx, tx, (*User)(nil)).AllWithDeleted()
+		for _, field := range erasedFields {
+			query.Set("? = NULL", pg.Ident(field))
+		}
+		_, err = query.
+			Set("erased_at = ?", now).
+			Set("updated_at = ?", now).
+			Set("deleted_at = COALESCE(deleted_at, ?)", now).
+			Set("version = version + 1").
+			Where("id = ?", e.UserID).
+			Update()
+		if err != nil {
+			return
+		}
+		// the values of the erased fields are stripped from the audit trail along with the older changes below
+		after := &User{ID: e.UserID}
+		if err = tenant.Query(ctx, tx, after).AllWithDeleted().WherePK().Select(); err != nil {
+			return
+		}
+		if err = r.insertAudit(ctx, tx, AuditErase, before, after); err != nil {
+			return
+		}
+
+		phones, err := tenant.Query(ctx, tx, (*Phone)(nil)).AllWithDeleted().
+			Where("user_id = ?", e.UserID).
+			ForceDelete()
+		if err != nil {
+			return
+		}
+		emails, err := tenant.Query(ctx, tx, (*Email)(nil)).
+			Where("user_id = ?", e.UserID).
+			ForceDelete()
+		if err != nil {
+			return
+		}
+		addresses, err := tenant.Query(ctx, tx, (*Address)(nil)).
+			Where("user_id = ?", e.UserID).
+			ForceDelete()
+		if err != nil {
+			return
+		}
+		relationships, err := tenant.Query(ctx, tx, (*Relationship)(nil)).
+			WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+				return q.Where("user_id = ?", e.UserID).WhereOr("related_user_id = ?", e.UserID), nil
+			}).
+			Delete()
+		if err != nil {
+			return
+		}
+		preferences, err := tenant.Query(ctx, tx, (*Preference)(nil)).
+			Where("user_id = ?", e.UserID).
+			Delete()
+		if err != nil {
+			return
+		}
+		// consent events stay as a proof of consent, without the IP address and user agent of the user,
+		// the events of the users merged into the user are its events as well
+		consentEvents, err := tenant.Query(ctx, tx, (*ConsentEvent)(nil)).
+			Set("ip_address = NULL").
+			Set("user_agent = NULL").
+			Where("user_id IN "+mergedUserIDs, e.UserID, e.UserID).
+			Update()
+		if err != nil {
+			return
+		}
+
+		// the audit trail, along with the audit trails of the users merged into the user, keeps which fields
+		// changed but not their values
+		_, err = tenant.Query(ctx, tx, (*Audit)(nil)).
+			Set(`changes = (SELECT coalesce(jsonb_agg(c - 'before' - 'after'), '[]') FROM jsonb_array_elements(changes) c)`).
+			Where("user_id IN "+mergedUserIDs, e.UserID, e.UserID).
+			Update()
+		if err != nil {
+			return
+		}
+
+		e.Receipt = &ErasureReceipt{
+			ErasureID:        e.ID,
+			UserID:           e.UserID,
+			ErasedFields:     erasedFields,
+			DeletedPhones:    phones.RowsAffected(),
+			DeletedEmails:    emails.RowsAffected(),
+			DeletedAddresses: addresses.RowsAffected(),
+			ErasedAt:         now,
+
+			DeletedRelationships: relationships.RowsAffected(),
+			DeletedPreferences:   preferences.RowsAffected(),
+
+			AnonymizedConsentEvents: consentEvents.RowsAffected(),
+		}
+		if e.ReceiptHash, err = e.Receipt.hash(); err != nil {
+			return
+		}
+		e.CompletedAt = &now
+		_, err = tenant.Query(ctx, tx, e).
+			Column("completed_at", "receipt", "receipt_hash").
+			WherePK().
+			Update()
+		return
+	})
+}
@@ -0,0 +1,150 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// erasureBatchSize is the number of due erasures processed per query
+const erasureBatchSize = 100
+
+// ScheduleErasure schedules the permanent erasure of the user after the configured grace period.
+// The user is soft deleted right away.
+func (s *Service) ScheduleErasure(ctx context.Context, userID int, req ErasureRequest) (erasure *Erasure, err error) {
+	user, err := s.Repo.FetchIncludingDeleted(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if user.ErasedAt != nil {
+		err = er.New(errors.New("user is already erased"), er.UserErased).SetStatus(http.StatusGone)
+		return
+	}
+	if err = s.checkNoPendingErasure(ctx, userID); err != nil {
+		return
+	}
+
+	now := time.Now()
+	scheduledFor := now.Add(s.conf.GetDuration("erasure_grace_period"))
+	erasure = &Erasure{
+		UserID:       userID,
+		RequestedBy:  req.RequestedBy,
+		Reason:       req.Reason,
+		RequestedAt:  &now,
+		ScheduledFor: &scheduledFor,
+	}
+	err = s.Repo.CreateErasure(ctx, erasure)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.ErasureScheduled).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+// CancelErasure cancels the pending erasure of the user.
+// The user stays soft deleted and can be restored with `RestoreUser`.
+func (s *Service) CancelErasure(ctx context.Context, userID int) (erasure *Erasure, err error) {
+	erasure, err = s.FetchErasure(ctx, userID)
+	if err != nil {
+		return
+	}
+	if !erasure.IsPending() {
+		err = er.New(errors.New("no pending erasure"), er.ErasureNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	erasure.CancelledAt = &now
+	err = s.Repo.CancelErasure(ctx, erasure)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.ErasureNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// FetchErasure fetches the latest erasure of the user along with its receipt once completed
+func (s *Service) FetchErasure(ctx context.Context, userID int) (erasure *Erasure, err error) {
+	erasure, err = s.Repo.FetchErasure(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.ErasureNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// ProcessDueErasures erases all users whose erasure grace period has ended, of all tenants if the context
+// is `tenant.Unscoped`. It returns the number of users erased. An erasure which fails is logged and left
+// pending for the next run while the others are processed, the failures are returned as `ErasureFailures`.
+func (s *Service) ProcessDueErasures(ctx context.Context) (erased int, err error) {
+	// erasures are recorded in the audit trail as made by the worker
+	ctx = WithAuditInfo(ctx, AuditInfo{Actor: "worker"})
+	var (
+		now      = time.Now()
+		after    *Erasure
+		failures = ErasureFailures{}
+	)
+	for {
+		erasures, err := s.Repo.FetchDueErasures(ctx, now, after, erasureBatchSize)
+		if err != nil {
+			return erased, err
+		}
+		if len(erasures) > 0 {
+			// failed erasures are still pending, the next batch starts after this one so they are not fetched again
+			last := erasures[len(erasures)-1]
+			after = &last
+		}
+		for i := range erasures {
+			// due erasures of all tenants are fetched, each one is processed in its tenant
+			eCtx := tenant.WithID(ctx, erasures[i].TenantID)
+			if err = s.Repo.EraseUser(eCtx, &erasures[i]); err != nil {
+				s.log.WithFields(logrus.Fields{
+					"erasure_id": erasures[i].ID,
+					"user_id":    erasures[i].UserID,
+				}).WithError(err).Error("user erasure failed")
+				failures[erasures[i].ID] = err
+				continue
+			}
+			if erasures[i].CompletedAt == nil {
+				// cancelled after it was fetched
+				continue
+			}
+			// uploaded files of the user, eg. profile pictures
+			s.deleteBlobs(eCtx, fmt.Sprintf("users/%d", erasures[i].UserID))
+			s.log.WithFields(logrus.Fields{
+				"erasure_id":   erasures[i].ID,
+				"user_id":      erasures[i].UserID,
+				"receipt_hash": erasures[i].ReceiptHash,
+			}).Info("user erased")
+			erased++
+		}
+		if len(erasures) < erasureBatchSize {
+			if len(failures) > 0 {
+				return erased, failures
+			}
+			return erased, nil
+		}
+	}
+}
+
+// checkNoPendingErasure returns `er.ErasureScheduled` if the user has a pending erasure
+func (s *Service) checkNoPendingErasure(ctx context.Context, userID int) error {
+	erasure, err := s.Repo.FetchErasure(ctx, userID)
+	switch {
+	case err == pg.ErrNoRows:
+		return nil
+	case err != nil:
+		return err
+	case erasure.IsPending():
+		return er.New(errors.New("user is scheduled for erasure"), er.ErasureScheduled).SetStatus(http.StatusConflict)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// GroupRole is the role of a member in a group
+type GroupRole string
+
+// group roles, a group has at least one owner
+const (
+	GroupRoleOwner  GroupRole = "owner"
+	GroupRoleAdmin  GroupRole = "admin"
+	GroupRoleMember GroupRole = "member"
+)
+
+// ErrLastGroupOwner is returned when the last owner of a group would be removed or demoted
+var ErrLastGroupOwner = errors.New("last group owner")
+
+type (
+	// Group is a set of users, eg. a household or a B2B team
+	Group struct {
+		tableName   struct{}   `pg:"groups,discard_unknown_columns"`
+		ID          int        `json:"id" pg:"id"`
+		Name        string     `json:"name" pg:"name,notnull"`
+		Description string     `json:"description" pg:"description"`
+		CreatedAt   *time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt   *time.Time `json:"updated_at" pg:"updated_at"`
+
+		tenant.Owned
+	}
+
+	// GroupMember is the membership of a user in a group
+	GroupMember struct {
+		tableName struct{}   `pg:"group_members,discard_unknown_columns"`
+		GroupID   int        `json:"group_id" pg:"group_id,pk"`
+		Group     *Group     `json:"group,omitempty" pg:"rel:has-one"`
+		UserID    int        `json:"user_id" pg:"user_id,pk"`
+		User      *User      `json:"user,omitempty" pg:"rel:has-one"`
+		Role      GroupRole  `json:"role" pg:"role,notnull"`
+		JoinedAt  *time.Time `json:"joined_at" pg:"joined_at"`
+
+		tenant.Owned
+	}
+
+	// GroupRequest is the request body of create group API, the owner is the first member of the group
+	GroupRequest struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description,omitempty"`
+		OwnerID     int    `json:"owner_id" binding:"required"`
+	}
+
+	// GroupMemberRequest is the request body of add member API, the role defaults to member
+	GroupMemberRequest struct {
+		UserID int       `json:"user_id" binding:"required"`
+		Role   GroupRole `json:"role,omitempty"`
+	}
+
+	// GroupRoleRequest is the request body of update member API
+	GroupRoleRequest struct {
+		Role GroupRole `json:"role" binding:"required"`
+	}
+
+	// MembershipRequest is the query of list members and list groups of a user APIs
+	MembershipRequest struct {
+		Role  *string `form:"role,omitempty"`
+		Page  int     `form:"page,default=1"`
+		Limit int     `form:"limit,default=20"`
+	}
+)
+
+// validate returns `er.InvalidGroupRole` if the role is not known
+func (r GroupRole) validate() error {
+	switch r {
+	case GroupRoleOwner, GroupRoleAdmin, GroupRoleMember:
+		return nil
+	}
+	err := errors.New("invalid group role " + string(r))
+	return er.New(err, er.InvalidGroupRole).SetStatus(http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,147 @@
+package user
+
+import (
+	"context"
+	"math"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// CreateGroup inserts the group along with its first owner
+func (r *PGRepo) CreateGroup(ctx context.Context, g *Group, owner *GroupMember) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, g).Insert(); err != nil {
+			return
+		}
+		owner.GroupID = g.ID
+		_, err = tenant.Query(ctx, tx, owner).Insert()
+		return
+	})
+}
+
+func (r *PGRepo) FetchGroup(ctx context.Context, groupID int) (group *Group, err error) {
+	group = &Group{ID: groupID}
+	err = tenant.Query(ctx, r.db, group).WherePK().Select()
+	return
+}
+
+func (r *PGRepo) AddGroupMember(ctx context.Context, m *GroupMember) (err error) {
+	_, err = tenant.Query(ctx, r.db, m).Insert()
+	return
+}
+
+// UpdateGroupMember changes the role of the member. It returns `ErrLastGroupOwner` if the member
+// is the last owner of the group and would no longer be an owner.
+func (r *PGRepo) UpdateGroupMember(ctx context.Context, m *GroupMember) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		current, err := r.lockGroupMember(ctx, tx, m.GroupID, m.UserID)
+		if err != nil {
+			return
+		}
+		if current.Role == GroupRoleOwner && m.Role != GroupRoleOwner {
+			if err = r.checkOtherOwner(ctx, tx, m.GroupID, m.UserID); err != nil {
+				return
+			}
+		}
+		_, err = tenant.Query(ctx, tx, m).Column("role").WherePK().Update()
+		return
+	})
+}
+
+// RemoveGroupMember removes the user from the group. It returns `ErrLastGroupOwner` if the user
+// is the last owner of the group.
+func (r *PGRepo) RemoveGroupMember(ctx context.Context, groupID, userID int) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		current, err := r.lockGroupMember(ctx, tx, groupID, userID)
+		if err != nil {
+			return
+		}
+		if current.Role == GroupRoleOwner {
+			if err = r.checkOtherOwner(ctx, tx, groupID, userID); err != nil {
+				return
+			}
+		}
+		_, err = tenant.Query(ctx, tx, current).WherePK().Delete()
+		return
+	})
+}
+
+func (r *PGRepo) FetchGroupMember(ctx context.Context, groupID, userID int) (member *GroupMember, err error) {
+	member = &GroupMember{GroupID: groupID, UserID: userID}
+	err = tenant.Query(ctx, r.db, member).
+		Relation("User").
+		WherePK().
+		Select()
+	return
+}
+
+// FetchGroupMembers fetches the members of the group in order of joining, members which are deleted users are left out
+func (r *PGRepo) FetchGroupMembers(ctx context.Context, groupID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error) {
+	members = []GroupMember{}
+	query := tenant.Query(ctx, r.db, &members).
+		Relation("User").
+		Where("group_member.group_id = ?", groupID).
+		Where(`"user".deleted_at IS NULL`).
+		Order("group_member.joined_at ASC", "group_member.user_id ASC")
+	err = paginateMemberships(query, req, &pagination)
+	return
+}
+
+// FetchUserGroups fetches the memberships of the user along with their groups, latest joined first
+func (r *PGRepo) FetchUserGroups(ctx context.Context, userID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error) {
+	members = []GroupMember{}
+	query := tenant.Query(ctx, r.db, &members).
+		Relation("Group").
+		Where("group_member.user_id = ?", userID).
+		Order("group_member.joined_at DESC", "group_member.group_id DESC")
+	err = paginateMemberships(query, req, &pagination)
+	return
+}
+
+// paginateMemberships selects the page of memberships, with the role filter of the request
+func paginateMemberships(query *orm.Query, req *MembershipRequest, pagination *Pagination) error {
+	if req.Role != nil {
+		query.Where("group_member.role = ?", *req.Role)
+	}
+	count, err := query.
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return err
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return nil
+}
+
+// lockGroupMember locks the group and selects the member, so that concurrent changes of
+// the owners of the group cannot leave it without an owner
+func (r *PGRepo) lockGroupMember(ctx context.Context, tx *pg.Tx, groupID, userID int) (member *GroupMember, err error) {
+	if err = tenant.Query(ctx, tx, &Group{ID: groupID}).WherePK().For("UPDATE").Select(); err != nil {
+		return
+	}
+	member = &GroupMember{GroupID: groupID, UserID: userID}
+	err = tenant.Query(ctx, tx, member).WherePK().Select()
+	return
+}
+
+// checkOtherOwner returns `ErrLastGroupOwner` if the user is the only owner of the group
+func (r *PGRepo) checkOtherOwner(ctx context.Context, tx *pg.Tx, groupID, userID int) error {
+	exists, err := tenant.Query(ctx, tx, (*GroupMember)(nil)).
+		Where("group_id = ?", groupID).
+		Where("user_id <> ?", userID).
+		Where("role = ?", GroupRoleOwner).
+		Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrLastGroupOwner
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// CreateGroup creates a group with the requested user as its owner
+func (s *Service) CreateGroup(ctx context.Context, req *GroupRequest) (group *Group, err error) {
+	if err = s.checkUserExists(ctx, req.OwnerID); err != nil {
+		return
+	}
+	now := time.Now()
+	group = &Group{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+	owner := &GroupMember{UserID: req.OwnerID, Role: GroupRoleOwner, JoinedAt: &now}
+	err = s.Repo.CreateGroup(ctx, group, owner)
+	return
+}
+
+func (s *Service) FetchGroup(ctx context.Context, groupID int) (group *Group, err error) {
+	group, err = s.Repo.FetchGroup(ctx, groupID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.GroupNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// AddGroupMember adds the user to the group, as a member unless another role is requested
+func (s *Service) AddGroupMember(ctx context.Context, groupID int, req *GroupMemberRequest) (member *GroupMember, err error) {
+	if req.Role == "" {
+		req.Role = GroupRoleMember
+	}
+	if err = req.Role.validate(); err != nil {
+		return
+	}
+	if _, err = s.FetchGroup(ctx, groupID); err != nil {
+		return
+	}
+	if err = s.checkUserExists(ctx, req.UserID); err != nil {
+		return
+	}
+
+	now := time.Now()
+	member = &GroupMember{GroupID: groupID, UserID: req.UserID, Role: req.Role, JoinedAt: &now}
+	err = s.Repo.AddGroupMember(ctx, member)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.GroupMemberAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	if err != nil {
+		return
+	}
+	return s.Repo.FetchGroupMember(ctx, groupID, req.UserID)
+}
+
+// UpdateGroupMember changes the role of the member, the last owner of a group cannot be demoted
+func (s *Service) UpdateGroupMember(ctx context.Context, groupID, userID int, req *GroupRoleRequest) (member *GroupMember, err error) {
+	if err = req.Role.validate(); err != nil {
+		return
+	}
+	err = s.Repo.UpdateGroupMember(ctx, &GroupMember{GroupID: groupID, UserID: userID, Role: req.Role})
+	if err = groupMemberError(err); err != nil {
+		return
+	}
+	return s.Repo.FetchGroupMember(ctx, groupID, userID)
+}
+
+// RemoveGroupMember removes the user from the group, the last owner of a group cannot be removed
+func (s *Service) RemoveGroupMember(ctx context.Context, groupID, userID int) (err error) {
+	return groupMemberError(s.Repo.RemoveGroupMember(ctx, groupID, userID))
+}
+
+// FetchGroupMembers lists the members of the group in order of joining
+func (s *Service) FetchGroupMembers(ctx context.Context, groupID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error) {
+	if err = validateRoleFilter(req); err != nil {
+		return
+	}
+	if _, err = s.FetchGroup(ctx, groupID); err != nil {
+		return
+	}
+	return s.Repo.FetchGroupMembers(ctx, groupID, req)
+}
+
+// FetchUserGroups lists the groups of the user along with the role of the user in each, latest joined first
+func (s *Service) FetchUserGroups(ctx context.Context, userID int, req *MembershipRequest) (members []GroupMember, pagination Pagination, err error) {
+	if err = validateRoleFilter(req); err != nil {
+		return
+	}
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	return s.Repo.FetchUserGroups(ctx, userID, req)
+}
+
+// groupMemberError maps the repository errors of changes of a member to `er` errors
+func groupMemberError(err error) error {
+	switch err {
+	case pg.ErrNoRows:
+		return er.New(err, er.GroupMemberNotFound).SetStatus(http.StatusNotFound)
+	case ErrLastGroupOwner:
+		return er.New(err, er.GroupOwnerRequired).SetStatus(http.StatusConflict)
+	}
+	return err
+}
+
+func validateRoleFilter(req *MembershipRequest) error {
+	if req.Role == nil {
+		return nil
+	}
+	return GroupRole(*req.Role).validate()
+}
@@ -0,0 +1,210 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// AuditMerge is the audit action of the changes of both users of a merge
+const AuditMerge = "merge"
+
+// mergedUserIDs selects the user along with the users merged into it. The audit trail and consent events
+// are never moved by a merge, so that they keep recording which account changed or consented, and the
+// records of a user are those of all of them.
+const mergedUserIDs = `(SELECT "id" FROM "user" WHERE "id" = ? OR "merged_into" = ?)`
+
+// MergeStrategy selects which of the two users of a merge a field of the surviving user is taken from.
+// An empty value never wins over a value.
+type MergeStrategy string
+
+// merge strategies
+const (
+	// MergeSurvivor keeps the value of the surviving user
+	MergeSurvivor MergeStrategy = "survivor"
+	// MergeMerged takes the value of the merged user
+	MergeMerged MergeStrategy = "merged"
+	// MergeNewest takes the value of the user updated last
+	MergeNewest MergeStrategy = "newest"
+	// MergeOldest takes the value of the user created first
+	MergeOldest MergeStrategy = "oldest"
+)
+
+// mergeFields are the fields of a user combined by precedence. The mobile of the surviving user is kept,
+// the phones of the merged user are added to it.
+var mergeFields = []string{"first_name", "last_name", "profile_picture", "dob", "metadata"}
+
+// MergePrecedence is the strategy of each merged field, fields left out keep the value of the surviving user.
+// The strategy of `metadata` picks the user whose keys win when the metadata of both users are deep merged.
+type MergePrecedence map[string]MergeStrategy
+
+type (
+	// UserMerge records the merge of a user into a surviving user
+	UserMerge struct {
+		tableName    struct{}        `pg:"user_merges,discard_unknown_columns"`
+		ID           int             `json:"id" pg:"id"`
+		SurvivorID   int             `json:"survivor_id" pg:"survivor_id,notnull"`
+		MergedUserID int             `json:"merged_user_id" pg:"merged_user_id,notnull"`
+		Precedence   MergePrecedence `json:"precedence" pg:"precedence,type:jsonb"`
+		// TakenFields are the fields of the surviving user taken from the merged user
+		TakenFields []string `json:"taken_fields" pg:"taken_fields,array"`
+		// Moved is the number of records of the merged user reassigned to the surviving user, by kind
+		Moved     map[string]int `json:"moved" pg:"moved,type:jsonb"`
+		Actor     string         `json:"actor,omitempty" pg:"actor"`
+		RequestID string         `json:"request_id,omitempty" pg:"request_id"`
+		CreatedAt time.Time      `json:"created_at" pg:"created_at"`
+
+		// Survivor is the surviving user after the merge
+		Survivor *User `json:"survivor,omitempty" pg:"-"`
+
+		tenant.Owned
+	}
+
+	// MergeRequest is the request body of merge users API, `Precedence` overrides the `merge_precedence` config
+	MergeRequest struct {
+		MergedUserID int             `json:"merged_user_id" binding:"required"`
+		Precedence   MergePrecedence `json:"precedence,omitempty"`
+	}
+)
+
+// ParseMergePrecedence parses comma separated `field=strategy` rules, eg. `dob=oldest,profile_picture=newest`
+func ParseMergePrecedence(s string) (MergePrecedence, error) {
+	p := MergePrecedence{}
+	for _, rule := range strings.Split(s, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		field, strategy, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid merge precedence rule %s", rule)
+		}
+		p[strings.TrimSpace(field)] = MergeStrategy(strings.TrimSpace(strategy))
+	}
+	return p, p.validate()
+}
+
+// validate returns `er.InvalidMerge` if a field or a strategy is not known
+func (p MergePrecedence) validate() error {
+	for field, strategy := range p {
+		if !containsString(mergeFields, field) {
+			err := errors.New("invalid merge field " + field)
+			return er.New(err, er.InvalidMerge).SetStatus(http.StatusUnprocessableEntity)
+		}
+		switch strategy {
+		case MergeSurvivor, MergeMerged, MergeNewest, MergeOldest:
+		default:
+			err := errors.New("invalid merge strategy " + string(strategy))
+			return er.New(err, er.InvalidMerge).SetStatus(http.StatusUnprocessableEntity)
+		}
+	}
+	return nil
+}
+
+// takeMerged reports whether the field is taken from the merged user
+func (p MergePrecedence) takeMerged(field string, survivor, merged *User, survivorEmpty, mergedEmpty bool) bool {
+	if mergedEmpty {
+		return false
+	}
+	if survivorEmpty {
+		return true
+	}
+	switch p[field] {
+	case MergeMerged:
+		return true
+	case MergeNewest:
+		return timeAfter(merged.UpdatedAt, survivor.UpdatedAt)
+	case MergeOldest:
+		return timeAfter(survivor.CreatedAt, merged.CreatedAt)
+	}
+	return false
+}
+
+// combineUsers returns the surviving user with the fields taken from the merged user by precedence,
+// along with the names of the fields taken
+func combineUsers(survivor, merged *User, p MergePrecedence) (combined *User, taken []string) {
+	c := *survivor
+	combined = &c
+	taken = []string{}
+
+	if p.takeMerged("first_name", survivor, merged, survivor.FirstName == "", merged.FirstName == "") {
+		combined.FirstName = merged.FirstName
+		taken = append(taken, "first_name")
+	}
+	if p.takeMerged("last_name", survivor, merged, survivor.LastName == "", merged.LastName == "") {
+		combined.LastName = merged.LastName
+		taken = append(taken, "last_name")
+	}
+	if p.takeMerged("profile_picture", survivor, merged, survivor.ProfilePicture == "", merged.ProfilePicture == "") {
+		combined.ProfilePicture = merged.ProfilePicture
+		combined.ProfilePictureVariants = merged.ProfilePictureVariants
+		combined.ProfilePictureKey = merged.ProfilePictureKey
+		taken = append(taken, "profile_picture")
+	}
+	if p.takeMerged("dob", survivor, merged, survivor.DOB == nil, merged.DOB == nil) {
+		combined.DOB = merged.DOB
+		taken = append(taken, "dob")
+	}
+
+	if merged.Metadata != nil {
+		winner, loser := survivor, merged
+		if p.takeMerged("metadata", survivor, merged, survivor.Metadata == nil, false) {
+			winner, loser = merged, survivor
+			taken = append(taken, "metadata")
+		}
+		combined.Metadata = deepMerge(winner.Metadata, loser.Metadata)
+		combined.MetadataNamespace = winner.MetadataNamespace
+		if combined.MetadataNamespace == "" {
+			combined.MetadataNamespace = loser.MetadataNamespace
+		}
+		// the merged metadata is validated against the latest schema of the namespace
+		combined.MetadataSchemaVersion = 0
+	}
+	return
+}
+
+// deepMerge merges the JSON objects recursively, values of `winner` win over values of `loser`
+// unless both are objects. Arrays are not merged.
+func deepMerge(winner, loser interface{}) interface{} {
+	w, wOK := winner.(map[string]interface{})
+	l, lOK := loser.(map[string]interface{})
+	if !wOK || !lOK {
+		if winner == nil {
+			return loser
+		}
+		return winner
+	}
+	merged := make(map[string]interface{}, len(w)+len(l))
+	for k, v := range l {
+		merged[k] = v
+	}
+	for k, v := range w {
+		if lv, ok := merged[k]; ok {
+			merged[k] = deepMerge(v, lv)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// timeAfter reports whether a is after b, a missing time is never after
+func timeAfter(a, b *time.Time) bool {
+	if a == nil {
+		return false
+	}
+	return b == nil || a.After(*b)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
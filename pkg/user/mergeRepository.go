@@ -0,0 +1,289 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// MergeUsers merges the merged user into the surviving user in one transaction. The combined fields are saved
+// to the survivor, the records of the merged user are reassigned to the survivor, and the merged user is deleted
+// and redirects to the survivor. It returns `ErrVersionConflict` if either user changed since it was read.
+func (r *PGRepo) MergeUsers(ctx context.Context, survivor, merged *User, merge *UserMerge) (err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if err = lockReferrals(ctx, tx, tenantID); err != nil {
+			return
+		}
+		if err = r.lockUsers(ctx, tx, survivor.ID, merged.ID); err != nil {
+			return
+		}
+		survivorBefore := &User{ID: survivor.ID}
+		if err = tenant.Query(ctx, tx, survivorBefore).WherePK().Select(); err != nil {
+			return
+		}
+		mergedBefore := &User{ID: merged.ID}
+		if err = tenant.Query(ctx, tx, mergedBefore).WherePK().Select(); err != nil {
+			return
+		}
+		if mergedBefore.Version != merged.Version {
+			return ErrVersionConflict
+		}
+
+		if merge.Moved, err = r.reassignUserRecords(ctx, tx, tenantID, merged.ID, survivor.ID); err != nil {
+			return
+		}
+		if err = r.mergeReferrer(ctx, tx, tenantID, survivorBefore, mergedBefore); err != nil {
+			return
+		}
+		if err = r.updateUser(ctx, tx, survivor); err != nil {
+			return
+		}
+
+		now := time.Now()
+		res, err := tenant.Query(ctx, tx, (*User)(nil)).
+			Set("merged_into = ?", survivor.ID).
+			Set("deleted_at = ?", now).
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id = ?", merged.ID).
+			Where("version = ?", merged.Version).
+			Update()
+		if err != nil {
+			return
+		}
+		if res.RowsAffected() == 0 {
+			return ErrVersionConflict
+		}
+		// users merged into the merged user before redirect to the survivor
+		res, err = tenant.Query(ctx, tx, (*User)(nil)).AllWithDeleted().
+			Set("merged_into = ?", survivor.ID).
+			Where("merged_into = ?", merged.ID).
+			Update()
+		if err != nil {
+			return
+		}
+		merge.Moved["merged_users"] = res.RowsAffected()
+
+		for _, u := range []*User{survivorBefore, mergedBefore} {
+			after := &User{ID: u.ID}
+			if err = tenant.Query(ctx, tx, after).AllWithDeleted().WherePK().Select(); err != nil {
+				return
+			}
+			if err = r.insertAudit(ctx, tx, AuditMerge, u, after); err != nil {
+				return
+			}
+		}
+		_, err = tenant.Query(ctx, tx, merge).Insert()
+		return
+	})
+}
+
+// mergeReferrer gives the survivor the referrer of the merged user if the survivor has no referrer or was referred
+// by the merged user, unless the survivor referred that referrer, directly or not
+func (r *PGRepo) mergeReferrer(ctx context.Context, tx *pg.Tx, tenantID int, survivor, merged *User) (err error) {
+	if survivor.ReferredBy != nil && *survivor.ReferredBy != merged.ID {
+		return
+	}
+	referredBy, referredAt := merged.ReferredBy, merged.ReferredAt
+	if referredBy != nil {
+		cycle, err := isReferrer(ctx, tx, tenantID, survivor.ID, *referredBy)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			referredBy, referredAt = nil, nil
+		}
+	}
+	if referredBy == nil && survivor.ReferredBy == nil {
+		return
+	}
+	_, err = tenant.Query(ctx, tx, (*User)(nil)).
+		Set("referred_by = ?", referredBy).
+		Set("referred_at = ?", referredAt).
+		Where("id = ?", survivor.ID).
+		Update()
+	return
+}
+
+// reassignUserRecords moves the records of a user to another user and returns the number of records moved
+// by kind. Records the other user already has, eg. tags and group memberships, are dropped, keeping the
+// highest role of group memberships. Relationships between the two users, and relationships breaking the
+// constraints of their type with the relationships of the other user, are dropped.
+func (r *PGRepo) reassignUserRecords(ctx context.Context, tx *pg.Tx, tenantID, fromID, toID int) (moved map[string]int, err error) {
+	moved = map[string]int{}
+	move := func(kind string, query *orm.Query) error {
+		res, err := query.Update()
+		if err != nil {
+			return err
+		}
+		moved[kind] += res.RowsAffected()
+		return nil
+	}
+
+	// the mobile of the surviving user stays its primary phone, and its primary email and default address stay
+	err = move("phones", tenant.Query(ctx, tx, (*Phone)(nil)).AllWithDeleted().
+		Set("user_id = ?", toID).
+		Set("is_primary = false").
+		Where("user_id = ?", fromID))
+	if err != nil {
+		return
+	}
+	err = move("emails", tenant.Query(ctx, tx, (*Email)(nil)).
+		Set("user_id = ?", toID).
+		Set("is_primary = is_primary AND NOT EXISTS (SELECT 1 FROM user_emails WHERE user_id = ? AND is_primary)", toID).
+		Where("user_id = ?", fromID))
+	if err != nil {
+		return
+	}
+	err = move("addresses", tenant.Query(ctx, tx, (*Address)(nil)).
+		Set("user_id = ?", toID).
+		Set("is_default = is_default AND NOT EXISTS (SELECT 1 FROM user_addresses WHERE user_id = ? AND is_default)", toID).
+		Where("user_id = ?", fromID))
+	if err != nil {
+		return
+	}
+
+	_, err = tenant.Query(ctx, tx, (*UserTag)(nil)).
+		Where("user_id = ?", fromID).
+		Where("tag_id IN (SELECT tag_id FROM user_tags WHERE user_id = ?)", toID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if err = move("tags", tenant.Query(ctx, tx, (*UserTag)(nil)).Set("user_id = ?", toID).Where("user_id = ?", fromID)); err != nil {
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE group_members AS t SET role = f.role
+		FROM group_members AS f
+		WHERE t.group_id = f.group_id AND t.user_id = ? AND f.user_id = ? AND t.tenant_id = ?
+		AND array_position(?::text[], f.role) < array_position(?::text[], t.role)`,
+		toID, fromID, tenantID, pg.Array(groupRoleRanks), pg.Array(groupRoleRanks))
+	if err != nil {
+		return
+	}
+	_, err = tenant.Query(ctx, tx, (*GroupMember)(nil)).
+		Where("user_id = ?", fromID).
+		Where("group_id IN (SELECT group_id FROM group_members WHERE user_id = ?)", toID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if err = move("groups", tenant.Query(ctx, tx, (*GroupMember)(nil)).Set("user_id = ?", toID).Where("user_id = ?", fromID)); err != nil {
+		return
+	}
+
+	_, err = tenant.Query(ctx, tx, (*Relationship)(nil)).
+		Where("user_id IN (?, ?) AND related_user_id IN (?, ?)", fromID, toID, fromID, toID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if err = r.moveRelationships(ctx, tx, fromID, toID, moved); err != nil {
+		return
+	}
+
+	if err = r.moveReferrals(ctx, tx, tenantID, fromID, toID, moved); err != nil {
+		return
+	}
+	if err = move("status_changes", tenant.Query(ctx, tx, (*StatusChange)(nil)).Set("user_id = ?", toID).Where("user_id = ?", fromID)); err != nil {
+		return
+	}
+	// preferences set by both users keep the value of the survivor
+	_, err = tenant.Query(ctx, tx, (*Preference)(nil)).
+		Where("user_id = ?", fromID).
+		Where("key IN (SELECT key FROM user_preferences WHERE user_id = ?)", toID).
+		Delete()
+	if err != nil {
+		return
+	}
+	if err = move("preferences", tenant.Query(ctx, tx, (*Preference)(nil)).Set("user_id = ?", toID).Where("user_id = ?", fromID)); err != nil {
+		return
+	}
+	err = move("picture_uploads", tenant.Query(ctx, tx, (*PictureUpload)(nil)).Set("user_id = ?", toID).Where("user_id = ?", fromID))
+	return
+}
+
+// moveRelationships moves the relationships from and to a user to another user, oldest first. A relationship
+// breaking the constraints of its type with the relationships of the other user, eg. a second spouse or
+// the reverse of a symmetric relationship it already has, is dropped.
+func (r *PGRepo) moveRelationships(ctx context.Context, tx *pg.Tx, fromID, toID int, moved map[string]int) (err error) {
+	rels := []Relationship{}
+	if err = relationshipsOf(ctx, tx, &rels, fromID).Order("relationship.id ASC").Select(); err != nil {
+		return
+	}
+	for i := range rels {
+		rel := &rels[i]
+		if rel.UserID == fromID {
+			rel.UserID = toID
+		} else {
+			rel.RelatedUserID = toID
+		}
+		err = r.checkRelationship(ctx, tx, rel)
+		switch {
+		case err == nil:
+			_, err = tenant.Query(ctx, tx, rel).Column("user_id", "related_user_id").WherePK().Update()
+			moved["relationships"]++
+		case isRelationshipConflict(err):
+			_, err = tenant.Query(ctx, tx, rel).WherePK().Delete()
+			moved["dropped_relationships"]++
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// moveReferrals makes the users referred by a user referred by another user. A referred user which is a referrer
+// of the other user, directly or not, would close a referral cycle and loses its referrer instead.
+func (r *PGRepo) moveReferrals(ctx context.Context, tx *pg.Tx, tenantID, fromID, toID int, moved map[string]int) (err error) {
+	referred := []User{}
+	err = tenant.Query(ctx, tx, &referred).AllWithDeleted().
+		Column("id").
+		Where("?TableAlias.referred_by = ?", fromID).
+		Where("?TableAlias.id <> ?", toID).
+		Select()
+	if err != nil {
+		return
+	}
+	for _, u := range referred {
+		cycle, err := isReferrer(ctx, tx, tenantID, u.ID, toID)
+		if err != nil {
+			return err
+		}
+		query := tenant.Query(ctx, tx, (*User)(nil)).AllWithDeleted().Where("id = ?", u.ID)
+		kind := "referrals"
+		if cycle {
+			query.Set("referred_by = NULL").Set("referred_at = NULL")
+			kind = "referral_cycles"
+		} else {
+			query.Set("referred_by = ?", toID)
+		}
+		if _, err = query.Update(); err != nil {
+			return err
+		}
+		moved[kind]++
+	}
+	return
+}
+
+// groupRoleRanks are the group roles, highest first
+var groupRoleRanks = []string{string(GroupRoleOwner), string(GroupRoleAdmin), string(GroupRoleMember)}
+
+func (r *PGRepo) FetchUserMerges(ctx context.Context, survivorID int) (merges []UserMerge, err error) {
+	merges = []UserMerge{}
+	err = tenant.Query(ctx, r.db, &merges).
+		Where("survivor_id = ?", survivorID).
+		Order("created_at DESC", "id DESC").
+		Select()
+	return
+}
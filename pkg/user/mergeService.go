@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MergeUsers merges a duplicate user into the surviving user. Fields are combined by the `merge_precedence`
+// config, overridden by the precedence of the request, and the metadata of both users is deep merged.
+// Phones, emails, addresses, tags, groups, relationships, referrals, preferences and the status history of the
+// merged user are reassigned to the survivor. Its audit trail and consent events stay as they are and count as
+// the history and consent of the survivor. The merged user is deleted and its ID redirects to the survivor,
+// see `FetchMergeSurvivor`. The merge is recorded, and in the audit trail of both users.
+func (s *Service) MergeUsers(ctx context.Context, survivorID int, req *MergeRequest) (merge *UserMerge, err error) {
+	if req.MergedUserID == survivorID {
+		err = errors.New("user cannot be merged into itself")
+		return nil, er.New(err, er.InvalidMerge).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err = req.Precedence.validate(); err != nil {
+		return
+	}
+	precedence := MergePrecedence{}
+	for field, strategy := range s.mergePrecedence {
+		precedence[field] = strategy
+	}
+	for field, strategy := range req.Precedence {
+		precedence[field] = strategy
+	}
+
+	survivor, err := s.fetchUserToMerge(ctx, survivorID)
+	if err != nil {
+		return
+	}
+	merged, err := s.fetchUserToMerge(ctx, req.MergedUserID)
+	if err != nil {
+		return
+	}
+	combined, taken := combineUsers(survivor, merged, precedence)
+	if err = s.validateMetadata(ctx, combined); err != nil {
+		return
+	}
+
+	info := auditInfoFrom(ctx)
+	merge = &UserMerge{
+		SurvivorID:   survivorID,
+		MergedUserID: merged.ID,
+		Precedence:   precedence,
+		TakenFields:  taken,
+		Actor:        info.Actor,
+		RequestID:    info.RequestID,
+		CreatedAt:    time.Now(),
+	}
+	err = s.Repo.MergeUsers(ctx, combined, merged, merge)
+	switch err {
+	case nil:
+	case pg.ErrNoRows:
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	case ErrVersionConflict:
+		return nil, er.New(err, er.VersionMismatch).SetStatus(http.StatusPreconditionFailed)
+	default:
+		return nil, err
+	}
+	merge.Survivor, err = s.Repo.Fetch(ctx, survivorID)
+	return
+}
+
+// FetchMergeSurvivor returns the user the deleted user was merged into, nil if the user was not merged
+func (s *Service) FetchMergeSurvivor(ctx context.Context, userID int) (survivor *User, err error) {
+	user, err := s.Repo.FetchIncludingDeleted(ctx, userID)
+	if err == pg.ErrNoRows || (err == nil && user.MergedInto == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	survivor, err = s.Repo.Fetch(ctx, *user.MergedInto)
+	if err == pg.ErrNoRows {
+		// the survivor was deleted since
+		return nil, nil
+	}
+	return
+}
+
+// FetchUserMerges lists the users merged into the user, latest first
+func (s *Service) FetchUserMerges(ctx context.Context, userID int) (merges []UserMerge, err error) {
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	return s.Repo.FetchUserMerges(ctx, userID)
+}
+
+// fetchUserToMerge fetches a user of a merge, users scheduled for erasure cannot be merged
+func (s *Service) fetchUserToMerge(ctx context.Context, userID int) (user *User, err error) {
+	user, err = s.Repo.Fetch(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	err = s.checkNoPendingErasure(ctx, userID)
+	return
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMergePrecedence(t *testing.T) {
+	tests := []struct {
+		s    string
+		want MergePrecedence
+	}{
+		{"", MergePrecedence{}},
+		{"dob=oldest", MergePrecedence{"dob": MergeOldest}},
+		{" dob = oldest , profile_picture=newest,,metadata=merged,first_name=survivor ", MergePrecedence{
+			"dob":             MergeOldest,
+			"profile_picture": MergeNewest,
+			"metadata":        MergeMerged,
+			"first_name":      MergeSurvivor,
+		}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMergePrecedence(tt.s)
+		if err != nil {
+			t.Errorf("ParseMergePrecedence(%q) failed: %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMergePrecedence(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"dob",
+		"dob=youngest",
+		"mobile=merged",
+		"DOB=oldest",
+		"dob=",
+	} {
+		if _, err := ParseMergePrecedence(s); err == nil {
+			t.Errorf("ParseMergePrecedence(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestCombineUsers(t *testing.T) {
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	dob1 := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	dob2 := time.Date(1991, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// the survivor was created first and the merged user updated last
+	survivor := &User{
+		ID: 1, FirstName: "Jon", LastName: "", DOB: &dob1, CreatedAt: &older, UpdatedAt: &older,
+		Metadata: map[string]interface{}{"os": "ios", "device": map[string]interface{}{"model": "x"}},
+	}
+	merged := &User{
+		ID: 2, FirstName: "John", LastName: "Smith", DOB: &dob2, ProfilePicture: "https://cdn/2.jpg",
+		CreatedAt: &newer, UpdatedAt: &newer,
+		Metadata: map[string]interface{}{"os": "android", "device": map[string]interface{}{"year": 2022.0}},
+	}
+
+	tests := []struct {
+		name       string
+		precedence MergePrecedence
+		firstName  string
+		dob        *time.Time
+		os         string
+		taken      []string
+	}{
+		// empty fields of the survivor are always filled
+		{"survivor", MergePrecedence{}, "Jon", &dob1, "ios", []string{"last_name", "profile_picture"}},
+		{"merged", MergePrecedence{"first_name": MergeMerged, "metadata": MergeMerged}, "John", &dob1, "android",
+			[]string{"first_name", "last_name", "profile_picture", "metadata"}},
+		{"newest", MergePrecedence{"first_name": MergeNewest, "dob": MergeNewest}, "John", &dob2, "ios",
+			[]string{"first_name", "last_name", "profile_picture", "dob"}},
+		{"oldest", MergePrecedence{"first_name": MergeOldest, "dob": MergeOldest}, "Jon", &dob1, "ios",
+			[]string{"last_name", "profile_picture"}},
+	}
+	for _, tt := range tests {
+		got, taken := combineUsers(survivor, merged, tt.precedence)
+		if got.ID != survivor.ID || got.FirstName != tt.firstName || got.LastName != "Smith" || !got.DOB.Equal(*tt.dob) {
+			t.Errorf("%s: combined %+v", tt.name, got)
+		}
+		metadata := got.Metadata.(map[string]interface{})
+		if metadata["os"] != tt.os {
+			t.Errorf("%s: combined metadata os %v, want %s", tt.name, metadata["os"], tt.os)
+		}
+		// nested objects of both users are kept
+		if device := metadata["device"].(map[string]interface{}); device["model"] != "x" || device["year"] != 2022.0 {
+			t.Errorf("%s: combined metadata device %v", tt.name, device)
+		}
+		if !reflect.DeepEqual(taken, tt.taken) {
+			t.Errorf("%s: taken %v, want %v", tt.name, taken, tt.taken)
+		}
+	}
+
+	// the users are left unchanged
+	if survivor.FirstName != "Jon" || survivor.LastName != "" || merged.FirstName != "John" {
+		t.Errorf("combineUsers changed the users: %+v, %+v", survivor, merged)
+	}
+}
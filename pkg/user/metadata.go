@@ -0,0 +1,85 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultMetadataNamespace is the metadata namespace of users created without one
+const DefaultMetadataNamespace = "default"
+
+type (
+	// MetadataSchema is a JSON Schema the `User.Metadata` of a namespace is validated against.
+	// A namespace is usually a client app, and its schemas are versioned.
+	MetadataSchema struct {
+		tableName struct{}    `pg:"metadata_schemas,discard_unknown_columns"`
+		ID        int         `json:"id" pg:"id"`
+		Namespace string      `json:"namespace" pg:"namespace,notnull"`
+		Version   int         `json:"version" pg:"version,notnull"`
+		Schema    interface{} `json:"schema" pg:"schema,type:jsonb,notnull"`
+		CreatedAt *time.Time  `json:"created_at" pg:"created_at"`
+		UpdatedAt *time.Time  `json:"updated_at" pg:"updated_at"`
+
+		tenant.Owned
+	}
+
+	// MetadataSchemaRequest is the request body of save metadata schema API
+	MetadataSchemaRequest struct {
+		Schema interface{} `json:"schema" binding:"required"`
+	}
+)
+
+// compile compiles the JSON Schema. References to remote schemas are not allowed.
+func (m *MetadataSchema) compile() (*jsonschema.Schema, error) {
+	b, err := json.Marshal(m.Schema)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("metadata://%s/%d", m.Namespace, m.Version)
+
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("remote schema %s not allowed", s)
+	}
+	if err = c.AddResource(url, bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return c.Compile(url)
+}
+
+// validate validates the metadata against the schema, the returned error lists the fields failing validation
+func (m *MetadataSchema) validate(metadata interface{}) error {
+	schema, err := m.compile()
+	if err != nil {
+		return err
+	}
+	err = schema.Validate(metadata)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	return er.New(err, er.InvalidMetadata).
+		SetStatus(http.StatusUnprocessableEntity).
+		SetFields(metadataFieldErrors(ve)...)
+}
+
+// metadataFieldErrors flattens the validation error to its leaf causes, with paths relative to the request body
+func metadataFieldErrors(ve *jsonschema.ValidationError) (fields []er.FieldError) {
+	if len(ve.Causes) == 0 {
+		return []er.FieldError{{Path: "/metadata" + ve.InstanceLocation, Message: ve.Message}}
+	}
+	for _, cause := range ve.Causes {
+		fields = append(fields, metadataFieldErrors(cause)...)
+	}
+	return
+}
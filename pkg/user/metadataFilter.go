@@ -0,0 +1,218 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// operators of metadata filters
+const (
+	MetadataEq       = "eq"
+	MetadataNe       = "ne"
+	MetadataGt       = "gt"
+	MetadataGte      = "gte"
+	MetadataLt       = "lt"
+	MetadataLte      = "lte"
+	MetadataExists   = "exists"
+	MetadataContains = "contains"
+)
+
+// kinds of metadata indexes
+const (
+	MetadataIndexText    = "text"
+	MetadataIndexNumeric = "numeric"
+)
+
+// maxIdentifierLength is the length Postgres truncates identifiers to
+const maxIdentifierLength = 63
+
+var (
+	metadataKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	metadataComparisons = map[string]string{
+		MetadataGt:  ">",
+		MetadataGte: ">=",
+		MetadataLt:  "<",
+		MetadataLte: "<=",
+	}
+)
+
+type (
+	// MetadataFilter filters users by the value at a path of their metadata.
+	// It is written as `<path>:<op>[:<value>]` where path is a dot separated list of keys, eg. `device.os:eq:android`.
+	MetadataFilter struct {
+		Path  []string
+		Op    string
+		Value string
+	}
+
+	// MetadataIndex is an expression index on a path of the metadata, declared for frequently filtered keys.
+	// Numeric indexes serve `gt`, `gte`, `lt` and `lte` filters with numeric values, text indexes the others.
+	MetadataIndex struct {
+		Path []string
+		Kind string
+	}
+)
+
+// ParseMetadataFilter parses a filter written as `<path>:<op>[:<value>]`
+func ParseMetadataFilter(s string) (f MetadataFilter, err error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return f, invalidMetadataFilter(s, "expected <path>:<op>[:<value>]")
+	}
+	if f.Path, err = parseMetadataPath(parts[0]); err != nil {
+		return f, invalidMetadataFilter(s, err.Error())
+	}
+	f.Op = parts[1]
+	if len(parts) == 3 {
+		f.Value = parts[2]
+	}
+
+	switch f.Op {
+	case MetadataExists:
+		if len(parts) == 3 {
+			return f, invalidMetadataFilter(s, "exists takes no value")
+		}
+	case MetadataEq, MetadataNe, MetadataGt, MetadataGte, MetadataLt, MetadataLte, MetadataContains:
+		if len(parts) < 3 {
+			return f, invalidMetadataFilter(s, f.Op+" requires a value")
+		}
+	default:
+		return f, invalidMetadataFilter(s, "unknown operator "+f.Op)
+	}
+	return
+}
+
+// ParseMetadataIndex parses an index declared as `<path>[:text|numeric]`.
+// Paths whose index name would be truncated by Postgres are rejected.
+func ParseMetadataIndex(s string) (idx MetadataIndex, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if idx.Path, err = parseMetadataPath(parts[0]); err != nil {
+		return
+	}
+	idx.Kind = MetadataIndexText
+	if len(parts) == 2 {
+		idx.Kind = parts[1]
+	}
+	if idx.Kind != MetadataIndexText && idx.Kind != MetadataIndexNumeric {
+		return idx, fmt.Errorf("unknown metadata index kind %q", idx.Kind)
+	}
+	if len(idx.Name()) > maxIdentifierLength {
+		err = fmt.Errorf("metadata index name %s is longer than %d bytes", idx.Name(), maxIdentifierLength)
+	}
+	return
+}
+
+// ParseMetadataIndexes parses a comma separated list of indexes, see `ParseMetadataIndex`.
+// Indexes of different paths with the same name, eg. `a-b`, `a_b` and `a.b`, are rejected.
+func ParseMetadataIndexes(s string) (indexes []MetadataIndex, err error) {
+	declared := map[string]string{}
+	for _, decl := range strings.Split(s, ",") {
+		if decl = strings.TrimSpace(decl); decl == "" {
+			continue
+		}
+		idx, err := ParseMetadataIndex(decl)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := declared[idx.Name()]; ok {
+			return nil, fmt.Errorf("metadata indexes %s and %s are both named %s", other, decl, idx.Name())
+		}
+		declared[idx.Name()] = decl
+		indexes = append(indexes, idx)
+	}
+	return
+}
+
+// Name returns the name of the index, eg. `user_metadata_device_os_idx`
+func (idx MetadataIndex) Name() string {
+	name := "user_metadata_" + strings.ReplaceAll(strings.Join(idx.Path, "_"), "-", "_")
+	if idx.Kind == MetadataIndexNumeric {
+		name += "_num"
+	}
+	return name + "_idx"
+}
+
+// expr returns the indexed expression, the same expression filters are written with
+func (idx MetadataIndex) expr() *orm.SafeQueryAppender {
+	if idx.Kind == MetadataIndexNumeric {
+		return metadataNumber(idx.Path)
+	}
+	return metadataText(idx.Path)
+}
+
+// apply adds the filter to the query. Paths and values are passed as query params.
+func (f MetadataFilter) apply(q *orm.Query) error {
+	switch f.Op {
+	case MetadataEq:
+		// compared as text so that eg. `true` matches both the boolean and the string
+		q.Where("? = ?", metadataText(f.Path), f.Value)
+	case MetadataNe:
+		q.Where("? IS DISTINCT FROM ?", metadataText(f.Path), f.Value)
+	case MetadataExists:
+		q.Where("metadata #> ?::text[] IS NOT NULL", pg.Array(f.Path))
+	case MetadataContains:
+		var v interface{}
+		if err := json.Unmarshal([]byte(f.Value), &v); err != nil {
+			// not JSON, matched as a string
+			v = f.Value
+		}
+		for i := len(f.Path) - 1; i >= 0; i-- {
+			v = map[string]interface{}{f.Path[i]: v}
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		q.Where("metadata @> ?::jsonb", string(b))
+	default:
+		op := metadataComparisons[f.Op]
+		if _, err := strconv.ParseFloat(f.Value, 64); err == nil {
+			q.Where("? "+op+" ?::numeric", metadataNumber(f.Path), f.Value)
+		} else {
+			q.Where("? "+op+" ?", metadataText(f.Path), f.Value)
+		}
+	}
+	return nil
+}
+
+// metadataText is the text value at the path of the metadata
+func metadataText(path []string) *orm.SafeQueryAppender {
+	return pg.SafeQuery("(metadata #>> ?::text[])", pg.Array(path))
+}
+
+// metadataNumber is the numeric value at the path of the metadata, null unless the value is
+// a number or a string of a number. The cast is guarded so that other values never fail the query.
+func metadataNumber(path []string) *orm.SafeQueryAppender {
+	return pg.SafeQuery(
+		`(CASE WHEN metadata #>> ?::text[] ~ '^-{0,1}[0-9]+(\.[0-9]+){0,1}$' THEN (metadata #>> ?::text[])::numeric END)`,
+		pg.Array(path), pg.Array(path),
+	)
+}
+
+// parseMetadataPath splits a dot separated path of metadata keys
+func parseMetadataPath(s string) ([]string, error) {
+	path := strings.Split(s, ".")
+	for _, key := range path {
+		if !metadataKeyRe.MatchString(key) {
+			return nil, errors.New("invalid metadata path " + s)
+		}
+	}
+	return path, nil
+}
+
+func invalidMetadataFilter(filter, reason string) error {
+	return er.New(fmt.Errorf("%s: %s", filter, reason), er.InvalidMetadataFilter).
+		SetStatus(http.StatusUnprocessableEntity).
+		SetFields(er.FieldError{Path: "meta", Message: reason})
+}
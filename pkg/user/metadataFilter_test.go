@@ -0,0 +1,128 @@
+package user
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+func TestParseMetadataFilter(t *testing.T) {
+	tests := []struct {
+		s    string
+		want MetadataFilter
+	}{
+		{"os:eq:android", MetadataFilter{Path: []string{"os"}, Op: MetadataEq, Value: "android"}},
+		{"device.os:ne:ios", MetadataFilter{Path: []string{"device", "os"}, Op: MetadataNe, Value: "ios"}},
+		{"ver:gte:25", MetadataFilter{Path: []string{"ver"}, Op: MetadataGte, Value: "25"}},
+		{"referrer:exists", MetadataFilter{Path: []string{"referrer"}, Op: MetadataExists}},
+		// the value is everything after the operator
+		{"url:eq:https://example.com", MetadataFilter{Path: []string{"url"}, Op: MetadataEq, Value: "https://example.com"}},
+		{`tags:contains:["beta"]`, MetadataFilter{Path: []string{"tags"}, Op: MetadataContains, Value: `["beta"]`}},
+		{"app-id:eq:", MetadataFilter{Path: []string{"app-id"}, Op: MetadataEq, Value: ""}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetadataFilter(tt.s)
+		if err != nil {
+			t.Errorf("ParseMetadataFilter(%q) failed: %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMetadataFilter(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseMetadataFilterInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"os",
+		"os:like:android",
+		"os:eq",
+		"referrer:exists:yes",
+		"device..os:eq:android",
+		"os name:eq:android",
+		"os':eq:android",
+	} {
+		if _, err := ParseMetadataFilter(s); !er.IsCodeEq(err, er.InvalidMetadataFilter) {
+			t.Errorf("ParseMetadataFilter(%q) error = %v, want InvalidMetadataFilter", s, err)
+		}
+	}
+}
+
+func TestMetadataFilterApply(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"os:eq:android", `((metadata #>> '{"os"}'::text[]) = 'android')`},
+		{"device.os:ne:ios", `((metadata #>> '{"device","os"}'::text[]) IS DISTINCT FROM 'ios')`},
+		{"referrer:exists", `(metadata #> '{"referrer"}'::text[] IS NOT NULL)`},
+		{`tags:contains:["beta"]`, `(metadata @> '{"tags":["beta"]}'::jsonb)`},
+		{"device.model:contains:pixel", `(metadata @> '{"device":{"model":"pixel"}}'::jsonb)`},
+		{"ver:gte:25", `((CASE WHEN metadata #>> '{"ver"}'::text[] ~ '^-{0,1}[0-9]+(\.[0-9]+){0,1}$' ` +
+			`THEN (metadata #>> '{"ver"}'::text[])::numeric END) >= '25'::numeric)`},
+		// values which are not numbers are compared as text
+		{"ver:lt:beta", `((metadata #>> '{"ver"}'::text[]) < 'beta')`},
+		// values are passed as query params, never as SQL
+		{"os:eq:x' OR '1'='1", `((metadata #>> '{"os"}'::text[]) = 'x'' OR ''1''=''1')`},
+	}
+	db := pg.Connect(&pg.Options{})
+	defer db.Close()
+	for _, tt := range tests {
+		f, err := ParseMetadataFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseMetadataFilter(%q) failed: %v", tt.filter, err)
+		}
+		q := db.Model((*User)(nil)).AllWithDeleted()
+		if err = f.apply(q); err != nil {
+			t.Fatalf("apply(%q) failed: %v", tt.filter, err)
+		}
+		b, err := orm.NewSelectQuery(q).AppendQuery(orm.NewFormatter().WithModel(q), nil)
+		if err != nil {
+			t.Fatalf("apply(%q) query failed: %v", tt.filter, err)
+		}
+		if _, where, _ := strings.Cut(string(b), " WHERE "); where != tt.want {
+			t.Errorf("apply(%q) WHERE %s, want %s", tt.filter, where, tt.want)
+		}
+	}
+}
+
+func TestParseMetadataIndexes(t *testing.T) {
+	indexes, err := ParseMetadataIndexes(" os, ver:numeric,,device.model:text ")
+	if err != nil {
+		t.Fatalf("ParseMetadataIndexes failed: %v", err)
+	}
+	want := []string{"user_metadata_os_idx", "user_metadata_ver_num_idx", "user_metadata_device_model_idx"}
+	if len(indexes) != len(want) {
+		t.Fatalf("ParseMetadataIndexes = %+v, want %d indexes", indexes, len(want))
+	}
+	for i, idx := range indexes {
+		if idx.Name() != want[i] {
+			t.Errorf("index %d is named %s, want %s", i, idx.Name(), want[i])
+		}
+	}
+
+	for _, s := range []string{
+		"os:fulltext",
+		"device..os",
+		// names which would collide
+		"app-id,app_id",
+		"device.os,device_os",
+		"os,os",
+		// names truncated by Postgres
+		strings.Repeat("k", 60),
+	} {
+		if _, err := ParseMetadataIndexes(s); err == nil {
+			t.Errorf("ParseMetadataIndexes(%q) succeeded, want an error", s)
+		}
+	}
+	// the same path indexed as text and number are different indexes
+	if _, err := ParseMetadataIndexes("ver,ver:numeric"); err != nil {
+		t.Errorf("ParseMetadataIndexes of text and numeric indexes of a path failed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// SaveMetadataSchema inserts the schema, or replaces the schema of the same namespace and version of the tenant
+func (r *PGRepo) SaveMetadataSchema(ctx context.Context, m *MetadataSchema) (err error) {
+	_, err = tenant.Query(ctx, r.db, m).
+		OnConflict("(tenant_id, namespace, version) DO UPDATE").
+		Set("schema = EXCLUDED.schema").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Insert()
+	return
+}
+
+// FetchMetadataSchema fetches a version of the schema of the namespace, the latest one if version is 0
+func (r *PGRepo) FetchMetadataSchema(ctx context.Context, namespace string, version int) (schema *MetadataSchema, err error) {
+	schema = &MetadataSchema{}
+	query := tenant.Query(ctx, r.db, schema).
+		Where("namespace = ?", namespace)
+	if version > 0 {
+		query.Where("version = ?", version)
+	}
+	err = query.Order("version DESC").Limit(1).Select()
+	return
+}
+
+// FetchMetadataSchemas fetches all versions of the schemas, of the namespace only if not empty
+func (r *PGRepo) FetchMetadataSchemas(ctx context.Context, namespace string) (schemas []MetadataSchema, err error) {
+	schemas = []MetadataSchema{}
+	query := tenant.Query(ctx, r.db, &schemas)
+	if namespace != "" {
+		query.Where("namespace = ?", namespace)
+	}
+	err = query.Order("namespace ASC", "version ASC").Select()
+	return
+}
+
+// CreateMetadataIndex creates the expression index unless a valid index of the same name exists.
+// The index is built concurrently so that writes to users are not blocked meanwhile.
+func (r *PGRepo) CreateMetadataIndex(ctx context.Context, idx MetadataIndex) (err error) {
+	return r.createIndexConcurrently(ctx, idx.Name(), `CREATE INDEX CONCURRENTLY IF NOT EXISTS ? ON "user" (?)`,
+		pg.Ident(idx.Name()), idx.expr())
+}
+
+// CreateMetadataGINIndex creates the GIN index serving `contains` filters on any path
+func (r *PGRepo) CreateMetadataGINIndex(ctx context.Context) (err error) {
+	return r.createIndexConcurrently(ctx, "user_metadata_gin_idx",
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "user_metadata_gin_idx" ON "user" USING GIN ("metadata" jsonb_path_ops)`)
+}
+
+// createIndexConcurrently runs the create statement of the index. A failed concurrent build leaves an invalid
+// index which `IF NOT EXISTS` would skip, such an index is dropped first so that it is built again.
+func (r *PGRepo) createIndexConcurrently(ctx context.Context, name, create string, params ...interface{}) (err error) {
+	var valid []bool
+	_, err = r.db.QueryContext(ctx, &valid,
+		`SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass(quote_ident(?))`, name)
+	if err != nil {
+		return
+	}
+	if len(valid) > 0 && !valid[0] {
+		r.log.WithField("index", name).Warn("rebuilding invalid index")
+		if _, err = r.db.ExecContext(ctx, `DROP INDEX CONCURRENTLY IF EXISTS ?`, pg.Ident(name)); err != nil {
+			return
+		}
+	}
+	_, err = r.db.ExecContext(ctx, create, params...)
+	return
+}
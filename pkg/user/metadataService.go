@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// SaveMetadataSchema registers a version of the metadata schema of a namespace, replacing the existing one if any.
+// Users already validated against a replaced schema are not validated again.
+func (s *Service) SaveMetadataSchema(ctx context.Context, schema *MetadataSchema) (err error) {
+	if _, err = schema.compile(); err != nil {
+		return er.New(err, er.InvalidMetadataSchema).SetStatus(http.StatusUnprocessableEntity)
+	}
+	now := time.Now()
+	schema.CreatedAt = &now
+	schema.UpdatedAt = &now
+	return s.Repo.SaveMetadataSchema(ctx, schema)
+}
+
+// FetchMetadataSchema fetches a version of the metadata schema of a namespace
+func (s *Service) FetchMetadataSchema(ctx context.Context, namespace string, version int) (schema *MetadataSchema, err error) {
+	schema, err = s.Repo.FetchMetadataSchema(ctx, namespace, version)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.MetadataSchemaNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// FetchMetadataSchemas lists the registered metadata schemas, of a namespace only if not empty
+func (s *Service) FetchMetadataSchemas(ctx context.Context, namespace string) (schemas []MetadataSchema, err error) {
+	return s.Repo.FetchMetadataSchemas(ctx, namespace)
+}
+
+// validateMetadata validates `User.Metadata` against the schema of its namespace,
+// the latest version unless `User.MetadataSchemaVersion` is set.
+// Metadata of a namespace without any registered schema is not validated.
+func (s *Service) validateMetadata(ctx context.Context, user *User) error {
+	if user.Metadata == nil {
+		return nil
+	}
+	if user.MetadataNamespace == "" {
+		user.MetadataNamespace = DefaultMetadataNamespace
+	}
+	schema, err := s.Repo.FetchMetadataSchema(ctx, user.MetadataNamespace, user.MetadataSchemaVersion)
+	switch {
+	case err == pg.ErrNoRows && user.MetadataSchemaVersion == 0:
+		return nil
+	case err == pg.ErrNoRows:
+		return er.New(err, er.MetadataSchemaNotFound).SetStatus(http.StatusUnprocessableEntity)
+	case err != nil:
+		return err
+	}
+	user.MetadataSchemaVersion = schema.Version
+	return schema.validate(user.Metadata)
+}
+
+// EnsureMetadataIndexes creates the metadata indexes declared in `metadata_indexes` config,
+// and the GIN index of the metadata if `metadata_gin_index` is set. It is run by `migrate up`.
+func EnsureMetadataIndexes(ctx context.Context, conf *viper.Viper, log *logrus.Logger, repo Repository) (err error) {
+	indexes, err := ParseMetadataIndexes(conf.GetString("metadata_indexes"))
+	if err != nil {
+		return
+	}
+	for _, idx := range indexes {
+		if err = repo.CreateMetadataIndex(ctx, idx); err != nil {
+			return err
+		}
+		log.WithField("index", idx.Name()).Info("metadata index ensured")
+	}
+	if conf.GetBool("metadata_gin_index") {
+		err = repo.CreateMetadataGINIndex(ctx)
+	}
+	return
+}
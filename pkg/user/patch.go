@@ -0,0 +1,97 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// content types of user patches
+const (
+	// MergePatchType is a JSON Merge Patch, RFC 7386
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is a JSON Patch, RFC 6902
+	JSONPatchType = "application/json-patch+json"
+)
+
+// UserDocument is the editable part of a user, the document patches are applied to
+type UserDocument struct {
+	FirstName      string      `json:"first_name"`
+	LastName       string      `json:"last_name"`
+	Mobile         string      `json:"mobile"`
+	ProfilePicture string      `json:"profile_picture"`
+	DOB            *time.Time  `json:"dob"`
+	Metadata       interface{} `json:"metadata"`
+
+	MetadataNamespace     string `json:"metadata_namespace"`
+	MetadataSchemaVersion int    `json:"metadata_schema_version,omitempty"`
+}
+
+// document returns the editable part of the user. The metadata schema version is left out
+// so that patched metadata is validated against the latest schema, unless the patch sets it.
+func (u *User) document() UserDocument {
+	return UserDocument{
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		Mobile:            u.Mobile,
+		ProfilePicture:    u.ProfilePicture,
+		DOB:               u.DOB,
+		Metadata:          u.Metadata,
+		MetadataNamespace: u.MetadataNamespace,
+	}
+}
+
+// PatchUser applies a JSON Merge Patch or a JSON Patch, selected by content type, to the saved user
+// and replaces the user with the result. Explicit nulls clear fields, and metadata is patched in place.
+func (s *Service) PatchUser(ctx context.Context, saved *User, contentType string, patch []byte) (user *User, err error) {
+	doc, err := json.Marshal(saved.document())
+	if err != nil {
+		return
+	}
+	switch contentType {
+	case MergePatchType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	case JSONPatchType:
+		var p jsonpatch.Patch
+		if p, err = jsonpatch.DecodePatch(patch); err == nil {
+			doc, err = p.Apply(doc)
+		}
+	default:
+		err = errors.New("unsupported patch content type " + contentType)
+		return nil, er.New(err, er.UnsupportedPatchType).SetStatus(http.StatusUnsupportedMediaType)
+	}
+	if err != nil {
+		return nil, er.New(err, er.InvalidPatch).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	patched := UserDocument{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&patched); err != nil {
+		return nil, er.New(err, er.InvalidPatch).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	user = &User{
+		ID:             saved.ID,
+		FirstName:      patched.FirstName,
+		LastName:       patched.LastName,
+		Mobile:         patched.Mobile,
+		ProfilePicture: patched.ProfilePicture,
+		DOB:            patched.DOB,
+		Metadata:       patched.Metadata,
+		CreatedAt:      saved.CreatedAt,
+		Version:        saved.Version,
+
+		MetadataNamespace:     patched.MetadataNamespace,
+		MetadataSchemaVersion: patched.MetadataSchemaVersion,
+	}
+	err = s.UpdateUser(ctx, user)
+	return
+}
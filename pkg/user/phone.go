@@ -0,0 +1,59 @@
+package user
+
+import (
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// pgUniqueViolation is the postgres error code of `unique_violation`
+const pgUniqueViolation = "23505"
+
+type (
+	// Phone is a phone number owned by a user. A user can own many numbers
+	// but only one of them is primary; the primary number is mirrored in `User.Mobile`.
+	// Phones are soft deleted along with their user so that the number can be registered again.
+	Phone struct {
+		tableName      struct{}   `pg:"user_phones,discard_unknown_columns"`
+		ID             int        `json:"id" pg:"id"`
+		UserID         int        `json:"user_id" pg:"user_id,notnull,on_delete:CASCADE"`
+		User           *User      `json:"-" pg:"rel:has-one"`
+		Number         string     `json:"number" pg:"number,notnull"`
+		CountryCode    int        `json:"country_code" pg:"country_code"`
+		NationalNumber string     `json:"national_number" pg:"national_number"`
+		Region         string     `json:"region" pg:"region"`
+		LineType       string     `json:"line_type" pg:"line_type"`
+		Label          string     `json:"label" pg:"label"`
+		IsPrimary      bool       `json:"is_primary" pg:"is_primary,notnull,use_zero"`
+		VerifiedAt     *time.Time `json:"verified_at" pg:"verified_at"`
+		CreatedAt      *time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt      *time.Time `json:"updated_at" pg:"updated_at"`
+		DeletedAt      *time.Time `json:"-" pg:"deleted_at,soft_delete"`
+
+		tenant.Owned
+	}
+
+	// PhoneRequest is the request body of create/update phone APIs
+	PhoneRequest struct {
+		Number    string `json:"number" binding:"required"`
+		Label     string `json:"label,omitempty"`
+		IsPrimary bool   `json:"is_primary,omitempty"`
+	}
+)
+
+// setNumber sets the normalized number and its metadata on the phone
+func (p *Phone) setNumber(n *PhoneNumber) {
+	p.Number = n.E164
+	p.CountryCode = n.CountryCode
+	p.NationalNumber = n.NationalNumber
+	p.Region = n.Region
+	p.LineType = n.LineType
+}
+
+// isUniqueViolation checks if err is a postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	pgErr, ok := err.(pg.Error)
+	return ok && pgErr.Field('C') == pgUniqueViolation
+}
@@ -0,0 +1,237 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// phoneBackfillBatch is the number of rows read at once by `BackfillPhoneNumbers`
+const phoneBackfillBatch = 500
+
+// backfill failure reasons
+const (
+	BackfillInvalidNumber = "invalid_number"
+	BackfillCollision     = "collision"
+)
+
+type (
+	// PhoneBackfillFailure is a legacy number `BackfillPhoneNumbers` left untouched
+	PhoneBackfillFailure struct {
+		// Table is either "user" or "user_phones"
+		Table    string
+		ID       int
+		UserID   int
+		TenantID int
+		Number   string
+		// Reason is one of the `Backfill*` constants, Detail explains it,
+		// eg. the user or phone already having the normalized number
+		Reason string
+		Detail string
+	}
+
+	// PhoneBackfillReport is the outcome of `BackfillPhoneNumbers`
+	PhoneBackfillReport struct {
+		Users    int
+		Phones   int
+		Failures []PhoneBackfillFailure
+	}
+)
+
+// BackfillPhoneNumbers normalizes the mobiles of users and the numbers of phones saved before numbers were
+// parsed, ie. the rows without a country code, to E.164 and sets their country code and national number.
+// Numbers which fail to parse, or whose E.164 form is already used by another user or phone of the tenant,
+// are left as they are and reported as failures. Deleted users and phones are normalized as well.
+// Mobile changes are recorded in the audit trail of the user with `AuditInfo.Actor` "migrate".
+func BackfillPhoneNumbers(ctx context.Context, db *pg.DB, log *logrus.Logger, defaultRegion string) (report *PhoneBackfillReport, err error) {
+	r := &PGRepo{log: log, db: db}
+	ctx = WithAuditInfo(ctx, AuditInfo{Actor: "migrate"})
+	report = &PhoneBackfillReport{Failures: []PhoneBackfillFailure{}}
+
+	// the failed rows keep a NULL country code, the rows are paged by id so that they are not read again
+	for lastID := 0; ; {
+		users := []User{}
+		err = db.ModelContext(ctx, &users).
+			Column("id", "tenant_id").
+			Where("?TableAlias.country_code IS NULL").
+			Where("?TableAlias.mobile <> ''").
+			Where("?TableAlias.id > ?", lastID).
+			OrderExpr("?TableAlias.id ASC").
+			Limit(phoneBackfillBatch).
+			AllWithDeleted().
+			Select()
+		if err != nil || len(users) == 0 {
+			break
+		}
+		for _, u := range users {
+			if err = r.backfillMobile(tenant.WithID(ctx, u.TenantID), u.ID, defaultRegion, report); err != nil {
+				return
+			}
+		}
+		lastID = users[len(users)-1].ID
+	}
+	if err != nil {
+		return
+	}
+
+	for lastID := 0; ; {
+		phones := []Phone{}
+		err = db.ModelContext(ctx, &phones).
+			Where("country_code IS NULL").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(phoneBackfillBatch).
+			AllWithDeleted().
+			Select()
+		if err != nil || len(phones) == 0 {
+			break
+		}
+		for i := range phones {
+			if err = r.backfillPhone(tenant.WithID(ctx, phones[i].TenantID), &phones[i], defaultRegion, report); err != nil {
+				return
+			}
+		}
+		lastID = phones[len(phones)-1].ID
+	}
+	return
+}
+
+// backfillMobile normalizes the mobile of the user, the user is locked so that it is not changed meanwhile
+func (r *PGRepo) backfillMobile(ctx context.Context, userID int, defaultRegion string, report *PhoneBackfillReport) error {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		before := &User{ID: userID}
+		err = tenant.Query(ctx, tx, before).
+			WherePK().
+			Where("?TableAlias.country_code IS NULL").
+			AllWithDeleted().
+			For("UPDATE").
+			Select()
+		if err == pg.ErrNoRows {
+			// normalized since it was listed
+			return nil
+		}
+		if err != nil {
+			return
+		}
+
+		failure := PhoneBackfillFailure{
+			Table:    "user",
+			ID:       before.ID,
+			UserID:   before.ID,
+			TenantID: before.TenantID,
+			Number:   before.Mobile,
+		}
+		n, err := ParsePhoneNumber(before.Mobile, defaultRegion)
+		if err != nil {
+			failure.Reason, failure.Detail = BackfillInvalidNumber, err.Error()
+			report.Failures = append(report.Failures, failure)
+			return nil
+		}
+
+		// mobiles are unique among the users which are not deleted only
+		if before.DeletedAt == nil && n.E164 != before.Mobile {
+			other := &User{}
+			err = tenant.Query(ctx, tx, other).
+				Column("id").
+				Where("?TableAlias.mobile = ?", n.E164).
+				Where("?TableAlias.id <> ?", before.ID).
+				Limit(1).
+				Select()
+			if err == nil {
+				failure.Reason, failure.Detail = BackfillCollision, fmt.Sprintf("%s is the mobile of user %d", n.E164, other.ID)
+				report.Failures = append(report.Failures, failure)
+				return nil
+			}
+			if err != pg.ErrNoRows {
+				return
+			}
+		}
+
+		after := *before
+		after.setMobile(n)
+		now := time.Now()
+		after.UpdatedAt = &now
+		_, err = tenant.Query(ctx, tx, &after).
+			Set("mobile=?mobile").
+			Set("country_code=?country_code").
+			Set("national_number=?national_number").
+			Set("updated_at=?updated_at").
+			Set("version=version+1").
+			WherePK().
+			AllWithDeleted().
+			Returning("version").
+			Update()
+		if err != nil {
+			return
+		}
+		if after.Mobile != before.Mobile {
+			if err = r.insertAudit(ctx, tx, AuditUpdate, before, &after); err != nil {
+				return
+			}
+		}
+		report.Users++
+		return nil
+	})
+}
+
+// backfillPhone normalizes the number of the phone
+func (r *PGRepo) backfillPhone(ctx context.Context, p *Phone, defaultRegion string, report *PhoneBackfillReport) (err error) {
+	failure := PhoneBackfillFailure{
+		Table:    "user_phones",
+		ID:       p.ID,
+		UserID:   p.UserID,
+		TenantID: p.TenantID,
+		Number:   p.Number,
+	}
+	n, err := ParsePhoneNumber(p.Number, defaultRegion)
+	if err != nil {
+		failure.Reason, failure.Detail = BackfillInvalidNumber, err.Error()
+		report.Failures = append(report.Failures, failure)
+		return nil
+	}
+
+	// numbers are unique among the phones which are not deleted only
+	if p.DeletedAt == nil && n.E164 != p.Number {
+		other := &Phone{}
+		err = tenant.Query(ctx, r.db, other).
+			Column("id", "user_id").
+			Where("number = ?", n.E164).
+			Where("id <> ?", p.ID).
+			Limit(1).
+			Select()
+		if err == nil {
+			failure.Reason, failure.Detail = BackfillCollision, fmt.Sprintf("%s is phone %d of user %d", n.E164, other.ID, other.UserID)
+			report.Failures = append(report.Failures, failure)
+			return nil
+		}
+		if err != pg.ErrNoRows {
+			return
+		}
+	}
+
+	p.setNumber(n)
+	now := time.Now()
+	p.UpdatedAt = &now
+	_, err = tenant.Query(ctx, r.db, p).
+		Column("number", "country_code", "national_number", "region", "line_type", "updated_at").
+		Where("country_code IS NULL").
+		WherePK().
+		AllWithDeleted().
+		Update()
+	if isUniqueViolation(err) {
+		// the number was taken since it was checked
+		failure.Reason, failure.Detail = BackfillCollision, err.Error()
+		report.Failures = append(report.Failures, failure)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	report.Phones++
+	return nil
+}
@@ -0,0 +1,177 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// PrimaryPhoneLabel is the label given to the phone created from `User.Mobile`
+const PrimaryPhoneLabel = "primary"
+
+func (r *PGRepo) CreatePhone(ctx context.Context, p *Phone) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if _, err = tenant.Query(ctx, tx, p).Insert(); err != nil {
+			return
+		}
+		if p.IsPrimary {
+			if err = r.promotePhone(ctx, tx, p, *p.UpdatedAt); err != nil {
+				return
+			}
+		}
+		return r.touchUsers(ctx, tx, p.UserID)
+	})
+}
+
+func (r *PGRepo) UpdatePhone(ctx context.Context, p *Phone) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		_, err = tenant.Query(ctx, tx, p).
+			Column("number", "country_code", "national_number", "region", "line_type",
+				"label", "is_primary", "verified_at", "updated_at").
+			WherePK().
+			Where("user_id = ?user_id").
+			Update()
+		if err != nil {
+			return
+		}
+		if p.IsPrimary {
+			if err = r.promotePhone(ctx, tx, p, *p.UpdatedAt); err != nil {
+				return
+			}
+		}
+		return r.touchUsers(ctx, tx, p.UserID)
+	})
+}
+
+func (r *PGRepo) DeletePhone(ctx context.Context, userID, phoneID int) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		res, err := tenant.Query(ctx, tx, (*Phone)(nil)).
+			Where("id = ?", phoneID).
+			Where("user_id = ?", userID).
+			Delete()
+		if err != nil {
+			return
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		return r.touchUsers(ctx, tx, userID)
+	})
+}
+
+func (r *PGRepo) FetchPhone(ctx context.Context, userID, phoneID int) (phone *Phone, err error) {
+	phone = &Phone{}
+	err = tenant.Query(ctx, r.db, phone).
+		Where("id = ?", phoneID).
+		Where("user_id = ?", userID).
+		Select()
+	return
+}
+
+func (r *PGRepo) FetchPhones(ctx context.Context, userID int) (phones []Phone, err error) {
+	phones = []Phone{}
+	err = tenant.Query(ctx, r.db, &phones).
+		Where("user_id = ?", userID).
+		Order("is_primary DESC", "id ASC").
+		Select()
+	return
+}
+
+// promotePhone makes `p` the only primary phone of its user and mirrors its number in `User.Mobile`,
+// the change of the mobile is recorded in the audit trail of the user
+func (r *PGRepo) promotePhone(ctx context.Context, tx *pg.Tx, p *Phone, now time.Time) (err error) {
+	_, err = tenant.Query(ctx, tx, (*Phone)(nil)).
+		Set("is_primary = FALSE").
+		Set("updated_at = ?", now).
+		Where("user_id = ?", p.UserID).
+		Where("id <> ?", p.ID).
+		Where("is_primary").
+		Update()
+	if err != nil {
+		return
+	}
+
+	before := &User{ID: p.UserID}
+	if err = tenant.Query(ctx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+		return
+	}
+	// the user is already up to date when its mobile was changed by `updateUser`, which audits it
+	if before.Mobile == p.Number {
+		return
+	}
+	after := *before
+	after.Mobile = p.Number
+	after.CountryCode = p.CountryCode
+	after.NationalNumber = p.NationalNumber
+	after.UpdatedAt = &now
+	_, err = tenant.Query(ctx, tx, &after).
+		Set("mobile = ?mobile").
+		Set("country_code = ?country_code").
+		Set("national_number = ?national_number").
+		Set("updated_at = ?updated_at").
+		Set("version = version + 1").
+		WherePK().
+		Returning("version").
+		Update()
+	if err != nil {
+		return
+	}
+	return r.insertAudit(ctx, tx, AuditUpdate, before, &after)
+}
+
+// syncPrimaryPhone makes `p` the primary phone of the user after `User.Mobile` is changed.
+// If the number already belongs to the user it is promoted, otherwise the primary phone number is replaced.
+// The change of `User.Mobile` is audited by the caller, `promotePhone` leaves the user unchanged then.
+func (r *PGRepo) syncPrimaryPhone(ctx context.Context, tx *pg.Tx, userID int, p *Phone, now time.Time) (err error) {
+	phone := &Phone{}
+	err = tenant.Query(ctx, tx, phone).
+		Where("user_id = ?", userID).
+		Where("number = ?", p.Number).
+		Select()
+	switch err {
+	case nil:
+		if phone.IsPrimary {
+			return
+		}
+		phone.IsPrimary = true
+		phone.UpdatedAt = &now
+		if _, err = tenant.Query(ctx, tx, phone).Column("is_primary", "updated_at").WherePK().Update(); err != nil {
+			return
+		}
+		return r.promotePhone(ctx, tx, phone, now)
+	case pg.ErrNoRows:
+	default:
+		return
+	}
+
+	res, err := tenant.Query(ctx, tx, (*Phone)(nil)).
+		Set("number = ?", p.Number).
+		Set("country_code = ?", p.CountryCode).
+		Set("national_number = ?", p.NationalNumber).
+		Set("region = ?", p.Region).
+		Set("line_type = ?", p.LineType).
+		Set("verified_at = NULL").
+		Set("updated_at = ?", now).
+		Where("user_id = ?", userID).
+		Where("is_primary").
+		Update()
+	if err != nil || res.RowsAffected() > 0 {
+		return
+	}
+	_, err = tenant.Query(ctx, tx, &Phone{
+		UserID:         userID,
+		Number:         p.Number,
+		CountryCode:    p.CountryCode,
+		NationalNumber: p.NationalNumber,
+		Region:         p.Region,
+		LineType:       p.LineType,
+		Label:          PrimaryPhoneLabel,
+		IsPrimary:      true,
+		CreatedAt:      &now,
+		UpdatedAt:      &now,
+	}).Insert()
+	return
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// CreatePhone adds a new phone number to the user.
+// The first phone of a user is always made primary.
+func (s *Service) CreatePhone(ctx context.Context, userID int, req PhoneRequest) (phone *Phone, err error) {
+	n, err := s.NormalizePhone(req.Number)
+	if err != nil {
+		return
+	}
+	phones, err := s.phonesOf(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	phone = &Phone{
+		UserID:    userID,
+		Label:     req.Label,
+		IsPrimary: req.IsPrimary || len(phones) == 0,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	phone.setNumber(n)
+	err = s.Repo.CreatePhone(ctx, phone)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.PhoneAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+// UpdatePhone updates the number, label or primary flag of a user phone.
+// A primary phone can only stop being primary by promoting another phone.
+func (s *Service) UpdatePhone(ctx context.Context, userID, phoneID int, req PhoneRequest) (phone *Phone, err error) {
+	n, err := s.NormalizePhone(req.Number)
+	if err != nil {
+		return
+	}
+	phone, err = s.FetchPhone(ctx, userID, phoneID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if phone.Number != n.E164 {
+		phone.VerifiedAt = nil
+	}
+	phone.setNumber(n)
+	phone.Label = req.Label
+	phone.IsPrimary = phone.IsPrimary || req.IsPrimary
+	phone.UpdatedAt = &now
+
+	err = s.Repo.UpdatePhone(ctx, phone)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.PhoneAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	return
+}
+
+// DeletePhone removes a phone from the user. The primary phone can not be deleted.
+func (s *Service) DeletePhone(ctx context.Context, userID, phoneID int) (err error) {
+	phone, err := s.FetchPhone(ctx, userID, phoneID)
+	if err != nil {
+		return
+	}
+	if phone.IsPrimary {
+		return er.New(errors.New("primary phone can not be deleted"), er.PrimaryPhoneRequired).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return s.Repo.DeletePhone(ctx, userID, phoneID)
+}
+
+func (s *Service) FetchPhone(ctx context.Context, userID, phoneID int) (phone *Phone, err error) {
+	phone, err = s.Repo.FetchPhone(ctx, userID, phoneID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.PhoneNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+func (s *Service) FetchPhones(ctx context.Context, userID int) (phones []Phone, err error) {
+	return s.phonesOf(ctx, userID)
+}
+
+// phonesOf returns phones of an existing user, or `er.UserNotFound` if user does not exist
+func (s *Service) phonesOf(ctx context.Context, userID int) (phones []Phone, err error) {
+	if _, err = s.Repo.Fetch(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+	return s.Repo.FetchPhones(ctx, userID)
+}
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"gouser/er"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Line types of a phone number
+const (
+	LineTypeMobile         = "mobile"
+	LineTypeLandline       = "landline"
+	LineTypeLandlineMobile = "landline_or_mobile"
+	LineTypeVoIP           = "voip"
+	LineTypeTollFree       = "toll_free"
+	LineTypePremiumRate    = "premium_rate"
+	LineTypeSharedCost     = "shared_cost"
+	LineTypePersonalNumber = "personal_number"
+	LineTypePager          = "pager"
+	LineTypeUAN            = "uan"
+	LineTypeVoicemail      = "voicemail"
+	LineTypeUnknown        = "unknown"
+)
+
+var lineTypes = map[phonenumbers.PhoneNumberType]string{
+	phonenumbers.MOBILE:               LineTypeMobile,
+	phonenumbers.FIXED_LINE:           LineTypeLandline,
+	phonenumbers.FIXED_LINE_OR_MOBILE: LineTypeLandlineMobile,
+	phonenumbers.VOIP:                 LineTypeVoIP,
+	phonenumbers.TOLL_FREE:            LineTypeTollFree,
+	phonenumbers.PREMIUM_RATE:         LineTypePremiumRate,
+	phonenumbers.SHARED_COST:          LineTypeSharedCost,
+	phonenumbers.PERSONAL_NUMBER:      LineTypePersonalNumber,
+	phonenumbers.PAGER:                LineTypePager,
+	phonenumbers.UAN:                  LineTypeUAN,
+	phonenumbers.VOICEMAIL:            LineTypeVoicemail,
+}
+
+// PhoneNumber is a phone number parsed and normalized to E.164 format
+type PhoneNumber struct {
+	// E164 is the number in E.164 format, eg. +918977777777
+	E164 string
+	// CountryCode is the calling code of the country, eg. 91
+	CountryCode int
+	// NationalNumber is the national significant number, eg. 8977777777
+	NationalNumber string
+	// Region is ISO 3166-1 alpha-2 region of the number, eg. IN
+	Region string
+	// LineType is one of the `LineType*` constants
+	LineType string
+}
+
+// ParsePhoneNumber parses a phone number written in any common format.
+// Numbers without a country code are parsed as numbers of `defaultRegion`.
+// It returns `er.InvalidPhoneNumber` if the number is not a valid number.
+func ParsePhoneNumber(raw, defaultRegion string) (*PhoneNumber, error) {
+	num, err := phonenumbers.Parse(raw, defaultRegion)
+	if err != nil {
+		return nil, er.New(err, er.InvalidPhoneNumber).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	if !phonenumbers.IsValidNumber(num) {
+		prefixed, ok := parsePrefixedNumber(raw, defaultRegion)
+		if !ok {
+			return nil, er.New(errors.New("invalid phone number: "+raw), er.InvalidPhoneNumber).SetStatus(http.StatusUnprocessableEntity)
+		}
+		num = prefixed
+	}
+
+	lineType, ok := lineTypes[phonenumbers.GetNumberType(num)]
+	if !ok {
+		lineType = LineTypeUnknown
+	}
+
+	return &PhoneNumber{
+		E164:           phonenumbers.Format(num, phonenumbers.E164),
+		CountryCode:    int(num.GetCountryCode()),
+		NationalNumber: phonenumbers.GetNationalSignificantNumber(num),
+		Region:         phonenumbers.GetRegionCodeForNumber(num),
+		LineType:       lineType,
+	}, nil
+}
+
+// parsePrefixedNumber parses numbers which carry the country code after a prefix instead of a `+`:
+// any country code after the international prefix 00, eg. 0044 7911123456, or the country code of
+// `defaultRegion` after the trunk prefix 0, eg. 091-8977777777 in IN. Other numbers are not guessed,
+// as a mistyped local number could be a valid number of another country.
+func parsePrefixedNumber(raw, defaultRegion string) (*phonenumbers.PhoneNumber, bool) {
+	if strings.HasPrefix(strings.TrimSpace(raw), "+") {
+		return nil, false
+	}
+	digits := digitsOf(raw)
+	trunk := false
+	switch {
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+		trunk = true
+	default:
+		return nil, false
+	}
+	num, err := phonenumbers.Parse("+"+digits, defaultRegion)
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		return nil, false
+	}
+	if trunk && int(num.GetCountryCode()) != phonenumbers.GetCountryCodeForRegion(defaultRegion) {
+		return nil, false
+	}
+	return num, true
+}
+
+// digitsOf returns only the digits of s
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"testing"
+
+	"gouser/er"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw           string
+		defaultRegion string
+		want          PhoneNumber
+	}{
+		{"+91 89777 77777", "IN", PhoneNumber{"+918977777777", 91, "8977777777", "IN", LineTypeMobile}},
+		{"8977777777", "IN", PhoneNumber{"+918977777777", 91, "8977777777", "IN", LineTypeMobile}},
+		{"(897) 777-7777", "IN", PhoneNumber{"+918977777777", 91, "8977777777", "IN", LineTypeMobile}},
+		// the country code of the default region after the trunk prefix
+		{"091-8977777777", "IN", PhoneNumber{"+918977777777", 91, "8977777777", "IN", LineTypeMobile}},
+		// any country code after the international prefix
+		{"0091 8977777777", "IN", PhoneNumber{"+918977777777", 91, "8977777777", "IN", LineTypeMobile}},
+		{"0044 20 7946 0958", "IN", PhoneNumber{"+442079460958", 44, "2079460958", "GB", LineTypeLandline}},
+		{"+1 650-253-0000", "IN", PhoneNumber{"+16502530000", 1, "6502530000", "US", LineTypeLandlineMobile}},
+		{"020 7946 0958", "GB", PhoneNumber{"+442079460958", 44, "2079460958", "GB", LineTypeLandline}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePhoneNumber(tt.raw, tt.defaultRegion)
+		if err != nil {
+			t.Errorf("ParsePhoneNumber(%q, %s) failed: %v", tt.raw, tt.defaultRegion, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParsePhoneNumber(%q, %s) = %+v, want %+v", tt.raw, tt.defaultRegion, *got, tt.want)
+		}
+	}
+}
+
+func TestParsePhoneNumberInvalid(t *testing.T) {
+	tests := []struct {
+		raw           string
+		defaultRegion string
+	}{
+		{"", "IN"},
+		{"not a number", "IN"},
+		{"12345", "IN"},
+		{"+91 12345", "IN"},
+		// a mistyped local number is not read as a number of another country
+		{"442079460958", "IN"},
+		// a country code after the trunk prefix must be the code of the default region
+		{"044 20 7946 0958", "IN"},
+	}
+	for _, tt := range tests {
+		got, err := ParsePhoneNumber(tt.raw, tt.defaultRegion)
+		if err == nil {
+			t.Errorf("ParsePhoneNumber(%q, %s) = %+v, want an error", tt.raw, tt.defaultRegion, *got)
+			continue
+		}
+		if !er.IsCodeEq(err, er.InvalidPhoneNumber) {
+			t.Errorf("ParsePhoneNumber(%q, %s) error = %v, want InvalidPhoneNumber", tt.raw, tt.defaultRegion, err)
+		}
+	}
+}
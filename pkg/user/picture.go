@@ -0,0 +1,174 @@
+package user
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	// decoders of accepted picture types
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+// maxPicturePixels caps the decoded size of uploaded pictures
+const maxPicturePixels = 40_000_000
+
+// pictureTypes are the accepted content types of uploaded pictures, sniffed from their content
+var pictureTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// pictureVariant is a resized copy of an uploaded picture
+type pictureVariant struct {
+	Size        int
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// resizePicture returns a variant of the picture per size, fitting in a square of that size.
+// Pictures are never upscaled. Variants are re-encoded, which drops EXIF and any other metadata,
+// so the EXIF orientation of JPEGs is applied to the pixels first.
+func resizePicture(img image.Image, orientation int, sizes []int) (variants []pictureVariant, err error) {
+	for _, size := range sizes {
+		b := img.Bounds()
+		w, h := b.Dx(), b.Dy()
+		if w > size || h > size {
+			if w >= h {
+				w, h = size, max1(h*size/w)
+			} else {
+				w, h = max1(w*size/h), size
+			}
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		oriented := orient(dst, orientation)
+
+		v := pictureVariant{Size: size}
+		buf := &bytes.Buffer{}
+		if oriented.Opaque() {
+			v.ContentType, v.Ext = "image/jpeg", "jpg"
+			err = jpeg.Encode(buf, oriented, &jpeg.Options{Quality: 85})
+		} else {
+			v.ContentType, v.Ext = "image/png", "png"
+			err = png.Encode(buf, oriented)
+		}
+		if err != nil {
+			return
+		}
+		v.Data = buf.Bytes()
+		variants = append(variants, v)
+	}
+	return
+}
+
+// orient transforms the image as per its EXIF orientation, 1 to 8
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counterclockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 1 (as is) if it has none
+func jpegOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return 1
+		}
+		marker := b[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// image data starts, EXIF comes before it
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			return 1
+		}
+		if seg := b[i+4 : i+2+n]; marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of the TIFF structure of EXIF
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+	var bo binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	off := int(bo.Uint32(t[4:]))
+	if off < 8 || off+2 > len(t) {
+		return 1
+	}
+	for k, n := 0, int(bo.Uint16(t[off:])); k < n; k++ {
+		e := off + 2 + k*12
+		if e+12 > len(t) {
+			return 1
+		}
+		if bo.Uint16(t[e:]) == 0x0112 {
+			if o := int(bo.Uint16(t[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+func max1(v int) int {
+	if v < 1 {
+		return 1
+	}
+	return v
+}
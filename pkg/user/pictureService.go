@@ -0,0 +1,282 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/blob"
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// pictureUploadBatchSize is the number of expired picture uploads deleted per query
+const pictureUploadBatchSize = 100
+
+// UploadProfilePicture sets the picture uploaded through the API as the profile picture of the user
+func (s *Service) UploadProfilePicture(ctx context.Context, userID int, r io.Reader) (user *User, err error) {
+	user, err = s.Repo.Fetch(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if err = s.setProfilePicture(ctx, user, r); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// setProfilePicture validates the picture, stores its resized variants and sets the largest
+// variant as the profile picture of the user. The previously uploaded picture is deleted.
+func (s *Service) setProfilePicture(ctx context.Context, user *User, r io.Reader) (err error) {
+	maxSize := s.PictureMaxSize()
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return
+	}
+	if int64(len(data)) > maxSize {
+		return pictureTooLarge(maxSize)
+	}
+	if contentType := http.DetectContentType(data); !pictureTypes[contentType] {
+		err = errors.New("unsupported picture type " + contentType)
+		return er.New(err, er.UnsupportedPictureType).SetStatus(http.StatusUnsupportedMediaType)
+	}
+	img, err := decodePicture(data)
+	if err != nil {
+		return er.New(err, er.InvalidPicture).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	sizes, err := s.pictureSizes()
+	if err != nil {
+		return
+	}
+	variants, err := resizePicture(img, jpegOrientation(data), sizes)
+	if err != nil {
+		return
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return
+	}
+	key := fmt.Sprintf("users/%d/picture/%s", user.ID, suffix)
+	urls := make(map[string]string, len(variants))
+	for _, v := range variants {
+		vKey := fmt.Sprintf("%s/%d.%s", key, v.Size, v.Ext)
+		if err = s.blobs.Put(ctx, vKey, bytes.NewReader(v.Data), int64(len(v.Data)), v.ContentType); err != nil {
+			s.deleteBlobs(ctx, key)
+			return
+		}
+		urls[strconv.Itoa(v.Size)] = s.blobs.URL(vKey)
+	}
+
+	oldKey := user.ProfilePictureKey
+	now := time.Now()
+	user.ProfilePicture = urls[strconv.Itoa(sizes[len(sizes)-1])]
+	user.ProfilePictureVariants = urls
+	user.ProfilePictureKey = key
+	user.UpdatedAt = &now
+	if err = s.Repo.UpdateProfilePicture(ctx, user); err != nil {
+		s.deleteBlobs(ctx, key)
+		return
+	}
+	if oldKey != "" {
+		s.deleteBlobs(ctx, oldKey)
+	}
+	return
+}
+
+// CreatePictureUpload returns a pre-signed URL the client uploads the picture of the user to,
+// the picture is set once the upload is finalized with `FinalizePictureUpload`.
+// It fails with `er.DirectUploadUnsupported` if the blob store cannot pre-sign URLs.
+func (s *Service) CreatePictureUpload(ctx context.Context, userID int) (upload *PictureUpload, err error) {
+	presigner, ok := s.blobs.(blob.Presigner)
+	if !ok {
+		err = errors.New("blob store does not pre-sign upload URLs")
+		return nil, er.New(err, er.DirectUploadUnsupported).SetStatus(http.StatusNotImplemented)
+	}
+	if _, err = s.Repo.Fetch(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return
+	}
+	ttl := s.conf.GetDuration("picture_upload_ttl")
+	now := time.Now()
+	urlExpiresAt, expiresAt := now.Add(ttl), now.Add(2*ttl)
+	upload = &PictureUpload{
+		UserID:       userID,
+		Key:          fmt.Sprintf("users/%d/uploads/%s", userID, suffix),
+		Method:       http.MethodPut,
+		CreatedAt:    &now,
+		URLExpiresAt: &urlExpiresAt,
+		ExpiresAt:    &expiresAt,
+	}
+	if upload.URL, err = presigner.PresignPut(ctx, upload.Key, ttl); err != nil {
+		return nil, err
+	}
+	err = s.Repo.CreatePictureUpload(ctx, upload)
+	return
+}
+
+// FinalizePictureUpload checks that the picture of the upload has been uploaded and sets it
+// as the profile picture of the user, like `UploadProfilePicture`. The uploaded file is deleted
+// once its resized variants are stored.
+func (s *Service) FinalizePictureUpload(ctx context.Context, userID, uploadID int) (user *User, err error) {
+	upload, err := s.Repo.FetchPictureUpload(ctx, userID, uploadID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.PictureUploadNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if upload.FinalizedAt != nil {
+		err = er.New(errors.New("picture upload is finalized"), er.PictureUploadFinalized).SetStatus(http.StatusConflict)
+		return
+	}
+	if time.Now().After(*upload.ExpiresAt) {
+		err = er.New(errors.New("picture upload has expired"), er.PictureUploadExpired).SetStatus(http.StatusGone)
+		return
+	}
+
+	info, err := s.blobs.Stat(ctx, upload.Key)
+	if err == blob.ErrNotFound {
+		err = er.New(err, er.PictureNotUploaded).SetStatus(http.StatusConflict)
+	}
+	if err != nil {
+		return
+	}
+	if info.Size > s.PictureMaxSize() {
+		return nil, pictureTooLarge(s.PictureMaxSize())
+	}
+
+	user, err = s.Repo.Fetch(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	file, err := s.blobs.Get(ctx, upload.Key)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	if err = s.setProfilePicture(ctx, user, file); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload.FinalizedAt = &now
+	// an upload finalized concurrently is left as is, the picture set last wins
+	if err = s.Repo.FinalizePictureUpload(ctx, upload); err == pg.ErrNoRows {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dErr := s.blobs.Delete(ctx, upload.Key); dErr != nil {
+		s.log.WithContext(ctx).WithField("key", upload.Key).Error("unable to delete picture upload: ", dErr.Error())
+	}
+	return
+}
+
+// CollectPictureUploads deletes the uploads which can no longer be finalized along with their files,
+// so that pictures uploaded but never finalized do not pile up in the blob store.
+// Uploads of all tenants are collected if the context is `tenant.Unscoped`. It returns the number of uploads deleted.
+func (s *Service) CollectPictureUploads(ctx context.Context) (collected int, err error) {
+	for {
+		uploads, err := s.Repo.FetchExpiredPictureUploads(ctx, time.Now(), pictureUploadBatchSize)
+		if err != nil {
+			return collected, err
+		}
+		for i := range uploads {
+			// finalized uploads are deleted as well, their file is normally deleted already
+			if err = s.blobs.Delete(ctx, uploads[i].Key); err != nil {
+				return collected, err
+			}
+			if err = s.Repo.DeletePictureUpload(tenant.WithID(ctx, uploads[i].TenantID), uploads[i].ID); err != nil {
+				return collected, err
+			}
+			if uploads[i].FinalizedAt == nil {
+				collected++
+			}
+		}
+		if len(uploads) < pictureUploadBatchSize {
+			return collected, nil
+		}
+	}
+}
+
+// pictureSizes returns the configured sizes of picture variants in ascending order
+func (s *Service) pictureSizes() (sizes []int, err error) {
+	for _, f := range strings.Split(s.conf.GetString("picture_sizes"), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid picture size %q", f)
+		}
+		if len(sizes) > 0 && size <= sizes[len(sizes)-1] {
+			return nil, fmt.Errorf("picture sizes must be ascending, got %d after %d", size, sizes[len(sizes)-1])
+		}
+		sizes = append(sizes, size)
+	}
+	return
+}
+
+// PictureMaxSize returns the maximum size of uploaded pictures in bytes
+func (s *Service) PictureMaxSize() int64 {
+	return s.conf.GetInt64("picture_max_size")
+}
+
+// deleteBlobs deletes the blobs under the prefix, eg. the variants of an uploaded picture.
+// Failures are only logged, the blobs are no longer referenced anyway.
+func (s *Service) deleteBlobs(ctx context.Context, prefix string) {
+	if err := s.blobs.DeletePrefix(ctx, prefix); err != nil {
+		s.log.WithContext(ctx).WithField("prefix", prefix).Error("unable to delete blobs: ", err.Error())
+	}
+}
+
+func pictureTooLarge(maxSize int64) error {
+	err := fmt.Errorf("picture larger than %d bytes", maxSize)
+	return er.New(err, er.PictureTooLarge).SetStatus(http.StatusRequestEntityTooLarge)
+}
+
+// randomHex returns n random bytes hex encoded, eg. to make keys of uploads unguessable
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// decodePicture decodes the picture after checking its dimensions, so that huge images are never decoded
+func decodePicture(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPicturePixels {
+		return nil, fmt.Errorf("picture of %dx%d pixels is too large", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
@@ -0,0 +1,26 @@
+package user
+
+import (
+	"time"
+
+	"gouser/pkg/tenant"
+)
+
+// PictureUpload is a profile picture uploaded by the client straight to the blob store with a pre-signed URL.
+// The picture is PUT to `URL` before `URLExpiresAt`, then the upload is finalized before `ExpiresAt`.
+// Uploads which are not finalized by then are deleted along with their file.
+type PictureUpload struct {
+	tableName struct{} `pg:"user_picture_uploads,discard_unknown_columns"`
+	ID        int      `json:"id" pg:"id"`
+	UserID    int      `json:"user_id" pg:"user_id,notnull"`
+	Key       string   `json:"-" pg:"key,notnull"`
+	// URL and Method are only returned when the upload is created
+	URL          string     `json:"url,omitempty" pg:"-"`
+	Method       string     `json:"method,omitempty" pg:"-"`
+	CreatedAt    *time.Time `json:"created_at" pg:"created_at"`
+	URLExpiresAt *time.Time `json:"url_expires_at" pg:"url_expires_at,notnull"`
+	ExpiresAt    *time.Time `json:"expires_at" pg:"expires_at,notnull"`
+	FinalizedAt  *time.Time `json:"finalized_at,omitempty" pg:"finalized_at"`
+
+	tenant.Owned
+}
@@ -0,0 +1,57 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+func (r *PGRepo) CreatePictureUpload(ctx context.Context, u *PictureUpload) (err error) {
+	_, err = tenant.Query(ctx, r.db, u).Insert()
+	return
+}
+
+func (r *PGRepo) FetchPictureUpload(ctx context.Context, userID, uploadID int) (upload *PictureUpload, err error) {
+	upload = &PictureUpload{}
+	err = tenant.Query(ctx, r.db, upload).
+		Where("id = ?", uploadID).
+		Where("user_id = ?", userID).
+		Select()
+	return
+}
+
+// FinalizePictureUpload sets `FinalizedAt` of the upload unless it is already finalized
+func (r *PGRepo) FinalizePictureUpload(ctx context.Context, u *PictureUpload) (err error) {
+	res, err := tenant.Query(ctx, r.db, u).
+		Column("finalized_at").
+		WherePK().
+		Where("finalized_at IS NULL").
+		Update()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+// FetchExpiredPictureUploads fetches uploads which can no longer be finalized by `now`
+func (r *PGRepo) FetchExpiredPictureUploads(ctx context.Context, now time.Time, limit int) (uploads []PictureUpload, err error) {
+	err = tenant.Query(ctx, r.db, &uploads).
+		Where("expires_at <= ?", now).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	return
+}
+
+func (r *PGRepo) DeletePictureUpload(ctx context.Context, uploadID int) (err error) {
+	_, err = tenant.Query(ctx, r.db, (*PictureUpload)(nil)).
+		Where("id = ?", uploadID).
+		Delete()
+	return
+}
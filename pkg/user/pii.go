@@ -0,0 +1,54 @@
+package user
+
+import "strings"
+
+// maskedDigits is the number of trailing digits left visible in masked phone numbers
+const maskedDigits = 2
+
+// MaskPII masks the contact details of the user in place, for callers not allowed to read PII.
+// Phone numbers keep their last digits, emails their first letter and domain, and addresses
+// their city and country. The date of birth is cleared.
+func (u *User) MaskPII() {
+	u.Mobile = maskNumber(u.Mobile)
+	u.NationalNumber = maskNumber(u.NationalNumber)
+	u.DOB = nil
+	for i := range u.Phones {
+		u.Phones[i].Number = maskNumber(u.Phones[i].Number)
+		u.Phones[i].NationalNumber = maskNumber(u.Phones[i].NationalNumber)
+	}
+	for i := range u.Emails {
+		u.Emails[i].Address = maskEmail(u.Emails[i].Address)
+	}
+	for i := range u.Addresses {
+		a := &u.Addresses[i]
+		a.Line1 = mask(a.Line1, 0)
+		a.Line2 = mask(a.Line2, 0)
+		a.PostalCode = mask(a.PostalCode, 0)
+	}
+}
+
+// maskNumber masks all digits of a phone number but the last ones, eg. `+91********10`
+func maskNumber(n string) string {
+	if strings.HasPrefix(n, "+") {
+		return "+" + mask(n[1:], maskedDigits)
+	}
+	return mask(n, maskedDigits)
+}
+
+// maskEmail masks the local part of the address but its first letter, eg. `j***@example.com`
+func maskEmail(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 1 {
+		return mask(address, 0)
+	}
+	return address[:1] + "***" + address[at:]
+}
+
+// mask replaces all but the last `keep` characters of s with `*`
+func mask(s string, keep int) string {
+	r := []rune(s)
+	for i := 0; i < len(r)-keep; i++ {
+		r[i] = '*'
+	}
+	return string(r)
+}
@@ -0,0 +1,314 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// time zone preferences are validated without relying on the zoneinfo of the host
+	_ "time/tzdata"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// PreferenceType is the type of the values of a preference
+type PreferenceType string
+
+const (
+	PreferenceBool   PreferenceType = "bool"
+	PreferenceInt    PreferenceType = "int"
+	PreferenceString PreferenceType = "string"
+	// PreferenceEnum is a string among the `Values` of the preference
+	PreferenceEnum PreferenceType = "enum"
+)
+
+// preferenceStringMaxLength is the maximum length of string preferences
+const preferenceStringMaxLength = 256
+
+var (
+	// preferenceDefinitionPattern is the format of a preference declared in the `preferences` config,
+	// eg. `theme:enum(light|dark|system)=system` or `digest_hour:int(0..23)=9`
+	preferenceDefinitionPattern = regexp.MustCompile(`^([a-z][a-z0-9_]*(?:\.[a-z][a-z0-9_]*)*):(bool|int|string|enum)(?:\(([^)]*)\))?=(.*)$`)
+	// languagePattern is the format of language tags, eg. `en` or `pt-BR`
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+)
+
+// builtinPreferences are the preferences every user has, the `preferences` config adds to them and can
+// replace them
+var builtinPreferences = PreferenceDefinitions{
+	{Key: "language", Type: PreferenceString, Default: "en", check: func(v string) error {
+		if !languagePattern.MatchString(v) {
+			return errors.New("must be a language tag, eg. en or pt-BR")
+		}
+		return nil
+	}},
+	{Key: "timezone", Type: PreferenceString, Default: "UTC", check: func(v string) error {
+		if _, err := time.LoadLocation(v); err != nil || v == "" || v == "Local" {
+			return errors.New("must be an IANA time zone, eg. Asia/Kolkata")
+		}
+		return nil
+	}},
+	{Key: "notifications.email", Type: PreferenceBool, Default: true},
+	{Key: "notifications.sms", Type: PreferenceBool, Default: true},
+	{Key: "notifications.push", Type: PreferenceBool, Default: true},
+}
+
+type (
+	// PreferenceDefinition declares a preference, its type, its default and how its values are validated
+	PreferenceDefinition struct {
+		Key     string         `json:"key"`
+		Type    PreferenceType `json:"type"`
+		Default interface{}    `json:"default"`
+		// Values are the allowed values of enum preferences
+		Values []string `json:"values,omitempty"`
+		// Min and Max bound int preferences
+		Min *int `json:"min,omitempty"`
+		Max *int `json:"max,omitempty"`
+
+		// check further validates string values, eg. time zones
+		check func(string) error
+	}
+
+	// PreferenceDefinitions are the preferences of users, ordered by key
+	PreferenceDefinitions []PreferenceDefinition
+
+	// Preference is the value of a preference set by a user, preferences not set have their default value
+	Preference struct {
+		tableName struct{}        `pg:"user_preferences,alias:preference,discard_unknown_columns"`
+		UserID    int             `json:"user_id" pg:"user_id,pk"`
+		Key       string          `json:"key" pg:"key,pk"`
+		Value     json.RawMessage `json:"value" pg:"value,type:jsonb"`
+		UpdatedAt time.Time       `json:"updated_at" pg:"updated_at"`
+
+		tenant.Owned
+	}
+
+	// UserPreferences are the effective preferences of a user
+	UserPreferences struct {
+		UserID int `json:"user_id"`
+		// Preferences are the values set by the user, or the defaults of the preferences it did not set
+		Preferences map[string]interface{} `json:"preferences"`
+		// Overridden lists the preferences set by the user
+		Overridden []string `json:"overridden"`
+	}
+
+	// PreferencesUpdateRequest is the request body of update preferences API.
+	// Preferences left out are unchanged, and a null value resets the preference to its default.
+	PreferencesUpdateRequest struct {
+		Preferences map[string]interface{} `json:"preferences" binding:"required"`
+	}
+
+	// PreferencesRequest is the query of bulk preferences API, `Keys` selects the preferences returned
+	PreferencesRequest struct {
+		UserIDs []int    `form:"user_id" binding:"required,min=1,max=100"`
+		Keys    []string `form:"key,omitempty"`
+	}
+)
+
+// ParsePreferenceDefinitions returns the built-in preferences along with the comma separated preferences
+// of the config, eg. `theme:enum(light|dark|system)=system,digest_hour:int(0..23)=9,beta:bool=false`.
+// A preference of the config replaces the built-in preference of the same key.
+func ParsePreferenceDefinitions(s string) (PreferenceDefinitions, error) {
+	byKey := map[string]PreferenceDefinition{}
+	for _, d := range builtinPreferences {
+		byKey[d.Key] = d
+	}
+	for _, decl := range strings.Split(s, ",") {
+		if decl = strings.TrimSpace(decl); decl == "" {
+			continue
+		}
+		d, err := parsePreferenceDefinition(decl)
+		if err != nil {
+			return nil, err
+		}
+		// a built-in preference keeps its validation if its type is unchanged, eg. another default language
+		if b, ok := byKey[d.Key]; ok && b.check != nil && b.Type == d.Type {
+			d.check = b.check
+			if _, err = d.normalize(d.Default); err != nil {
+				return nil, fmt.Errorf("invalid default of preference %s: %s", d.Key, err)
+			}
+		}
+		byKey[d.Key] = d
+	}
+
+	defs := make(PreferenceDefinitions, 0, len(byKey))
+	for _, d := range byKey {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Key < defs[j].Key })
+	return defs, nil
+}
+
+// parsePreferenceDefinition parses a preference declared as `key:type(args)=default`, args are the values
+// of an enum separated by `|`, or the bounds of an int, eg. `0..23`, `1..` or `..100`
+func parsePreferenceDefinition(decl string) (d PreferenceDefinition, err error) {
+	m := preferenceDefinitionPattern.FindStringSubmatch(decl)
+	if m == nil {
+		return d, fmt.Errorf("invalid preference %s", decl)
+	}
+	d = PreferenceDefinition{Key: m[1], Type: PreferenceType(m[2])}
+	args, def := m[3], m[4]
+
+	switch d.Type {
+	case PreferenceEnum:
+		for _, v := range strings.Split(args, "|") {
+			if v = strings.TrimSpace(v); v != "" {
+				d.Values = append(d.Values, v)
+			}
+		}
+		if len(d.Values) == 0 {
+			return d, fmt.Errorf("preference %s has no values", d.Key)
+		}
+		d.Default = def
+	case PreferenceInt:
+		if args != "" {
+			min, max, ok := strings.Cut(args, "..")
+			if !ok {
+				return d, fmt.Errorf("invalid bounds of preference %s", d.Key)
+			}
+			if d.Min, err = parseBound(min); err != nil {
+				return d, fmt.Errorf("invalid bounds of preference %s", d.Key)
+			}
+			if d.Max, err = parseBound(max); err != nil {
+				return d, fmt.Errorf("invalid bounds of preference %s", d.Key)
+			}
+		}
+		if d.Default, err = strconv.Atoi(def); err != nil {
+			return d, fmt.Errorf("invalid default of preference %s", d.Key)
+		}
+	case PreferenceBool:
+		if d.Default, err = strconv.ParseBool(def); err != nil {
+			return d, fmt.Errorf("invalid default of preference %s", d.Key)
+		}
+	default:
+		if args != "" {
+			return d, fmt.Errorf("preference %s of type %s takes no arguments", d.Key, d.Type)
+		}
+		d.Default = def
+	}
+
+	if d.Default, err = d.normalize(d.Default); err != nil {
+		return d, fmt.Errorf("invalid default of preference %s: %s", d.Key, err)
+	}
+	return d, nil
+}
+
+func parseBound(s string) (*int, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	return &n, err
+}
+
+// find returns the definition of the preference, nil if the preference is not defined
+func (defs PreferenceDefinitions) find(key string) *PreferenceDefinition {
+	for i := range defs {
+		if defs[i].Key == key {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+// normalize returns the value as the type of the preference, eg. JSON numbers as int,
+// or an error if the value is not valid
+func (d *PreferenceDefinition) normalize(v interface{}) (interface{}, error) {
+	switch d.Type {
+	case PreferenceBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, errors.New("must be a boolean")
+	case PreferenceInt:
+		var n int
+		switch v := v.(type) {
+		case int:
+			n = v
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+				return nil, errors.New("must be an integer")
+			}
+			n = int(v)
+		default:
+			return nil, errors.New("must be an integer")
+		}
+		if d.Min != nil && n < *d.Min {
+			return nil, fmt.Errorf("must be at least %d", *d.Min)
+		}
+		if d.Max != nil && n > *d.Max {
+			return nil, fmt.Errorf("must be at most %d", *d.Max)
+		}
+		return n, nil
+	case PreferenceEnum:
+		s, ok := v.(string)
+		if !ok || !containsString(d.Values, s) {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(d.Values, ", "))
+		}
+		return s, nil
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		if len(s) > preferenceStringMaxLength {
+			return nil, fmt.Errorf("must be at most %d characters", preferenceStringMaxLength)
+		}
+		if d.check != nil {
+			if err := d.check(s); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+}
+
+// validateKeys returns `er.InvalidPreference` listing the keys which are not defined
+func (defs PreferenceDefinitions) validateKeys(keys []string) error {
+	var fields []er.FieldError
+	for _, key := range keys {
+		if defs.find(key) == nil {
+			fields = append(fields, er.FieldError{Path: "key", Message: "unknown preference " + key})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return er.New(errors.New("unknown preferences"), er.InvalidPreference).
+		SetStatus(http.StatusUnprocessableEntity).
+		SetFields(fields...)
+}
+
+// effective returns the preferences of the user, the values it set override the defaults.
+// Values no longer valid, eg. since the preference changed, fall back to the default.
+// Only the preferences of keys are returned, or all of them if keys is empty.
+func (defs PreferenceDefinitions) effective(userID int, set []Preference, keys []string) *UserPreferences {
+	p := &UserPreferences{UserID: userID, Preferences: map[string]interface{}{}, Overridden: []string{}}
+	for _, d := range defs {
+		if len(keys) == 0 || containsString(keys, d.Key) {
+			p.Preferences[d.Key] = d.Default
+		}
+	}
+	for _, s := range set {
+		if _, ok := p.Preferences[s.Key]; !ok {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(s.Value, &v); err != nil {
+			continue
+		}
+		if v, err := defs.find(s.Key).normalize(v); err == nil {
+			p.Preferences[s.Key] = v
+			p.Overridden = append(p.Overridden, s.Key)
+		}
+	}
+	sort.Strings(p.Overridden)
+	return p
+}
@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// FetchPreferences fetches the preferences set by the users
+func (r *PGRepo) FetchPreferences(ctx context.Context, userIDs []int) (prefs []Preference, err error) {
+	prefs = []Preference{}
+	err = tenant.Query(ctx, r.db, &prefs).
+		Where("user_id IN (?)", pg.In(userIDs)).
+		Order("user_id ASC", "key ASC").
+		Select()
+	return
+}
+
+// SavePreferences sets the preferences of the user and deletes the preferences of the reset keys,
+// so that they have their default value again
+func (r *PGRepo) SavePreferences(ctx context.Context, userID int, set []Preference, reset []string) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if len(reset) > 0 {
+			_, err = tenant.Query(ctx, tx, (*Preference)(nil)).
+				Where("user_id = ?", userID).
+				Where("key IN (?)", pg.In(reset)).
+				Delete()
+			if err != nil {
+				return
+			}
+		}
+		if len(set) > 0 {
+			_, err = tenant.Query(ctx, tx, &set).
+				OnConflict("(user_id, key) DO UPDATE").
+				Set("value = EXCLUDED.value").
+				Set("updated_at = EXCLUDED.updated_at").
+				Insert()
+		}
+		return
+	})
+}
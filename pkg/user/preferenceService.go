@@ -0,0 +1,110 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"gouser/er"
+)
+
+// PreferenceDefinitions returns the preferences of users, the built-in ones along with the `preferences` config
+func (s *Service) PreferenceDefinitions() PreferenceDefinitions {
+	return s.preferences
+}
+
+// FetchPreferences returns the effective preferences of the user, defaults merged with the values it set
+func (s *Service) FetchPreferences(ctx context.Context, userID int) (prefs *UserPreferences, err error) {
+	defs := s.PreferenceDefinitions()
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	set, err := s.Repo.FetchPreferences(ctx, []int{userID})
+	if err != nil {
+		return
+	}
+	return defs.effective(userID, set, nil), nil
+}
+
+// UpdatePreferences sets the preferences of the request, a null value resets the preference to its default.
+// It returns `er.InvalidPreference` listing the unknown preferences and the invalid values.
+func (s *Service) UpdatePreferences(ctx context.Context, userID int, req *PreferencesUpdateRequest) (prefs *UserPreferences, err error) {
+	defs := s.PreferenceDefinitions()
+
+	now := time.Now()
+	set := []Preference{}
+	reset := []string{}
+	fields := []er.FieldError{}
+	for key, value := range req.Preferences {
+		d := defs.find(key)
+		if d == nil {
+			fields = append(fields, er.FieldError{Path: "/preferences/" + key, Message: "unknown preference"})
+			continue
+		}
+		if value == nil {
+			reset = append(reset, key)
+			continue
+		}
+		v, err := d.normalize(value)
+		if err != nil {
+			fields = append(fields, er.FieldError{Path: "/preferences/" + key, Message: err.Error()})
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, Preference{UserID: userID, Key: key, Value: b, UpdatedAt: now})
+	}
+	if len(fields) > 0 {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+		return nil, er.New(errors.New("invalid preferences"), er.InvalidPreference).
+			SetStatus(http.StatusUnprocessableEntity).
+			SetFields(fields...)
+	}
+
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	if err = s.Repo.SavePreferences(ctx, userID, set, reset); err != nil {
+		return
+	}
+	return s.FetchPreferences(ctx, userID)
+}
+
+// FetchBulkPreferences returns the effective preferences of the users, in the order of the request,
+// limited to the preferences of `req.Keys` if any. It returns `er.UserNotFound` if any of the users does not exist.
+func (s *Service) FetchBulkPreferences(ctx context.Context, req *PreferencesRequest) (prefs []*UserPreferences, err error) {
+	defs := s.PreferenceDefinitions()
+	if err = defs.validateKeys(req.Keys); err != nil {
+		return
+	}
+
+	userIDs := uniqueInts(req.UserIDs)
+	found, err := s.Repo.FetchUserIDs(ctx, userIDs)
+	if err != nil {
+		return
+	}
+	if len(found) != len(userIDs) {
+		err = fmt.Errorf("users %v not found", missingInts(userIDs, found))
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	set, err := s.Repo.FetchPreferences(ctx, userIDs)
+	if err != nil {
+		return
+	}
+
+	byUser := map[int][]Preference{}
+	for _, p := range set {
+		byUser[p.UserID] = append(byUser[p.UserID], p)
+	}
+	prefs = make([]*UserPreferences, 0, len(userIDs))
+	for _, id := range userIDs {
+		prefs = append(prefs, defs.effective(id, byUser[id], req.Keys))
+	}
+	return
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestParsePreferenceDefinitions(t *testing.T) {
+	defs, err := ParsePreferenceDefinitions(
+		" theme:enum(light|dark|system)=system, digest_hour:int(0..23)=9,beta:bool=false,,nickname:string=,limit:int(1..)=10,language:string=fr")
+	if err != nil {
+		t.Fatalf("ParsePreferenceDefinitions failed: %v", err)
+	}
+
+	keys := []string{}
+	for _, d := range defs {
+		keys = append(keys, d.Key)
+	}
+	want := []string{"beta", "digest_hour", "language", "limit", "nickname",
+		"notifications.email", "notifications.push", "notifications.sms", "theme", "timezone"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+
+	tests := []PreferenceDefinition{
+		{Key: "theme", Type: PreferenceEnum, Default: "system", Values: []string{"light", "dark", "system"}},
+		{Key: "digest_hour", Type: PreferenceInt, Default: 9, Min: intPtr(0), Max: intPtr(23)},
+		{Key: "limit", Type: PreferenceInt, Default: 10, Min: intPtr(1)},
+		{Key: "beta", Type: PreferenceBool, Default: false},
+		{Key: "nickname", Type: PreferenceString, Default: ""},
+		// a built-in preference is replaced
+		{Key: "language", Type: PreferenceString, Default: "fr"},
+	}
+	for _, tt := range tests {
+		got := *defs.find(tt.Key)
+		got.check = nil
+		if !reflect.DeepEqual(got, tt) {
+			t.Errorf("preference %s = %+v, want %+v", tt.Key, got, tt)
+		}
+	}
+
+	// the replaced built-in preference keeps its validation
+	if _, err = defs.find("language").normalize("not a language"); err == nil {
+		t.Error("language accepts an invalid language tag")
+	}
+}
+
+func TestParsePreferenceDefinitionsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"theme",
+		"theme:enum(light|dark)",
+		"Theme:bool=true",
+		"theme:color=red",
+		"theme:enum()=light",
+		"theme:enum(light|dark)=blue",
+		"hour:int(0..23)=24",
+		"hour:int(0-23)=1",
+		"hour:int(a..b)=1",
+		"hour:int=nine",
+		"beta:bool=yes please",
+		"name:string(1..3)=x",
+		"language:string=not a language",
+		"timezone:string=Mars/Olympus",
+	} {
+		if _, err := ParsePreferenceDefinitions(s); err == nil {
+			t.Errorf("ParsePreferenceDefinitions(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestPreferenceNormalize(t *testing.T) {
+	defs, err := ParsePreferenceDefinitions("hour:int(0..23)=9,theme:enum(light|dark)=light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key   string
+		value interface{}
+		want  interface{}
+		valid bool
+	}{
+		{"hour", 5.0, 5, true},
+		{"hour", 5.5, nil, false},
+		{"hour", 24.0, nil, false},
+		{"hour", -1.0, nil, false},
+		{"hour", "5", nil, false},
+		{"theme", "dark", "dark", true},
+		{"theme", "blue", nil, false},
+		{"notifications.email", false, false, true},
+		{"notifications.email", "false", nil, false},
+		{"timezone", "Asia/Kolkata", "Asia/Kolkata", true},
+		{"timezone", "Local", nil, false},
+		{"timezone", "", nil, false},
+		{"language", "pt-BR", "pt-BR", true},
+		{"language", 1.0, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := defs.find(tt.key).normalize(tt.value)
+		if tt.valid && (err != nil || got != tt.want) {
+			t.Errorf("normalize %s %v = %v, %v, want %v", tt.key, tt.value, got, err, tt.want)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("normalize %s %v = %v, want an error", tt.key, tt.value, got)
+		}
+	}
+}
+
+func TestPreferencesEffective(t *testing.T) {
+	defs, err := ParsePreferenceDefinitions("hour:int(0..23)=9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := []Preference{
+		{Key: "hour", Value: json.RawMessage(`7`)},
+		{Key: "timezone", Value: json.RawMessage(`"Europe/Paris"`)},
+		// values no longer valid fall back to the default
+		{Key: "language", Value: json.RawMessage(`42`)},
+		// preferences no longer defined are left out
+		{Key: "removed", Value: json.RawMessage(`true`)},
+	}
+
+	p := defs.effective(1, set, nil)
+	if p.Preferences["hour"] != 7 || p.Preferences["timezone"] != "Europe/Paris" || p.Preferences["language"] != "en" {
+		t.Errorf("effective preferences %v", p.Preferences)
+	}
+	if _, ok := p.Preferences["removed"]; ok || len(p.Preferences) != len(defs) {
+		t.Errorf("effective preferences %v, want the %d defined preferences", p.Preferences, len(defs))
+	}
+	if !reflect.DeepEqual(p.Overridden, []string{"hour", "timezone"}) {
+		t.Errorf("overridden %v, want [hour timezone]", p.Overridden)
+	}
+
+	p = defs.effective(1, set, []string{"hour", "notifications.sms"})
+	want := map[string]interface{}{"hour": 7, "notifications.sms": true}
+	if !reflect.DeepEqual(p.Preferences, want) || !reflect.DeepEqual(p.Overridden, []string{"hour"}) {
+		t.Errorf("effective preferences of keys %v, overridden %v", p.Preferences, p.Overridden)
+	}
+}
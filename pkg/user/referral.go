@@ -0,0 +1,129 @@
+package user
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// referralCodeAlphabet leaves out characters easily mistaken for each other, eg. 0 and O
+const referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const (
+	referralCodeLength = 8
+	// referralCodeConstraint is the unique index of referral codes, see `Service.CreateUser`
+	referralCodeConstraint = "user_referral_code_key"
+	// referralTreeMaxNodes limits the number of users of a referral tree
+	referralTreeMaxNodes = 1000
+)
+
+var (
+	// ErrReferrerAlreadySet is returned when the referrer of a user is set twice
+	ErrReferrerAlreadySet = errors.New("user already has a referrer")
+	// ErrReferralCycle is returned when a user would be referred by one of its own referrals
+	ErrReferralCycle = errors.New("referral cycle")
+)
+
+type (
+	// ReferrerRequest names the referrer of a user by its referral code or its ID
+	ReferrerRequest struct {
+		ReferralCode string `json:"referral_code,omitempty"`
+		ReferredBy   *int   `json:"referred_by,omitempty"`
+	}
+
+	// ReferralTreeRequest is the query of referral tree API, depth 1 lists the users referred directly
+	ReferralTreeRequest struct {
+		Depth int `form:"depth,default=3"`
+	}
+
+	// ReferralTree lists the users referred by the user, and the users they referred in turn, up to `Depth`.
+	// Deleted users and the users they referred are left out.
+	ReferralTree struct {
+		UserID    int             `json:"user_id"`
+		Depth     int             `json:"depth"`
+		Referrals []*ReferralNode `json:"referrals"`
+		// Truncated is set if the tree has more than `referralTreeMaxNodes` users, the deepest are left out
+		Truncated bool `json:"truncated,omitempty"`
+	}
+
+	// ReferralNode is a user of a referral tree along with the users it referred
+	ReferralNode struct {
+		ID           int             `json:"id" pg:"id"`
+		FirstName    string          `json:"first_name" pg:"first_name"`
+		LastName     string          `json:"last_name" pg:"last_name"`
+		ReferralCode string          `json:"referral_code" pg:"referral_code"`
+		Status       Status          `json:"status" pg:"status"`
+		ReferredBy   int             `json:"-" pg:"referred_by"`
+		ReferredAt   *time.Time      `json:"referred_at" pg:"referred_at"`
+		Depth        int             `json:"depth" pg:"depth"`
+		Referrals    []*ReferralNode `json:"referrals,omitempty" pg:"-"`
+	}
+
+	// ReferralStats counts the users, not deleted, referred by a referrer
+	ReferralStats struct {
+		tableName struct{} `pg:"user,alias:user"`
+		UserID    int      `json:"user_id" pg:"user_id"`
+		// Direct is the number of users referred by the user, Active the number of them who are active
+		Direct int `json:"direct" pg:"direct"`
+		Active int `json:"active" pg:"active"`
+		// Total is the number of users in the referral tree of the user up to the maximum depth,
+		// only counted for the stats of a single user
+		Total           int        `json:"total,omitempty" pg:"-"`
+		FirstReferralAt *time.Time `json:"first_referral_at,omitempty" pg:"first_referral_at"`
+		LastReferralAt  *time.Time `json:"last_referral_at,omitempty" pg:"last_referral_at"`
+
+		tenant.Owned
+	}
+
+	// ReferralStatsRequest is the query of referrers stats API, `From` and `To` filter the referrals by `referred_at`
+	ReferralStatsRequest struct {
+		From  *time.Time `form:"from,omitempty"`
+		To    *time.Time `form:"to,omitempty"`
+		Page  int        `form:"page,default=1"`
+		Limit int        `form:"limit,default=20"`
+	}
+)
+
+// NormalizeReferralCode uppercases the code and trims spaces, codes are case-insensitive
+func NormalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newReferralCode returns a random referral code of `referralCodeLength` characters of `referralCodeAlphabet`
+func newReferralCode() (string, error) {
+	b := make([]byte, referralCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// the alphabet has 32 characters, so that every character is equally likely
+		b[i] = referralCodeAlphabet[int(b[i])%len(referralCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// buildReferralTree nests the nodes, ordered by depth, under the users who referred them
+func buildReferralTree(userID int, nodes []*ReferralNode) []*ReferralNode {
+	roots := []*ReferralNode{}
+	byID := make(map[int]*ReferralNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+		if n.ReferredBy == userID {
+			roots = append(roots, n)
+		} else if parent := byID[n.ReferredBy]; parent != nil {
+			parent.Referrals = append(parent.Referrals, n)
+		}
+	}
+	return roots
+}
+
+// isReferralCodeViolation checks if err is a violation of the unique index of referral codes
+func isReferralCodeViolation(err error) bool {
+	pgErr, ok := err.(pg.Error)
+	return ok && pgErr.Field('C') == pgUniqueViolation && pgErr.Field('n') == referralCodeConstraint
+}
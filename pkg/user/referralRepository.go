@@ -0,0 +1,171 @@
+package user
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// referralLockKey is the advisory lock taken, along with the tenant ID, to change referrers of a tenant
+const referralLockKey = 20
+
+// referralTreeQuery selects the users referred by a user, and the users they referred in turn, up to a depth.
+// Deleted users and the users they referred are left out.
+const referralTreeQuery = `WITH RECURSIVE referrals AS (
+		SELECT u.id, 1 AS depth FROM "user" AS u
+		WHERE u.referred_by = ?0 AND u.tenant_id = ?1 AND u.deleted_at IS NULL
+		UNION ALL
+		SELECT u.id, r.depth + 1 FROM "user" AS u JOIN referrals AS r ON u.referred_by = r.id
+		WHERE r.depth < ?2 AND u.tenant_id = ?1 AND u.deleted_at IS NULL
+	)`
+
+// lockReferrals locks the referrers of the tenant, they are changed one at a time so that two concurrent
+// changes cannot close a cycle
+func lockReferrals(ctx context.Context, tx *pg.Tx, tenantID int) (err error) {
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, ?::int)", referralLockKey, tenantID)
+	return
+}
+
+// isReferrer reports whether the user is the referred user or one of its referrers, directly or not
+func isReferrer(ctx context.Context, tx *pg.Tx, tenantID, userID, referredID int) (referrer bool, err error) {
+	_, err = tx.QueryOneContext(ctx, pg.Scan(&referrer), `WITH RECURSIVE referrers AS (
+			SELECT id, referred_by FROM "user" WHERE id = ?0 AND tenant_id = ?1
+			UNION
+			SELECT u.id, u.referred_by FROM "user" AS u JOIN referrers AS r ON u.id = r.referred_by
+			WHERE u.tenant_id = ?1
+		)
+		SELECT EXISTS (SELECT 1 FROM referrers WHERE id = ?2)`, referredID, tenantID, userID)
+	return
+}
+
+func (r *PGRepo) FetchByReferralCode(ctx context.Context, code string) (user *User, err error) {
+	user = &User{}
+	err = tenant.Query(ctx, r.db, user).Where("referral_code = ?", code).Select()
+	return
+}
+
+// SetReferrer sets the referrer of the user and records the change in the audit trail. It returns
+// `ErrReferrerAlreadySet` if the user already has a referrer and `ErrReferralCycle` if the referrer
+// was referred, directly or not, by the user.
+func (r *PGRepo) SetReferrer(ctx context.Context, userID, referrerID int, at time.Time) (err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if err = lockReferrals(ctx, tx, tenantID); err != nil {
+			return
+		}
+		before := &User{ID: userID}
+		if err = tenant.Query(ctx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		if before.ReferredBy != nil {
+			return ErrReferrerAlreadySet
+		}
+
+		cycle, err := isReferrer(ctx, tx, tenantID, userID, referrerID)
+		if err != nil {
+			return
+		}
+		if cycle {
+			return ErrReferralCycle
+		}
+
+		_, err = tenant.Query(ctx, tx, (*User)(nil)).
+			Set("referred_by = ?", referrerID).
+			Set("referred_at = ?", at).
+			Set("updated_at = ?", at).
+			Set("version = version + 1").
+			Where("id = ?", userID).
+			Update()
+		if err != nil {
+			return
+		}
+		after := &User{ID: userID}
+		if err = tenant.Query(ctx, tx, after).WherePK().Select(); err != nil {
+			return
+		}
+		return r.insertAudit(ctx, tx, AuditUpdate, before, after)
+	})
+}
+
+// FetchReferralTree fetches up to `limit` users of the referral tree of the user, ordered by depth
+func (r *PGRepo) FetchReferralTree(ctx context.Context, userID, depth, limit int) (nodes []*ReferralNode, err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrNoTenant
+	}
+	nodes = []*ReferralNode{}
+	_, err = r.db.QueryContext(ctx, &nodes, referralTreeQuery+`
+		SELECT u.id, u.first_name, u.last_name, u.referral_code, u.status, u.referred_by, u.referred_at, r.depth
+		FROM referrals AS r JOIN "user" AS u ON u.id = r.id
+		ORDER BY r.depth, u.referred_at, u.id
+		LIMIT ?3`, userID, tenantID, depth, limit)
+	return
+}
+
+// CountReferrals counts the users of the referral tree of the user
+func (r *PGRepo) CountReferrals(ctx context.Context, userID, depth int) (count int, err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return 0, tenant.ErrNoTenant
+	}
+	_, err = r.db.QueryOneContext(ctx, pg.Scan(&count), referralTreeQuery+`
+		SELECT count(*) FROM referrals`, userID, tenantID, depth)
+	return
+}
+
+// FetchReferralStats fetches the stats of the users referred by the user, zero if the user referred none
+func (r *PGRepo) FetchReferralStats(ctx context.Context, userID int) (stats *ReferralStats, err error) {
+	rows := []ReferralStats{}
+	err = referralStatsQuery(ctx, r.db, &rows).Where("referred_by = ?", userID).Select()
+	if err != nil {
+		return
+	}
+	if len(rows) == 0 {
+		return &ReferralStats{UserID: userID}, nil
+	}
+	return &rows[0], nil
+}
+
+// FetchReferrerStats fetches the stats of the users who referred other users, most referrals first
+func (r *PGRepo) FetchReferrerStats(ctx context.Context, req *ReferralStatsRequest) (stats []ReferralStats, pagination Pagination, err error) {
+	stats = []ReferralStats{}
+	query := referralStatsQuery(ctx, r.db, &stats).Where("referred_by IS NOT NULL")
+	if req.From != nil {
+		query.Where("referred_at >= ?", req.From)
+	}
+	if req.To != nil {
+		query.Where("referred_at < ?", req.To)
+	}
+	count, err := query.
+		Order("direct DESC", "user_id ASC").
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return
+}
+
+// referralStatsQuery groups the users, not deleted, by their referrer
+func referralStatsQuery(ctx context.Context, db orm.DB, model interface{}) *orm.Query {
+	return tenant.Query(ctx, db, model).
+		ColumnExpr("referred_by AS user_id").
+		ColumnExpr("count(*) AS direct").
+		ColumnExpr("count(*) FILTER (WHERE status = ?) AS active", StatusActive).
+		ColumnExpr("min(referred_at) AS first_referral_at").
+		ColumnExpr("max(referred_at) AS last_referral_at").
+		Where("deleted_at IS NULL").
+		Group("referred_by")
+}
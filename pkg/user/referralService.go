@@ -0,0 +1,114 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// referralCodeAttempts is the number of codes drawn for a new user before giving up on a taken code
+const referralCodeAttempts = 3
+
+// ResolveReferrer returns the ID of the user named by the referral code or the ID of the request,
+// 0 if the request names no referrer. Deleted users cannot refer users.
+func (s *Service) ResolveReferrer(ctx context.Context, req *ReferrerRequest) (referrerID int, err error) {
+	var referrer *User
+	code := NormalizeReferralCode(req.ReferralCode)
+	switch {
+	case code != "":
+		referrer, err = s.Repo.FetchByReferralCode(ctx, code)
+	case req.ReferredBy != nil:
+		referrer, err = s.Repo.Fetch(ctx, *req.ReferredBy)
+	default:
+		return 0, nil
+	}
+	if err == pg.ErrNoRows {
+		err = er.New(errors.New("referrer not found"), er.ReferrerNotFound).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err != nil {
+		return
+	}
+	if req.ReferredBy != nil && *req.ReferredBy != referrer.ID {
+		err = errors.New("referral_code and referred_by name different users")
+		return 0, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return referrer.ID, nil
+}
+
+// SetReferrer sets the referrer of a user created without one. The referrer cannot be changed once set,
+// and cannot be the user itself or a user referred, directly or not, by the user.
+func (s *Service) SetReferrer(ctx context.Context, userID int, req *ReferrerRequest) (user *User, err error) {
+	referrerID, err := s.ResolveReferrer(ctx, req)
+	if err != nil {
+		return
+	}
+	if referrerID == 0 {
+		err = errors.New("referral_code or referred_by is required")
+		return nil, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if referrerID == userID {
+		err = errors.New("user cannot refer itself")
+		return nil, er.New(err, er.SelfReferral).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	err = s.Repo.SetReferrer(ctx, userID, referrerID, time.Now())
+	switch err {
+	case nil:
+	case pg.ErrNoRows:
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	case ErrReferrerAlreadySet:
+		return nil, er.New(err, er.ReferrerAlreadySet).SetStatus(http.StatusConflict)
+	case ErrReferralCycle:
+		return nil, er.New(err, er.ReferralCycle).SetStatus(http.StatusUnprocessableEntity)
+	default:
+		return nil, err
+	}
+	return s.Repo.Fetch(ctx, userID)
+}
+
+// FetchReferralTree returns the referral tree of the user up to `req.Depth`,
+// which must not exceed `referral_tree_max_depth`
+func (s *Service) FetchReferralTree(ctx context.Context, userID int, req *ReferralTreeRequest) (tree *ReferralTree, err error) {
+	if maxDepth := s.conf.GetInt("referral_tree_max_depth"); req.Depth < 1 || req.Depth > maxDepth {
+		err = fmt.Errorf("depth must be between 1 and %d", maxDepth)
+		return nil, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	nodes, err := s.Repo.FetchReferralTree(ctx, userID, req.Depth, referralTreeMaxNodes+1)
+	if err != nil {
+		return
+	}
+	tree = &ReferralTree{UserID: userID, Depth: req.Depth}
+	if len(nodes) > referralTreeMaxNodes {
+		nodes = nodes[:referralTreeMaxNodes]
+		tree.Truncated = true
+	}
+	tree.Referrals = buildReferralTree(userID, nodes)
+	return
+}
+
+// FetchReferralStats returns the stats of the users referred by the user,
+// the total counts the referral tree of the user up to `referral_tree_max_depth`
+func (s *Service) FetchReferralStats(ctx context.Context, userID int) (stats *ReferralStats, err error) {
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	if stats, err = s.Repo.FetchReferralStats(ctx, userID); err != nil {
+		return
+	}
+	stats.Total, err = s.Repo.CountReferrals(ctx, userID, s.conf.GetInt("referral_tree_max_depth"))
+	return
+}
+
+// FetchReferrerStats lists the stats of the users who referred other users, most referrals first
+func (s *Service) FetchReferrerStats(ctx context.Context, req *ReferralStatsRequest) (stats []ReferralStats, pagination Pagination, err error) {
+	return s.Repo.FetchReferrerStats(ctx, req)
+}
@@ -0,0 +1,120 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// RelationshipType is the type of a directional relationship from a user to a related user
+type RelationshipType string
+
+// relationship types, see `relationshipRules` for their constraints
+const (
+	// RelationshipParent relates a parent to its child
+	RelationshipParent RelationshipType = "parent"
+	// RelationshipSpouse relates spouses, both ways
+	RelationshipSpouse RelationshipType = "spouse"
+	// RelationshipGuardian relates a legal guardian to its ward
+	RelationshipGuardian RelationshipType = "guardian"
+	// RelationshipBlocked relates a user to a user it blocked
+	RelationshipBlocked RelationshipType = "blocked"
+)
+
+// RelationshipStatus is the state of a relationship, relationships which need no confirmation are created accepted
+type RelationshipStatus string
+
+// relationship statuses
+const (
+	RelationshipPending  RelationshipStatus = "pending"
+	RelationshipAccepted RelationshipStatus = "accepted"
+)
+
+// relationshipRule are the constraints of a relationship type
+type relationshipRule struct {
+	// Symmetric relationships hold both ways, eg. spouses, so the users can be related only once.
+	// Antisymmetric relationships cannot be reversed, eg. a user cannot be the parent of its own parent.
+	Symmetric     bool
+	Antisymmetric bool
+	// Confirmed relationships are pending until the related user accepts them, and either user may remove them.
+	// Other relationships are one-sided, only the user they are created from may remove them.
+	Confirmed bool
+	// MaxFrom limits the relationships of the type from a user and MaxTo the relationships to a user,
+	// counting pending ones, 0 is unlimited. Both apply to either user of symmetric relationships.
+	MaxFrom int
+	MaxTo   int
+}
+
+// relationshipRules are the constraints of each relationship type
+var relationshipRules = map[RelationshipType]relationshipRule{
+	RelationshipParent:   {Antisymmetric: true, Confirmed: true, MaxTo: 2},
+	RelationshipSpouse:   {Symmetric: true, Confirmed: true, MaxFrom: 1, MaxTo: 1},
+	RelationshipGuardian: {Antisymmetric: true, Confirmed: true, MaxTo: 2},
+	RelationshipBlocked:  {},
+}
+
+var (
+	// ErrRelationshipExists is returned when the users are already related with the type
+	ErrRelationshipExists = errors.New("relationship already exists")
+	// ErrRelationshipReversed is returned when an antisymmetric relationship would be reversed
+	ErrRelationshipReversed = errors.New("relationship cannot be reversed")
+	// ErrRelationshipLimit is returned when either user would exceed the maximum relationships of the type
+	ErrRelationshipLimit = errors.New("relationship limit reached")
+	// ErrRelationshipBlocked is returned when either user blocked the other
+	ErrRelationshipBlocked = errors.New("relationship blocked")
+	// ErrRelationshipNotPending is returned when a relationship cannot be accepted by the user
+	ErrRelationshipNotPending = errors.New("relationship not pending")
+)
+
+type (
+	// Relationship relates a user to another user. A pending relationship is accepted by the related user.
+	Relationship struct {
+		tableName     struct{}           `pg:"user_relationships,alias:relationship,discard_unknown_columns"`
+		ID            int                `json:"id" pg:"id"`
+		Type          RelationshipType   `json:"type" pg:"type,notnull"`
+		UserID        int                `json:"user_id" pg:"user_id,notnull"`
+		RelatedUserID int                `json:"related_user_id" pg:"related_user_id,notnull"`
+		Status        RelationshipStatus `json:"status" pg:"status,notnull"`
+		Metadata      interface{}        `json:"metadata,omitempty" pg:"metadata,type:jsonb"`
+		CreatedAt     *time.Time         `json:"created_at" pg:"created_at"`
+		AcceptedAt    *time.Time         `json:"accepted_at,omitempty" pg:"accepted_at"`
+
+		tenant.Owned
+	}
+
+	// RelationshipRequest is the request body of create relationship API, the relationship is from the user
+	// of the path to the related user
+	RelationshipRequest struct {
+		Type          RelationshipType `json:"type" binding:"required"`
+		RelatedUserID int              `json:"related_user_id" binding:"required"`
+		Metadata      interface{}      `json:"metadata,omitempty"`
+	}
+
+	// RelationshipsRequest is the query of list relationships API. Direction `outgoing` filters the relationships
+	// from the user and `incoming` those to the user, both are listed by default.
+	RelationshipsRequest struct {
+		Type      []string `form:"type,omitempty"`
+		Status    *string  `form:"status,omitempty"`
+		Direction *string  `form:"direction,omitempty"`
+		Page      int      `form:"page,default=1"`
+		Limit     int      `form:"limit,default=20"`
+	}
+)
+
+// ParseRelationshipType returns `er.InvalidRelationship` if t is not a known relationship type
+func ParseRelationshipType(t string) (RelationshipType, error) {
+	typ := RelationshipType(t)
+	if _, ok := relationshipRules[typ]; !ok {
+		err := errors.New("invalid relationship type " + t)
+		return "", er.New(err, er.InvalidRelationship).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return typ, nil
+}
+
+// rule returns the constraints of the type of the relationship
+func (r *Relationship) rule() relationshipRule {
+	return relationshipRules[r.Type]
+}
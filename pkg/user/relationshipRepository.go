@@ -0,0 +1,244 @@
+package user
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// CreateRelationship inserts the relationship after checking the constraints of its type, see `relationshipRule`.
+// Both users are locked so that concurrent relationships of the users cannot break the constraints.
+func (r *PGRepo) CreateRelationship(ctx context.Context, rel *Relationship) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		if err = r.lockUsers(ctx, tx, rel.UserID, rel.RelatedUserID); err != nil {
+			return
+		}
+		if err = r.checkRelationship(ctx, tx, rel); err != nil {
+			return
+		}
+		_, err = tenant.Query(ctx, tx, rel).Insert()
+		return
+	})
+}
+
+// checkRelationship returns one of the `ErrRelationship*` errors if the relationship breaks the constraints
+// of its type with the other relationships of the users, see `relationshipRule`
+func (r *PGRepo) checkRelationship(ctx context.Context, tx *pg.Tx, rel *Relationship) (err error) {
+	if rel.Type != RelationshipBlocked {
+		if err = r.checkNotBlocked(ctx, tx, rel.UserID, rel.RelatedUserID); err != nil {
+			return
+		}
+	}
+
+	rule := rel.rule()
+	exists, err := relationshipsBetween(ctx, tx, rel.Type, rel.UserID, rel.RelatedUserID).Exists()
+	if err != nil {
+		return
+	}
+	if exists {
+		return ErrRelationshipExists
+	}
+	if rule.Symmetric || rule.Antisymmetric {
+		if exists, err = relationshipsBetween(ctx, tx, rel.Type, rel.RelatedUserID, rel.UserID).Exists(); err != nil {
+			return
+		}
+		if exists && rule.Symmetric {
+			return ErrRelationshipExists
+		}
+		if exists {
+			return ErrRelationshipReversed
+		}
+	}
+	return r.checkRelationshipLimits(ctx, tx, rel)
+}
+
+// isRelationshipConflict reports whether err is returned by `checkRelationship` for a relationship breaking
+// the constraints of its type
+func isRelationshipConflict(err error) bool {
+	switch err {
+	case ErrRelationshipExists, ErrRelationshipReversed, ErrRelationshipLimit, ErrRelationshipBlocked:
+		return true
+	}
+	return false
+}
+
+// AcceptRelationship accepts the pending relationship to the user. It returns `ErrRelationshipNotPending`
+// if the relationship is not pending or the user is not the related user.
+func (r *PGRepo) AcceptRelationship(ctx context.Context, userID, relationshipID int, at time.Time) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		rel := &Relationship{}
+		err = relationshipsOf(ctx, tx, rel, userID).
+			Where("relationship.id = ?", relationshipID).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return
+		}
+		if rel.Status != RelationshipPending || rel.RelatedUserID != userID {
+			return ErrRelationshipNotPending
+		}
+		if err = r.checkNotBlocked(ctx, tx, rel.UserID, rel.RelatedUserID); err != nil {
+			return
+		}
+		_, err = tenant.Query(ctx, tx, (*Relationship)(nil)).
+			Set("status = ?", RelationshipAccepted).
+			Set("accepted_at = ?", at).
+			Where("id = ?", relationshipID).
+			Update()
+		return
+	})
+}
+
+func (r *PGRepo) DeleteRelationship(ctx context.Context, relationshipID int) (err error) {
+	res, err := tenant.Query(ctx, r.db, (*Relationship)(nil)).Where("id = ?", relationshipID).Delete()
+	if err != nil {
+		return
+	}
+	if res.RowsAffected() == 0 {
+		err = pg.ErrNoRows
+	}
+	return
+}
+
+// FetchRelationship fetches the relationship from or to the user
+func (r *PGRepo) FetchRelationship(ctx context.Context, userID, relationshipID int) (rel *Relationship, err error) {
+	rel = &Relationship{}
+	err = relationshipsOf(ctx, r.db, rel, userID).Where("relationship.id = ?", relationshipID).Select()
+	return
+}
+
+// FetchRelationships fetches the relationships from and to the user, latest first.
+// Relationships with deleted users are left out.
+func (r *PGRepo) FetchRelationships(ctx context.Context, userID int, req *RelationshipsRequest) (rels []Relationship, pagination Pagination, err error) {
+	rels = []Relationship{}
+	query := relationshipsOf(ctx, r.db, &rels, userID).
+		Where(`NOT EXISTS (SELECT 1 FROM "user" AS u
+			WHERE u.id IN (relationship.user_id, relationship.related_user_id) AND u.deleted_at IS NOT NULL)`)
+	if len(req.Type) > 0 {
+		query.Where("relationship.type IN (?)", pg.In(req.Type))
+	}
+	if req.Status != nil {
+		query.Where("relationship.status = ?", *req.Status)
+	}
+	if req.Direction != nil {
+		switch *req.Direction {
+		case "outgoing":
+			query.Where("relationship.user_id = ?", userID)
+		case "incoming":
+			query.Where("relationship.related_user_id = ?", userID)
+		}
+	}
+	count, err := query.
+		Order("relationship.created_at DESC", "relationship.id DESC").
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return
+}
+
+// relationshipsOf selects the relationships from or to the user
+func relationshipsOf(ctx context.Context, db orm.DB, model interface{}, userID int) *orm.Query {
+	return tenant.Query(ctx, db, model).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("relationship.user_id = ?", userID).WhereOr("relationship.related_user_id = ?", userID), nil
+		})
+}
+
+// relationshipsBetween selects the relationships of the type from the user to the related user
+func relationshipsBetween(ctx context.Context, db orm.DB, typ RelationshipType, userID, relatedUserID int) *orm.Query {
+	return tenant.Query(ctx, db, (*Relationship)(nil)).
+		Where("type = ?", typ).
+		Where("user_id = ?", userID).
+		Where("related_user_id = ?", relatedUserID)
+}
+
+// lockUsers locks the users, in order of ID to avoid deadlocks. It returns `pg.ErrNoRows` if either user
+// does not exist or is deleted.
+func (r *PGRepo) lockUsers(ctx context.Context, tx *pg.Tx, userIDs ...int) error {
+	users := []User{}
+	err := tenant.Query(ctx, tx, &users).
+		Column("id").
+		Where("id IN (?)", pg.In(userIDs)).
+		Order("id ASC").
+		For("UPDATE").
+		Select()
+	if err != nil {
+		return err
+	}
+	if len(users) != len(uniqueInts(userIDs)) {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// checkNotBlocked returns `ErrRelationshipBlocked` if either user blocked the other
+func (r *PGRepo) checkNotBlocked(ctx context.Context, tx *pg.Tx, userID, relatedUserID int) error {
+	blocked, err := tenant.Query(ctx, tx, (*Relationship)(nil)).
+		Where("type = ?", RelationshipBlocked).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.
+				WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+					return q.Where("user_id = ?", userID).Where("related_user_id = ?", relatedUserID), nil
+				}).
+				WhereOrGroup(func(q *orm.Query) (*orm.Query, error) {
+					return q.Where("user_id = ?", relatedUserID).Where("related_user_id = ?", userID), nil
+				}), nil
+		}).
+		Exists()
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrRelationshipBlocked
+	}
+	return nil
+}
+
+// checkRelationshipLimits returns `ErrRelationshipLimit` if either user already has the maximum relationships
+// of the type, see `relationshipRule`. A saved relationship, eg. moved by a merge, does not count itself.
+func (r *PGRepo) checkRelationshipLimits(ctx context.Context, tx *pg.Tx, rel *Relationship) error {
+	rule := rel.rule()
+	limits := []struct {
+		userID int
+		max    int
+		column string
+	}{
+		{rel.UserID, rule.MaxFrom, "user_id"},
+		{rel.RelatedUserID, rule.MaxTo, "related_user_id"},
+	}
+	for _, limit := range limits {
+		if limit.max == 0 {
+			continue
+		}
+		query := tenant.Query(ctx, tx, (*Relationship)(nil)).Where("type = ?", rel.Type)
+		if rel.ID != 0 {
+			query.Where("id <> ?", rel.ID)
+		}
+		if rule.Symmetric {
+			query.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+				return q.Where("user_id = ?", limit.userID).WhereOr("related_user_id = ?", limit.userID), nil
+			})
+		} else {
+			query.Where("? = ?", pg.Ident(limit.column), limit.userID)
+		}
+		count, err := query.Count()
+		if err != nil {
+			return err
+		}
+		if count >= limit.max {
+			return ErrRelationshipLimit
+		}
+	}
+	return nil
+}
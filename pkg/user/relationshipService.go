@@ -0,0 +1,121 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// CreateRelationship relates the user to the related user. Relationships of types needing confirmation
+// are pending until the related user accepts them, the others are accepted at once.
+func (s *Service) CreateRelationship(ctx context.Context, userID int, req *RelationshipRequest) (rel *Relationship, err error) {
+	typ, err := ParseRelationshipType(string(req.Type))
+	if err != nil {
+		return
+	}
+	if req.RelatedUserID == userID {
+		err = errors.New("user cannot be related to itself")
+		return nil, er.New(err, er.InvalidRelationship).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	now := time.Now()
+	rel = &Relationship{
+		Type:          typ,
+		UserID:        userID,
+		RelatedUserID: req.RelatedUserID,
+		Status:        RelationshipPending,
+		Metadata:      req.Metadata,
+		CreatedAt:     &now,
+	}
+	if !rel.rule().Confirmed {
+		rel.Status = RelationshipAccepted
+		rel.AcceptedAt = &now
+	}
+	err = s.Repo.CreateRelationship(ctx, rel)
+	switch {
+	case err == nil:
+		return
+	case err == pg.ErrNoRows:
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	case isUniqueViolation(err):
+		err = ErrRelationshipExists
+	}
+	return nil, relationshipError(err)
+}
+
+// AcceptRelationship accepts the pending relationship on behalf of the related user
+func (s *Service) AcceptRelationship(ctx context.Context, userID, relationshipID int) (rel *Relationship, err error) {
+	if err = relationshipError(s.Repo.AcceptRelationship(ctx, userID, relationshipID, time.Now())); err != nil {
+		return
+	}
+	return s.Repo.FetchRelationship(ctx, userID, relationshipID)
+}
+
+// RemoveRelationship removes, or declines if pending, the relationship from or to the user.
+// Relationships which need no confirmation, eg. blocks, are removed by the user they are from only.
+func (s *Service) RemoveRelationship(ctx context.Context, userID, relationshipID int) (err error) {
+	rel, err := s.FetchRelationship(ctx, userID, relationshipID)
+	if err != nil {
+		return
+	}
+	if !rel.rule().Confirmed && rel.UserID != userID {
+		err = errors.New("relationship can be removed by the user it is from only")
+		return er.New(err, er.RelationshipNotFound).SetStatus(http.StatusNotFound)
+	}
+	return relationshipError(s.Repo.DeleteRelationship(ctx, relationshipID))
+}
+
+// FetchRelationship fetches the relationship from or to the user
+func (s *Service) FetchRelationship(ctx context.Context, userID, relationshipID int) (rel *Relationship, err error) {
+	rel, err = s.Repo.FetchRelationship(ctx, userID, relationshipID)
+	return rel, relationshipError(err)
+}
+
+// FetchRelationships lists the relationships from and to the user, latest first
+func (s *Service) FetchRelationships(ctx context.Context, userID int, req *RelationshipsRequest) (rels []Relationship, pagination Pagination, err error) {
+	for _, t := range req.Type {
+		if _, err = ParseRelationshipType(t); err != nil {
+			return
+		}
+	}
+	if req.Status != nil {
+		switch RelationshipStatus(*req.Status) {
+		case RelationshipPending, RelationshipAccepted:
+		default:
+			err = errors.New("invalid relationship status " + *req.Status)
+			return nil, pagination, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+		}
+	}
+	if req.Direction != nil && *req.Direction != "outgoing" && *req.Direction != "incoming" {
+		err = errors.New("direction must be outgoing or incoming")
+		return nil, pagination, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	return s.Repo.FetchRelationships(ctx, userID, req)
+}
+
+// relationshipError maps the repository errors of relationships to `er` errors
+func relationshipError(err error) error {
+	switch err {
+	case pg.ErrNoRows:
+		return er.New(err, er.RelationshipNotFound).SetStatus(http.StatusNotFound)
+	case ErrRelationshipExists:
+		return er.New(err, er.RelationshipAlreadyExists).SetStatus(http.StatusConflict)
+	case ErrRelationshipReversed:
+		return er.New(err, er.InvalidRelationship).SetStatus(http.StatusUnprocessableEntity)
+	case ErrRelationshipLimit:
+		return er.New(err, er.RelationshipLimitReached).SetStatus(http.StatusConflict)
+	case ErrRelationshipBlocked:
+		return er.New(err, er.RelationshipBlocked).SetStatus(http.StatusConflict)
+	case ErrRelationshipNotPending:
+		return er.New(err, er.RelationshipNotPending).SetStatus(http.StatusConflict)
+	}
+	return err
+}
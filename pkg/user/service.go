@@ -2,24 +2,92 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
+	"gouser/er"
+	"gouser/pkg/blob"
+	"gouser/pkg/mail"
+
+	"github.com/go-pg/pg/v10"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 type Service struct {
-	conf *viper.Viper
-	log  *logrus.Logger
-	Repo Repository
+	conf   *viper.Viper
+	log    *logrus.Logger
+	mailer mail.Sender
+	blobs  blob.Store
+	Repo   Repository
+
+	// mergePrecedence and preferences are the parsed `merge_precedence` and `preferences` configs
+	mergePrecedence MergePrecedence
+	preferences     PreferenceDefinitions
+}
+
+// NewService returns a user service object. It fails if the `merge_precedence` or `preferences` config is invalid.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, mailer mail.Sender, blobs blob.Store) (*Service, error) {
+	precedence, err := ParseMergePrecedence(conf.GetString("merge_precedence"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge_precedence config: %w", err)
+	}
+	preferences, err := ParsePreferenceDefinitions(conf.GetString("preferences"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid preferences config: %w", err)
+	}
+	return &Service{
+		conf:            conf,
+		log:             log,
+		Repo:            Repo,
+		mailer:          mailer,
+		blobs:           blobs,
+		mergePrecedence: precedence,
+		preferences:     preferences,
+	}, nil
 }
 
-// NewService returns a user service object.
-func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository) *Service {
-	return &Service{conf: conf, log: log, Repo: Repo}
+// NormalizePhone parses a phone number to E.164 format using the configured default region
+func (s Service) NormalizePhone(raw string) (*PhoneNumber, error) {
+	return ParsePhoneNumber(raw, s.conf.GetString("phone_default_region"))
 }
 
 func (s Service) CreateUser(ctx context.Context, user *User) (err error) {
-	return s.Repo.CreateUser(ctx, user)
+	if err = s.setPrimaryPhone(user); err != nil {
+		return
+	}
+	if err = s.validateMetadata(ctx, user); err != nil {
+		return
+	}
+	switch user.Status {
+	case "":
+		user.Status = StatusActive
+	case StatusPending, StatusActive:
+	default:
+		err = errors.New("new users are pending or active")
+		return er.New(err, er.InvalidStatus).SetStatus(http.StatusUnprocessableEntity)
+	}
+	user.Version = 1
+	if user.ReferredBy != nil && user.ReferredAt == nil {
+		now := time.Now()
+		user.ReferredAt = &now
+	}
+	// referral codes are random, a taken code is drawn again
+	for attempt := 1; ; attempt++ {
+		if user.ReferralCode, err = newReferralCode(); err != nil {
+			return
+		}
+		err = s.Repo.CreateUser(ctx, user)
+		if attempt == referralCodeAttempts || !isReferralCodeViolation(err) {
+			break
+		}
+	}
+	if isUniqueViolation(err) {
+		err = er.New(err, er.UserAlreadyExists).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return
 }
 
 func (s Service) FetchUserByID(ctx context.Context, userID int) (user *User, err error) {
@@ -27,16 +95,142 @@ func (s Service) FetchUserByID(ctx context.Context, userID int) (user *User, err
 	return s.Repo.Fetch(ctx, userID)
 }
 
+// FetchUserByIDIncludingDeleted fetches the user even if it is soft deleted
+func (s Service) FetchUserByIDIncludingDeleted(ctx context.Context, userID int) (user *User, err error) {
+	return s.Repo.FetchIncludingDeleted(ctx, userID)
+}
+
+// DeleteUser soft deletes the user. The mobile of a deleted user can be registered again.
+func (s Service) DeleteUser(ctx context.Context, userID int) (err error) {
+	err = s.Repo.DeleteUser(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	return
+}
+
+// RestoreUser restores a soft deleted user. It fails if the mobile of the user
+// has been registered by another user since the user was deleted,
+// or if the user is erased, scheduled for erasure or merged into another user.
+func (s Service) RestoreUser(ctx context.Context, userID int) (user *User, err error) {
+	user, err = s.Repo.FetchIncludingDeleted(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if user.DeletedAt == nil {
+		err = er.New(errors.New("user is not deleted"), er.UserNotDeleted).SetStatus(http.StatusConflict)
+		return
+	}
+	if user.ErasedAt != nil {
+		err = er.New(errors.New("user is erased"), er.UserErased).SetStatus(http.StatusGone)
+		return
+	}
+	if user.MergedInto != nil {
+		err = er.New(errors.New("user was merged into another user"), er.UserMerged).SetStatus(http.StatusConflict)
+		return
+	}
+	if err = s.checkNoPendingErasure(ctx, userID); err != nil {
+		return
+	}
+
+	err = s.Repo.RestoreUser(ctx, user)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.UserAlreadyExists).SetStatus(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		return
+	}
+	return s.Repo.Fetch(ctx, userID)
+}
+
+// UpdateUser replaces the user if it is still at `user.Version`, otherwise it returns `er.VersionMismatch`.
+// Fields left empty are cleared, except the mobile which is required.
+// On success `user.Version` is set to the new version.
 func (s Service) UpdateUser(ctx context.Context, user *User) (err error) {
-	return s.Repo.UpdateUser(ctx, user)
+	if user.Mobile == "" {
+		return er.New(errors.New("mobile is required"), er.PrimaryPhoneRequired).SetStatus(http.StatusUnprocessableEntity)
+	}
+	if err = s.setPrimaryPhone(user); err != nil {
+		return
+	}
+	if err = s.validateMetadata(ctx, user); err != nil {
+		return
+	}
+	err = s.Repo.UpdateUser(ctx, user)
+	if isUniqueViolation(err) {
+		err = er.New(err, er.PhoneAlreadyExists).SetStatus(http.StatusConflict)
+	}
+	if err == ErrVersionConflict {
+		err = er.New(err, er.VersionMismatch).SetStatus(http.StatusPreconditionFailed)
+	}
+	return
 }
 
 func (s *Service) FetchAllUsers(ctx context.Context, filter *UserRequest) (users []User, pagination Pagination, err error) {
-
+	for _, status := range filter.Status {
+		if _, err = ParseStatus(status); err != nil {
+			return
+		}
+	}
+	if filter.Tag, err = normalizeTags(filter.Tag); err != nil {
+		return
+	}
+	switch filter.TagMatch {
+	case "":
+		filter.TagMatch = TagMatchAny
+	case TagMatchAny, TagMatchAll:
+	default:
+		err = errors.New("tag_match must be any or all")
+		return users, pagination, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+	filter.MetadataFilters = make([]MetadataFilter, 0, len(filter.Meta))
+	for _, m := range filter.Meta {
+		f, err := ParseMetadataFilter(m)
+		if err != nil {
+			return users, pagination, err
+		}
+		filter.MetadataFilters = append(filter.MetadataFilters, f)
+	}
+	filter.ConsentFilters = make([]ConsentFilter, 0, len(filter.Consent))
+	for _, c := range filter.Consent {
+		f, err := ParseConsentFilter(c)
+		if err != nil {
+			return users, pagination, err
+		}
+		filter.ConsentFilters = append(filter.ConsentFilters, f)
+	}
 	return s.Repo.FetchAllUsers(ctx, filter)
 }
 
+// FetchByMobileNumber finds the user owning the phone number, the number can be in any format
 func (s *Service) FetchByMobileNumber(dCtx context.Context, mobile string) (user *User, err error) {
+	n, err := s.NormalizePhone(mobile)
+	if err != nil {
+		return
+	}
+	return s.Repo.FetchByMobileNumber(dCtx, n.E164)
+}
+
+// setPrimaryPhone normalizes `User.Mobile` and sets it as the primary phone of the user
+func (s Service) setPrimaryPhone(user *User) error {
+	n, err := s.NormalizePhone(user.Mobile)
+	if err != nil {
+		return err
+	}
+	user.setMobile(n)
 
-	return s.Repo.FetchByMobileNumber(dCtx, mobile)
+	primary := Phone{
+		UserID:    user.ID,
+		Label:     PrimaryPhoneLabel,
+		IsPrimary: true,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+	primary.setNumber(n)
+	user.Phones = []Phone{primary}
+	return nil
 }
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+)
+
+// Status is the lifecycle state of a user account
+type Status string
+
+// account statuses
+const (
+	// StatusPending is a user which has signed up but is not verified yet
+	StatusPending     Status = "pending"
+	StatusActive      Status = "active"
+	StatusSuspended   Status = "suspended"
+	StatusBanned      Status = "banned"
+	StatusDeactivated Status = "deactivated"
+)
+
+// statusTransitions are the statuses a user can be moved to from each status.
+// A banned user is never reinstated.
+var statusTransitions = map[Status][]Status{
+	StatusPending:     {StatusActive, StatusBanned, StatusDeactivated},
+	StatusActive:      {StatusSuspended, StatusBanned, StatusDeactivated},
+	StatusSuspended:   {StatusActive, StatusBanned, StatusDeactivated},
+	StatusBanned:      {},
+	StatusDeactivated: {StatusActive},
+}
+
+type (
+	// StatusChange is an append-only record of a transition of the status of a user
+	StatusChange struct {
+		tableName  struct{}  `pg:"user_status_changes,discard_unknown_columns"`
+		ID         int64     `json:"id" pg:"id"`
+		UserID     int       `json:"user_id" pg:"user_id,notnull"`
+		FromStatus Status    `json:"from_status" pg:"from_status,notnull"`
+		ToStatus   Status    `json:"to_status" pg:"to_status,notnull"`
+		Reason     string    `json:"reason" pg:"reason,notnull"`
+		Actor      string    `json:"actor" pg:"actor,notnull"`
+		RequestID  string    `json:"request_id,omitempty" pg:"request_id"`
+		CreatedAt  time.Time `json:"created_at" pg:"created_at"`
+
+		tenant.Owned
+	}
+
+	// StatusRequest is the request body of change status API
+	StatusRequest struct {
+		Status Status `json:"status" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+
+	// StatusHistoryRequest is the query of status history API
+	StatusHistoryRequest struct {
+		Page  int `form:"page,default=1" binding:"min=1"`
+		Limit int `form:"limit,default=20" binding:"min=1,max=100"`
+	}
+)
+
+// ParseStatus returns `er.InvalidStatus` if s is not a known status
+func ParseStatus(s string) (Status, error) {
+	status := Status(s)
+	if _, ok := statusTransitions[status]; !ok {
+		err := errors.New("invalid status " + s)
+		return "", er.New(err, er.InvalidStatus).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return status, nil
+}
+
+// CanTransition reports whether a user in status `from` can be moved to status `to`
+func CanTransition(from, to Status) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
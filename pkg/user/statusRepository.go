@@ -0,0 +1,63 @@
+package user
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ChangeUserStatus moves the user from `change.FromStatus` to `change.ToStatus` and records the change
+// in the status history and the audit trail. It returns `ErrVersionConflict` if the status of the user
+// is no longer `change.FromStatus`.
+func (r *PGRepo) ChangeUserStatus(ctx context.Context, change *StatusChange) (err error) {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		before := &User{ID: change.UserID}
+		if err = tenant.Query(ctx, tx, before).WherePK().For("UPDATE").Select(); err != nil {
+			return
+		}
+		if before.Status != change.FromStatus {
+			return ErrVersionConflict
+		}
+
+		now := time.Now()
+		_, err = tenant.Query(ctx, tx, (*User)(nil)).
+			Set("status = ?", change.ToStatus).
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id = ?", change.UserID).
+			Update()
+		if err != nil {
+			return
+		}
+		if _, err = tenant.Query(ctx, tx, change).Insert(); err != nil {
+			return
+		}
+		after := &User{ID: change.UserID}
+		if err = tenant.Query(ctx, tx, after).WherePK().Select(); err != nil {
+			return
+		}
+		return r.insertAudit(ctx, tx, AuditUpdate, before, after)
+	})
+}
+
+// FetchStatusChanges fetches the status history of the user, latest first
+func (r *PGRepo) FetchStatusChanges(ctx context.Context, userID int, req *StatusHistoryRequest) (changes []StatusChange, pagination Pagination, err error) {
+	changes = []StatusChange{}
+	count, err := tenant.Query(ctx, r.db, &changes).
+		Where("user_id = ?", userID).
+		Order("created_at DESC", "id DESC").
+		Limit(req.Limit).
+		Offset((req.Page - 1) * req.Limit).
+		SelectAndCount()
+	if err != nil {
+		return
+	}
+	pagination.TotalDataCount = count
+	pagination.CurrentPage = req.Page
+	pagination.TotalPages = int(math.Ceil(float64(count) / float64(req.Limit)))
+	return
+}
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ChangeUserStatus moves the user to the requested status if `statusTransitions` allows it from the
+// current status of the user, otherwise it returns `er.IllegalStatusTransition`.
+// The reason and the actor of the change are recorded in the status history of the user.
+func (s *Service) ChangeUserStatus(ctx context.Context, userID int, req *StatusRequest) (user *User, err error) {
+	to, err := ParseStatus(string(req.Status))
+	if err != nil {
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	info := auditInfoFrom(ctx)
+	if reason == "" || info.Actor == "" {
+		err = errors.New("reason and actor of a status change are required")
+		return nil, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	user, err = s.Repo.Fetch(ctx, userID)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	if err != nil {
+		return
+	}
+	if !CanTransition(user.Status, to) {
+		err = fmt.Errorf("user cannot be moved from %s to %s", user.Status, to)
+		return nil, er.New(err, er.IllegalStatusTransition).SetStatus(http.StatusConflict)
+	}
+
+	err = s.Repo.ChangeUserStatus(ctx, &StatusChange{
+		UserID:     userID,
+		FromStatus: user.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		Actor:      info.Actor,
+		RequestID:  info.RequestID,
+		CreatedAt:  time.Now(),
+	})
+	switch err {
+	case nil:
+	case pg.ErrNoRows:
+		return nil, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	case ErrVersionConflict:
+		return nil, er.New(err, er.VersionMismatch).SetStatus(http.StatusPreconditionFailed)
+	default:
+		return nil, err
+	}
+	return s.Repo.Fetch(ctx, userID)
+}
+
+// FetchStatusHistory lists the status changes of the user, latest first.
+// The history of soft deleted users is listed as well.
+func (s *Service) FetchStatusHistory(ctx context.Context, userID int, req *StatusHistoryRequest) (changes []StatusChange, pagination Pagination, err error) {
+	if _, err = s.Repo.FetchIncludingDeleted(ctx, userID); err != nil {
+		if err == pg.ErrNoRows {
+			err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		}
+		return
+	}
+	return s.Repo.FetchStatusChanges(ctx, userID, req)
+}
@@ -0,0 +1,52 @@
+package user
+
+import (
+	"testing"
+
+	"gouser/er"
+)
+
+func TestParseStatus(t *testing.T) {
+	for _, s := range []Status{StatusPending, StatusActive, StatusSuspended, StatusBanned, StatusDeactivated} {
+		if got, err := ParseStatus(string(s)); err != nil || got != s {
+			t.Errorf("ParseStatus(%s) = %s, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "Active", "deleted", "erased"} {
+		if _, err := ParseStatus(s); !er.IsCodeEq(err, er.InvalidStatus) {
+			t.Errorf("ParseStatus(%q) error = %v, want InvalidStatus", s, err)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	all := []Status{StatusPending, StatusActive, StatusSuspended, StatusBanned, StatusDeactivated}
+	allowed := map[Status][]Status{
+		StatusPending:     {StatusActive, StatusBanned, StatusDeactivated},
+		StatusActive:      {StatusSuspended, StatusBanned, StatusDeactivated},
+		StatusSuspended:   {StatusActive, StatusBanned, StatusDeactivated},
+		StatusDeactivated: {StatusActive},
+		// a banned user is never reinstated
+		StatusBanned: {},
+	}
+	for _, from := range all {
+		for _, to := range all {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+	// nothing moves a user back to pending, nor to an unknown status
+	for _, from := range all {
+		if CanTransition(from, StatusPending) || CanTransition(from, "deleted") {
+			t.Errorf("CanTransition from %s to pending or an unknown status", from)
+		}
+	}
+	if CanTransition("deleted", StatusActive) {
+		t.Error("CanTransition from an unknown status")
+	}
+}
@@ -0,0 +1,100 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gouser/er"
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// tag match modes of the user filter
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+// tagPattern is the format of normalized tag names, eg. `vip` or `beta-tester`
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+func init() {
+	// join table of `User.Tags`
+	orm.RegisterTable((*UserTag)(nil))
+}
+
+type (
+	// Tag is a label of users, eg. `vip`. Tags are created when they are first assigned.
+	Tag struct {
+		tableName struct{}   `pg:"tags,discard_unknown_columns"`
+		ID        int        `json:"id" pg:"id"`
+		Name      string     `json:"name" pg:"name,notnull"`
+		CreatedAt *time.Time `json:"created_at" pg:"created_at"`
+
+		tenant.Owned
+	}
+
+	// UserTag assigns a tag to a user
+	UserTag struct {
+		tableName struct{}   `pg:"user_tags"`
+		UserID    int        `pg:"user_id,pk"`
+		TagID     int        `pg:"tag_id,pk"`
+		CreatedAt *time.Time `pg:"created_at"`
+
+		tenant.Owned
+	}
+
+	// TagUsage is a tag of the catalogue along with the number of users it is assigned to
+	TagUsage struct {
+		tableName struct{}   `pg:"tags,alias:tag"`
+		ID        int        `json:"id" pg:"id"`
+		Name      string     `json:"name" pg:"name"`
+		CreatedAt *time.Time `json:"created_at" pg:"created_at"`
+		UserCount int        `json:"user_count" pg:"user_count"`
+
+		tenant.Owned
+	}
+
+	// TagAssignmentRequest is the request body of bulk tag assignment API
+	TagAssignmentRequest struct {
+		UserIDs []int    `json:"user_ids" binding:"required,min=1,max=1000"`
+		Add     []string `json:"add,omitempty" binding:"max=100"`
+		Remove  []string `json:"remove,omitempty" binding:"max=100"`
+	}
+
+	// TagAssignmentResult counts the tag assignments added and removed by a bulk assignment
+	TagAssignmentResult struct {
+		Added   int `json:"added"`
+		Removed int `json:"removed"`
+	}
+)
+
+// NormalizeTag lowercases and trims the tag name and returns `er.InvalidTag` if it is not valid
+func NormalizeTag(name string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(name))
+	if !tagPattern.MatchString(tag) {
+		err := errors.New("invalid tag " + name)
+		return "", er.New(err, er.InvalidTag).SetStatus(http.StatusUnprocessableEntity)
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes the tag names and drops duplicates
+func normalizeTags(names []string) (tags []string, err error) {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return
+}
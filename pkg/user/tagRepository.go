@@ -0,0 +1,103 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"gouser/pkg/tenant"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// AssignTags adds and removes tags of the users in one transaction, added tags are created if missing.
+// Assignments which already exist are left as is. Tags are looked up and created in the tenant of the context.
+// The version of the users whose tags changed is bumped.
+func (r *PGRepo) AssignTags(ctx context.Context, userIDs []int, add, remove []string) (result TagAssignmentResult, err error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return result, tenant.ErrNoTenant
+	}
+	err = r.db.RunInTransaction(ctx, func(tx *pg.Tx) (err error) {
+		now := time.Now()
+		changed := []int{}
+		if len(add) > 0 {
+			tags := make([]Tag, len(add))
+			for i, name := range add {
+				tags[i] = Tag{Name: name, CreatedAt: &now}
+			}
+			if _, err = tenant.Query(ctx, tx, &tags).OnConflict("(tenant_id, name) DO NOTHING").Insert(); err != nil {
+				return
+			}
+			added := []int{}
+			_, err = tx.QueryContext(ctx, &added, `INSERT INTO user_tags (tenant_id, user_id, tag_id, created_at)
+				SELECT t.tenant_id, u.id, t.id, ? FROM unnest(?::bigint[]) AS u(id) CROSS JOIN tags AS t
+				WHERE t.tenant_id = ? AND t.name IN (?)
+				ON CONFLICT DO NOTHING
+				RETURNING user_id`, now, pg.Array(userIDs), tenantID, pg.In(add))
+			if err != nil {
+				return
+			}
+			result.Added = len(added)
+			changed = append(changed, added...)
+		}
+		if len(remove) > 0 {
+			removed := []int{}
+			_, err = tenant.Query(ctx, tx, (*UserTag)(nil)).
+				Where("user_id IN (?)", pg.In(userIDs)).
+				Where("tag_id IN (SELECT id FROM tags WHERE name IN (?))", pg.In(remove)).
+				Returning("user_id").
+				Delete(&removed)
+			if err != nil {
+				return
+			}
+			result.Removed = len(removed)
+			changed = append(changed, removed...)
+		}
+		return r.touchUsers(ctx, tx, changed...)
+	})
+	return
+}
+
+// FetchUserTags fetches the tags of the user ordered by name
+func (r *PGRepo) FetchUserTags(ctx context.Context, userID int) (tags []Tag, err error) {
+	tags = []Tag{}
+	err = tenant.Query(ctx, r.db, &tags).
+		Where("id IN (SELECT tag_id FROM user_tags WHERE user_id = ?)", userID).
+		Order("name ASC").
+		Select()
+	return
+}
+
+// FetchTagUsages fetches the tag catalogue ordered by name, with the number of users, not deleted, of each tag
+func (r *PGRepo) FetchTagUsages(ctx context.Context) (usages []TagUsage, err error) {
+	usages = []TagUsage{}
+	err = tenant.Query(ctx, r.db, &usages).
+		Column("tag.id", "tag.name", "tag.created_at").
+		ColumnExpr(`count("user".id) AS user_count`).
+		Join("LEFT JOIN user_tags AS ut ON ut.tag_id = tag.id").
+		Join(`LEFT JOIN "user" ON "user".id = ut.user_id AND "user".deleted_at IS NULL`).
+		Group("tag.id").
+		Order("tag.name ASC").
+		Select()
+	return
+}
+
+// FetchUserIDs returns which of the ids are ids of users, not deleted
+func (r *PGRepo) FetchUserIDs(ctx context.Context, ids []int) (found []int, err error) {
+	err = tenant.Query(ctx, r.db, (*User)(nil)).
+		Column("id").
+		Where("id IN (?)", pg.In(ids)).
+		Select(&found)
+	return
+}
+
+// applyTagFilter filters the users having any or all of the tags, see `TagMatchAny` and `TagMatchAll`
+func applyTagFilter(query *orm.Query, tags []string, match string) {
+	sub := `?TableAlias.id IN (SELECT ut.user_id FROM user_tags AS ut JOIN tags AS t ON t.id = ut.tag_id WHERE t.name IN (?)`
+	if match == TagMatchAll {
+		query.Where(sub+` GROUP BY ut.user_id HAVING count(*) = ?)`, pg.In(tags), len(tags))
+		return
+	}
+	query.Where(sub+`)`, pg.In(tags))
+}
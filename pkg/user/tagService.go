@@ -0,0 +1,125 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gouser/er"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// AssignTags adds and removes tags of the users in bulk. Tags are created when first added.
+// It returns `er.UserNotFound` if any of the users does not exist.
+func (s *Service) AssignTags(ctx context.Context, req *TagAssignmentRequest) (result TagAssignmentResult, err error) {
+	add, err := normalizeTags(req.Add)
+	if err != nil {
+		return
+	}
+	remove, err := normalizeTags(req.Remove)
+	if err != nil {
+		return
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		err = errors.New("no tags to add or remove")
+		return result, er.New(err, er.InvalidRequestBody).SetStatus(http.StatusUnprocessableEntity)
+	}
+
+	userIDs := uniqueInts(req.UserIDs)
+	found, err := s.Repo.FetchUserIDs(ctx, userIDs)
+	if err != nil {
+		return
+	}
+	if len(found) != len(userIDs) {
+		err = fmt.Errorf("users %v not found", missingInts(userIDs, found))
+		return result, er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	return s.Repo.AssignTags(ctx, userIDs, add, remove)
+}
+
+// AddUserTag assigns the tag to the user, assigning a tag the user already has succeeds
+func (s *Service) AddUserTag(ctx context.Context, userID int, name string) (tags []Tag, err error) {
+	tag, err := NormalizeTag(name)
+	if err != nil {
+		return
+	}
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	if _, err = s.Repo.AssignTags(ctx, []int{userID}, []string{tag}, nil); err != nil {
+		return
+	}
+	return s.Repo.FetchUserTags(ctx, userID)
+}
+
+// RemoveUserTag removes the tag from the user, it returns `er.TagNotFound` if the user does not have the tag
+func (s *Service) RemoveUserTag(ctx context.Context, userID int, name string) (tags []Tag, err error) {
+	tag, err := NormalizeTag(name)
+	if err != nil {
+		return
+	}
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	result, err := s.Repo.AssignTags(ctx, []int{userID}, nil, []string{tag})
+	if err != nil {
+		return
+	}
+	if result.Removed == 0 {
+		err = er.New(errors.New("user does not have tag "+tag), er.TagNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	return s.Repo.FetchUserTags(ctx, userID)
+}
+
+func (s *Service) FetchUserTags(ctx context.Context, userID int) (tags []Tag, err error) {
+	if err = s.checkUserExists(ctx, userID); err != nil {
+		return
+	}
+	return s.Repo.FetchUserTags(ctx, userID)
+}
+
+// FetchTagCatalogue lists all tags with the number of users having each tag
+func (s *Service) FetchTagCatalogue(ctx context.Context) (usages []TagUsage, err error) {
+	return s.Repo.FetchTagUsages(ctx)
+}
+
+// checkUserExists returns `er.UserNotFound` if the user does not exist or is deleted
+func (s *Service) checkUserExists(ctx context.Context, userID int) error {
+	found, err := s.Repo.FetchUserIDs(ctx, []int{userID})
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return er.New(pg.ErrNoRows, er.UserNotFound).SetStatus(http.StatusNotFound)
+	}
+	return nil
+}
+
+// uniqueInts returns the values without duplicates, in order of first occurrence
+func uniqueInts(values []int) (unique []int) {
+	seen := make(map[int]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return
+}
+
+// missingInts returns the values which are not in found
+func missingInts(values, found []int) (missing []int) {
+	has := make(map[int]bool, len(found))
+	for _, v := range found {
+		has[v] = true
+	}
+	for _, v := range values {
+		if !has[v] {
+			missing = append(missing, v)
+		}
+	}
+	return
+}
@@ -1,8 +1,11 @@
 package user
 
 import (
+	"errors"
 	"time"
 
+	"gouser/pkg/tenant"
+
 	"go.uber.org/fx"
 )
 
@@ -14,18 +17,54 @@ var Module = fx.Options(
 	),
 )
 
+// ErrVersionConflict is returned when a user is updated with a stale version
+var ErrVersionConflict = errors.New("user version conflict")
+
 type (
+	// User is a user account. Version is incremented on every update of the user row
+	// and is used for optimistic concurrency control.
 	User struct {
-		tableName      struct{}    `pg:"user,discard_unknown_columns"`
-		ID             int         `json:"id" pg:"id"`
-		FirstName      string      `json:"first_name" pg:"first_name"`
-		LastName       string      `json:"last_name" pg:"last_name"`
-		Mobile         string      `json:"mobile" pg:"mobile,unique"`
-		ProfilePicture string      `json:"profile_picture" pg:"profile_picture"`
-		DOB            *time.Time  `json:"dob" form:"dob" time_format:"2006-01-02" pg:"dob"`
-		CreatedAt      *time.Time  `json:"created_at" form:"created_at" pg:"created_at"`
-		UpdatedAt      *time.Time  `json:"updated_at" form:"updated_at" pg:"updated_at"`
-		Metadata       interface{} `json:"metadata,omitempty" pg:"metadata,type:jsonb"`
+		tableName      struct{} `pg:"user,discard_unknown_columns"`
+		ID             int      `json:"id" pg:"id"`
+		FirstName      string   `json:"first_name" pg:"first_name"`
+		LastName       string   `json:"last_name" pg:"last_name"`
+		Mobile         string   `json:"mobile" pg:"mobile"`
+		CountryCode    int      `json:"country_code" pg:"country_code"`
+		NationalNumber string   `json:"national_number" pg:"national_number"`
+		ProfilePicture string   `json:"profile_picture" pg:"profile_picture"`
+		// ProfilePictureVariants are the URLs of the resized uploaded picture by size, eg. "64"
+		ProfilePictureVariants map[string]string `json:"profile_picture_variants,omitempty" pg:"profile_picture_variants,type:jsonb"`
+		// ProfilePictureKey is the blob key prefix of the uploaded picture
+		ProfilePictureKey string     `json:"-" pg:"profile_picture_key"`
+		DOB               *time.Time `json:"dob" form:"dob" time_format:"2006-01-02" pg:"dob"`
+		CreatedAt         *time.Time `json:"created_at" form:"created_at" pg:"created_at"`
+		UpdatedAt         *time.Time `json:"updated_at" form:"updated_at" pg:"updated_at"`
+		DeletedAt         *time.Time `json:"deleted_at,omitempty" pg:"deleted_at,soft_delete"`
+		ErasedAt          *time.Time `json:"erased_at,omitempty" pg:"erased_at"`
+		Version           int        `json:"version" pg:"version"`
+		// Status is changed with `Service.ChangeUserStatus` only, see `statusTransitions`
+		Status   Status      `json:"status" pg:"status"`
+		Metadata interface{} `json:"metadata,omitempty" pg:"metadata,type:jsonb"`
+
+		// MetadataNamespace and MetadataSchemaVersion identify the schema `Metadata` was validated against
+		MetadataNamespace     string `json:"metadata_namespace,omitempty" pg:"metadata_namespace"`
+		MetadataSchemaVersion int    `json:"metadata_schema_version,omitempty" pg:"metadata_schema_version"`
+
+		// ReferralCode is generated when the user is created, ReferredBy is the user who referred the user,
+		// it is set at creation or with `Service.SetReferrer` only
+		ReferralCode string     `json:"referral_code" pg:"referral_code"`
+		ReferredBy   *int       `json:"referred_by,omitempty" pg:"referred_by"`
+		ReferredAt   *time.Time `json:"referred_at,omitempty" pg:"referred_at"`
+
+		// MergedInto is the user the deleted user was merged into, see `Service.MergeUsers`
+		MergedInto *int `json:"merged_into,omitempty" pg:"merged_into"`
+
+		Phones    []Phone   `json:"phones,omitempty" pg:"rel:has-many"`
+		Emails    []Email   `json:"emails,omitempty" pg:"rel:has-many"`
+		Addresses []Address `json:"addresses,omitempty" pg:"rel:has-many"`
+		Tags      []Tag     `json:"tags,omitempty" pg:"many2many:user_tags"`
+
+		tenant.Owned
 	}
 
 	Pagination struct {
@@ -36,8 +75,45 @@ type (
 
 	UserRequest struct {
 		Mobile *string `form:"mobile,omitempty"`
+		Email  *string `form:"email,omitempty"`
 		Name   *string `form:"name,omitempty"`
 		Page   int     `form:"page,default=1"`
 		Limit  int     `form:"limit,default=20"`
+
+		// Status filters users in any of the statuses
+		Status []string `form:"status,omitempty"`
+
+		// Tag filters users having any of the tags, or all of them if TagMatch is `all`
+		Tag      []string `form:"tag,omitempty"`
+		TagMatch string   `form:"tag_match,omitempty"`
+
+		// IncludeDeleted returns soft deleted users as well
+		IncludeDeleted bool `form:"include_deleted,omitempty"`
+
+		// Meta filters users by their metadata, see `MetadataFilter` for the syntax
+		Meta            []string         `form:"meta,omitempty"`
+		MetadataFilters []MetadataFilter `form:"-"`
+
+		// Consent filters users who currently consent to each of the purposes, `purpose:current` to the latest
+		// version of the purpose, see `ParseConsentFilter`
+		Consent        []string        `form:"consent,omitempty"`
+		ConsentFilters []ConsentFilter `form:"-"`
 	}
 )
+
+// setMobile sets the normalized number as the mobile of the user
+func (u *User) setMobile(n *PhoneNumber) {
+	u.Mobile = n.E164
+	u.CountryCode = n.CountryCode
+	u.NationalNumber = n.NationalNumber
+}
+
+// primaryPhone returns the primary phone among the loaded phones of the user
+func (u *User) primaryPhone() *Phone {
+	for i := range u.Phones {
+		if u.Phones[i].IsPrimary {
+			return &u.Phones[i]
+		}
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ var Module = fx.Options(
 		InitLogrus,
 	),
 	fx.Invoke(
+		AutoMigrate,
 		LivenessProbe,
 	),
 )
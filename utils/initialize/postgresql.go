@@ -3,10 +3,10 @@ package initialize
 import (
 	"context"
 	"fmt"
-	"gouser/pkg/user"
+	"gouser/migrations"
+	"gouser/utils/migrate"
 
 	"github.com/go-pg/pg/v10"
-	"github.com/go-pg/pg/v10/orm"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -85,7 +85,6 @@ func postgresqlInit(dbName, dbUser, dbPassword, dbHost, dbPort string, log *logr
 		return
 	}
 
-	createSchema(DB)
 	log.Info("Successfully connected!")
 	log.WithFields(logrus.Fields{
 		"database": dbName,
@@ -93,19 +92,28 @@ func postgresqlInit(dbName, dbUser, dbPassword, dbHost, dbPort string, log *logr
 	return
 }
 
-func createSchema(db *pg.DB) error {
-	models := []interface{}{
-		(*user.User)(nil),
+// AutoMigrateIn is function param struct of func `AutoMigrate`
+type AutoMigrateIn struct {
+	fx.In
+
+	Config *viper.Viper
+	Log    *logrus.Logger
+	DB     *pg.DB `name:"gouserDB"`
+}
+
+// AutoMigrate applies the pending schema migrations on start when `auto_migrate` is enabled
+func AutoMigrate(i AutoMigrateIn) error {
+	if !i.Config.GetBool("auto_migrate") {
+		return nil
 	}
 
-	for _, model := range models {
-		err := db.Model(model).CreateTable(&orm.CreateTableOptions{
-			Temp:        false,
-			IfNotExists: true,
-		})
-		if err != nil {
-			return err
-		}
+	m, err := migrate.New(i.DB, i.Log, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if _, err = m.Up(context.Background()); err != nil {
+		i.Log.WithField("error", err.Error()).Error("schema migration failed")
+		return err
 	}
 	return nil
 }
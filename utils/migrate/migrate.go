@@ -0,0 +1,272 @@
+// Package migrate applies versioned SQL migrations to the database.
+// Applied versions are recorded in the `schema_migrations` table, and a postgres
+// advisory lock makes sure only one process migrates the database at a time.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// lockID is the key of the advisory lock held while migrating
+const lockID = 7_346_201_845
+
+var (
+	fileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+type (
+	// Migration is a versioned schema change
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Status is the state of a migration in the database
+	Status struct {
+		Version   int64      `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at"`
+	}
+
+	// schemaMigration is a row of `schema_migrations` table
+	schemaMigration struct {
+		tableName struct{}  `pg:"schema_migrations"`
+		Version   int64     `pg:"version,pk"`
+		Name      string    `pg:"name"`
+		AppliedAt time.Time `pg:"applied_at"`
+	}
+)
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *pg.DB
+	log        *logrus.Logger
+	migrations []Migration
+}
+
+// New loads migrations from fsys and returns a migrator for the database
+func New(db *pg.DB, log *logrus.Logger, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, log: log, migrations: migrations}, nil
+}
+
+// Load reads all `<version>_<name>.(up|down).sql` files of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	hasUp := map[int64]bool{}
+	for _, entry := range entries {
+		m := fileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		b, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+			hasUp[version] = true
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if !hasUp[mig.Version] {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations and returns the applied ones
+func (m *Migrator) Up(ctx context.Context) (applied []Migration, err error) {
+	err = m.locked(ctx, func(conn *pg.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			err = conn.RunInTransaction(ctx, func(tx *pg.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.ModelContext(ctx, &schemaMigration{
+					Version:   mig.Version,
+					Name:      mig.Name,
+					AppliedAt: time.Now(),
+				}).Insert()
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			m.log.WithField("migration", fmt.Sprintf("%d_%s", mig.Version, mig.Name)).Info("migration applied")
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return
+}
+
+// Down reverts the last `steps` applied migrations and returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []Migration, err error) {
+	err = m.locked(ctx, func(conn *pg.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			err = conn.RunInTransaction(ctx, func(tx *pg.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.ModelContext(ctx, &schemaMigration{Version: mig.Version}).WherePK().Delete()
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			m.log.WithField("migration", fmt.Sprintf("%d_%s", mig.Version, mig.Name)).Info("migration reverted")
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return
+}
+
+// Status lists all migrations along with the time they were applied at
+func (m *Migrator) Status(ctx context.Context) (status []Status, err error) {
+	err = m.locked(ctx, func(conn *pg.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if row, ok := done[mig.Version]; ok {
+				s.AppliedAt = &row.AppliedAt
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+	return
+}
+
+// Create writes blank up and down files of a new migration in dir,
+// versioned next to the latest migration found in the directory.
+func Create(dir, name string) (files []string, err error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !nameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q", name)
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d_%s %s migration\n", version, name, direction)
+		if err = os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+// locked runs fn on a single connection holding the migration advisory lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pg.Conn) error) (err error) {
+	conn := m.db.Conn()
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", lockID); err != nil {
+		return
+	}
+	defer func() {
+		if _, uErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", lockID); uErr != nil && err == nil {
+			err = uErr
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	if err != nil {
+		return
+	}
+	return fn(conn)
+}
+
+// applied returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context, conn *pg.Conn) (map[int64]schemaMigration, error) {
+	rows := []schemaMigration{}
+	if err := conn.ModelContext(ctx, &rows).Select(); err != nil {
+		return nil, err
+	}
+	done := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}